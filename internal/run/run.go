@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package run
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+// Until instantiates a given process, prepares the instance with a given
+// function (if any) and starts it, passing its traces (unwrapped) to
+// a given function until the instance visits a flow node with a given ID
+//
+// Every trace is logged and error traces fail the test. The instance is
+// stopped once the test is over.
+func Until(t *testing.T, proc *process.Process, end string,
+	prepare func(*instance.Instance), handle func(tracing.Trace)) *instance.Instance {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	defer tracer.Unsubscribe(traces)
+	inst, err := proc.Instantiate(instance.WithTracer(tracer), instance.WithContext(ctx))
+	require.Nil(t, err)
+	if prepare != nil {
+		prepare(inst)
+	}
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		t.Logf("%#v", trace)
+		if trace, ok := trace.(tracing.ErrorTrace); ok {
+			t.Fatalf("%#v", trace)
+		}
+		if handle != nil {
+			handle(trace)
+		}
+		if trace, ok := trace.(flow.VisitTrace); ok {
+			if id, present := trace.Node.Id(); present && *id == end {
+				return inst
+			}
+		}
+	}
+}
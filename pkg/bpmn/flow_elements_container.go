@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package bpmn
+
+// FlowElementsContainerInterface is implemented by elements
+// that contain flow elements (Process, SubProcess, Transaction, etc.)
+//
+// BPMN specification defines FlowElementsContainer as an abstract
+// superclass of these, but the XML schema doesn't have a matching type,
+// so the generated code doesn't have a matching interface either.
+type FlowElementsContainerInterface interface {
+	Element
+	BaseElementInterface
+	Name() (result *string, present bool)
+	LaneSets() (result *[]LaneSet)
+	AdHocSubProcesses() (result *[]AdHocSubProcess)
+	BoundaryEvents() (result *[]BoundaryEvent)
+	BusinessRuleTasks() (result *[]BusinessRuleTask)
+	CallActivities() (result *[]CallActivity)
+	CallChoreographies() (result *[]CallChoreography)
+	ChoreographyTasks() (result *[]ChoreographyTask)
+	ComplexGateways() (result *[]ComplexGateway)
+	DataObjects() (result *[]DataObject)
+	DataObjectReferences() (result *[]DataObjectReference)
+	DataStoreReferences() (result *[]DataStoreReference)
+	EndEvents() (result *[]EndEvent)
+	Events() (result *[]Event)
+	EventBasedGateways() (result *[]EventBasedGateway)
+	ExclusiveGateways() (result *[]ExclusiveGateway)
+	ImplicitThrowEvents() (result *[]ImplicitThrowEvent)
+	InclusiveGateways() (result *[]InclusiveGateway)
+	IntermediateCatchEvents() (result *[]IntermediateCatchEvent)
+	IntermediateThrowEvents() (result *[]IntermediateThrowEvent)
+	ManualTasks() (result *[]ManualTask)
+	ParallelGateways() (result *[]ParallelGateway)
+	ReceiveTasks() (result *[]ReceiveTask)
+	ScriptTasks() (result *[]ScriptTask)
+	SendTasks() (result *[]SendTask)
+	SequenceFlows() (result *[]SequenceFlow)
+	ServiceTasks() (result *[]ServiceTask)
+	StartEvents() (result *[]StartEvent)
+	SubChoreographies() (result *[]SubChoreography)
+	SubProcesses() (result *[]SubProcess)
+	Tasks() (result *[]Task)
+	Transactions() (result *[]Transaction)
+	UserTasks() (result *[]UserTask)
+	Associations() (result *[]Association)
+	Groups() (result *[]Group)
+	TextAnnotations() (result *[]TextAnnotation)
+	FlowElements() []FlowElementInterface
+	Artifacts() []ArtifactInterface
+}
@@ -129,3 +129,10 @@ func TestElementPredicateOr(t *testing.T) {
 	assert.True(t, ExactId("a").Or(ExactId("b"))(&proc))
 	assert.False(t, ExactId("A").Or(ExactId("B"))(&proc))
 }
+
+func TestFlowElementsContainerInterface(t *testing.T) {
+	var _ FlowElementsContainerInterface = &Process{}
+	var _ FlowElementsContainerInterface = &SubProcess{}
+	var _ FlowElementsContainerInterface = &Transaction{}
+	var _ FlowElementsContainerInterface = &AdHocSubProcess{}
+}
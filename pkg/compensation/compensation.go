@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package compensation
+
+import (
+	"context"
+	"sync"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/tracing"
+)
+
+// Handler runs activity's compensation handler,
+// returning once it is done
+type Handler func(ctx context.Context)
+
+type entry struct {
+	activity bpmn.FlowNodeInterface
+	handler  Handler
+}
+
+// Scope keeps track of completed activities that have compensation
+// handlers within a scope (process instance or sub-process execution)
+type Scope struct {
+	lock    sync.Mutex
+	tracer  tracing.Tracer
+	entries []entry
+}
+
+func NewScope(tracer tracing.Tracer) *Scope {
+	return &Scope{tracer: tracer, entries: make([]entry, 0)}
+}
+
+// Register records activity's compensation handler
+// once the activity has completed
+//
+// Every completion is recorded separately, so an activity that
+// has completed multiple times will be compensated as many times.
+func (scope *Scope) Register(activity bpmn.FlowNodeInterface, handler Handler) {
+	scope.lock.Lock()
+	defer scope.lock.Unlock()
+	scope.entries = append(scope.entries, entry{activity: activity, handler: handler})
+}
+
+// Compensate runs compensation handlers of completed activities
+// (only of a given activity, unless activityRef is nil) one by one,
+// in reverse order of their completion, and returns once they are
+// done or the context is cancelled
+//
+// Every completion is compensated at most once.
+func (scope *Scope) Compensate(ctx context.Context, activityRef *bpmn.IdRef) {
+	for ctx.Err() == nil {
+		e, found := scope.pop(activityRef)
+		if !found {
+			return
+		}
+		scope.tracer.Trace(CompensationTrace{Activity: e.activity})
+		e.handler(ctx)
+	}
+}
+
+// IsEmpty returns true if there's nothing left to compensate
+func (scope *Scope) IsEmpty() bool {
+	scope.lock.Lock()
+	defer scope.lock.Unlock()
+	return len(scope.entries) == 0
+}
+
+// pop removes the most recently registered entry
+// matching activityRef and returns it
+func (scope *Scope) pop(activityRef *bpmn.IdRef) (e entry, found bool) {
+	scope.lock.Lock()
+	defer scope.lock.Unlock()
+	for i := len(scope.entries) - 1; i >= 0; i-- {
+		if activityRef != nil {
+			if id, present := scope.entries[i].activity.Id(); !present || *id != *activityRef {
+				continue
+			}
+		}
+		e, found = scope.entries[i], true
+		scope.entries = append(scope.entries[:i], scope.entries[i+1:]...)
+		return
+	}
+	return
+}
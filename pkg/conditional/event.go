@@ -0,0 +1,199 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package conditional
+
+import (
+	"context"
+	"sync"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/expression"
+	"bpxe.org/pkg/tracing"
+)
+
+type eventDefinitionInstanceBuilder struct {
+	// Conditions are watched until this context is done
+	context          context.Context
+	eventIngress     event.Consumer
+	tracer           tracing.Tracer
+	definitions      *bpmn.Definitions
+	itemAwareLocator data.ItemAwareLocator
+}
+
+type eventDefinitionInstance struct {
+	definition bpmn.ConditionalEventDefinition
+	condition  *condition
+}
+
+func (e *eventDefinitionInstance) EventDefinition() bpmn.EventDefinitionInterface {
+	return &e.definition
+}
+
+func (e *eventDefinitionInstance) ConditionHolds() (bool, error) {
+	return e.condition.evaluate()
+}
+
+func (e *eventDefinitionInstanceBuilder) NewEventDefinitionInstance(def bpmn.EventDefinitionInterface) (definitionInstance event.DefinitionInstance, err error) {
+	if conditionalEventDefinition, ok := def.(*bpmn.ConditionalEventDefinition); ok {
+		var c *condition
+		c, err = e.newCondition(conditionalEventDefinition)
+		if err != nil {
+			return
+		}
+		definitionInstance = &eventDefinitionInstance{definition: *conditionalEventDefinition, condition: c}
+		// Only changes that occur from now on can trigger the event.
+		// The data the condition depends on may not be initialized yet,
+		// so failing to evaluate it is not an error at this point.
+		satisfied, _ := c.evaluate()
+		go func(ctx context.Context) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-c.changes:
+					result, err := c.evaluate()
+					if err != nil {
+						e.tracer.Trace(tracing.ErrorTrace{Error: err})
+						continue
+					}
+					if result && !satisfied {
+						_, err := e.eventIngress.ConsumeEvent(event.MakeConditionalEvent(definitionInstance))
+						if err != nil {
+							e.tracer.Trace(tracing.ErrorTrace{Error: err})
+						}
+					}
+					satisfied = result
+				}
+			}
+		}(e.context)
+	}
+	return
+}
+
+// EventDefinitionInstanceBuilder creates a builder of conditional
+// event definition instances, conditions of which are evaluated
+// against data found through a given locator
+//
+// The condition is re-evaluated every time any of the data items
+// it has accessed changes. Once it becomes true (having been false),
+// the conditional event is sent to the event ingress. Definition
+// instances implement event.ConditionalDefinitionInstance, so that
+// catch events can also check the condition when they are activated.
+func EventDefinitionInstanceBuilder(
+	ctx context.Context,
+	eventIngress event.Consumer,
+	tracer tracing.Tracer,
+	definitions *bpmn.Definitions,
+	itemAwareLocator data.ItemAwareLocator,
+) event.DefinitionInstanceBuilder {
+	return &eventDefinitionInstanceBuilder{
+		context:          ctx,
+		eventIngress:     eventIngress,
+		tracer:           tracer,
+		definitions:      definitions,
+		itemAwareLocator: itemAwareLocator,
+	}
+}
+
+// condition is a compiled condition of a conditional event definition
+type condition struct {
+	// evaluations can come both from the watcher and from catch events
+	lock     sync.Mutex
+	ctx      context.Context
+	compiled *expression.Compiled
+	recorder *recorder
+	// receives a value whenever any of the watched data items changes
+	changes chan struct{}
+	watched map[data.ItemAware]struct{}
+}
+
+func (e *eventDefinitionInstanceBuilder) newCondition(definition *bpmn.ConditionalEventDefinition) (c *condition, err error) {
+	c = &condition{
+		ctx:      e.context,
+		recorder: &recorder{ItemAwareLocator: e.itemAwareLocator, found: make(map[data.ItemAware]struct{})},
+		changes:  make(chan struct{}, 1),
+		watched:  make(map[data.ItemAware]struct{}),
+	}
+	c.compiled, err = expression.Compile(e.context, e.definitions, definition.Condition().Expression, c.recorder)
+	return
+}
+
+// evaluate evaluates the condition and starts watching
+// data items it has accessed for changes
+func (c *condition) evaluate() (result bool, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result, err = c.compiled.EvaluateCondition(nil)
+	for itemAware := range c.recorder.found {
+		c.watch(itemAware)
+	}
+	return
+}
+
+func (c *condition) watch(itemAware data.ItemAware) {
+	if _, watched := c.watched[itemAware]; watched {
+		return
+	}
+	c.watched[itemAware] = struct{}{}
+	updates := itemAware.Subscribe(c.ctx)
+	if updates == nil {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case _, ok := <-updates:
+				if !ok {
+					return
+				}
+				select {
+				case c.changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+}
+
+// recorder is an item aware locator that remembers
+// which data items have been found through it
+type recorder struct {
+	data.ItemAwareLocator
+	found map[data.ItemAware]struct{}
+}
+
+func (r *recorder) FindItemAwareById(id bpmn.IdRef) (itemAware data.ItemAware, found bool) {
+	itemAware, found = r.ItemAwareLocator.FindItemAwareById(id)
+	if found {
+		r.found[itemAware] = struct{}{}
+	}
+	return
+}
+
+func (r *recorder) FindItemAwareByName(name string) (itemAware data.ItemAware, found bool) {
+	itemAware, found = r.ItemAwareLocator.FindItemAwareByName(name)
+	if found {
+		r.found[itemAware] = struct{}{}
+	}
+	return
+}
+
+func (r *recorder) FindItemAwareLocatorByScope(scope string) (itemAwareLocator data.ItemAwareLocator, found bool) {
+	if scopedItemAwareLocator, ok := r.ItemAwareLocator.(data.ScopedItemAwareLocator); ok {
+		itemAwareLocator, found = scopedItemAwareLocator.FindItemAwareLocatorByScope(scope)
+		if found {
+			itemAwareLocator = &recorder{ItemAwareLocator: itemAwareLocator, found: r.found}
+		}
+	}
+	return
+}
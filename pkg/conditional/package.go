@@ -0,0 +1,16 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+// Package conditional provides evaluation of conditional events in BPXE
+//
+// Conditions are evaluated against data of the process instance the event
+// belongs to. Therefore, a conditional start event only starts a flow within
+// an existing instance once instance's data satisfies the condition. It
+// doesn't instantiate the process at model level (see model.Model.Run), as
+// there is no data outside of instances to evaluate the condition against.
+package conditional
@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package correlation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/expression"
+)
+
+// Values maps correlation property IDs to their values
+type Values map[string]interface{}
+
+// MessageValues evaluates retrieval expressions of all correlation
+// properties that can be retrieved from event's message against
+// message's payload
+func MessageValues(ctx context.Context, definitions *bpmn.Definitions,
+	ev *event.MessageEvent) (values Values, err error) {
+	values = make(Values)
+	payload := payload(ev.Item())
+	properties := definitions.CorrelationProperties()
+	for i := range *properties {
+		property := &(*properties)[i]
+		propertyId, present := property.Id()
+		if !present {
+			continue
+		}
+		retrievalExpressions := property.CorrelationPropertyRetrievalExpressions()
+		for j := range *retrievalExpressions {
+			retrievalExpression := &(*retrievalExpressions)[j]
+			if *retrievalExpression.MessageRef() != *ev.MessageRef() {
+				continue
+			}
+			values[*propertyId], err = expression.Evaluate(ctx, definitions,
+				retrievalExpression.MessagePath(), nil, payload)
+			if err != nil {
+				return
+			}
+			break
+		}
+	}
+	return
+}
+
+// SubscriptionValues evaluates correlation property bindings of the
+// subscription against process instance's data
+func SubscriptionValues(ctx context.Context, definitions *bpmn.Definitions,
+	subscription *bpmn.CorrelationSubscription,
+	locator data.ItemAwareLocator) (values Values, err error) {
+	values = make(Values)
+	bindings := subscription.CorrelationPropertyBindings()
+	for i := range *bindings {
+		binding := &(*bindings)[i]
+		values[*binding.CorrelationPropertyRef()], err = expression.Evaluate(ctx, definitions,
+			binding.DataPath(), locator, nil)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Correlates determines whether the message correlates with the process
+// instance.
+//
+// A subscription of the process is applicable to the message if all
+// properties of the subscription's correlation key can be retrieved
+// from the message. The message correlates if any of the applicable
+// subscriptions has the same values bound from instance's data.
+//
+// If there are no applicable subscriptions, `applicable` is false and
+// correlation is not used to decide whether the message should be
+// delivered to the instance.
+func Correlates(ctx context.Context, definitions *bpmn.Definitions,
+	process *bpmn.Process, ev *event.MessageEvent,
+	locator data.ItemAwareLocator) (correlates bool, applicable bool, err error) {
+	subscriptions := process.CorrelationSubscriptions()
+	if len(*subscriptions) == 0 {
+		return
+	}
+	var messageValues Values
+	messageValues, err = MessageValues(ctx, definitions, ev)
+	if err != nil || len(messageValues) == 0 {
+		return
+	}
+	for i := range *subscriptions {
+		subscription := &(*subscriptions)[i]
+		keyRef := *subscription.CorrelationKeyRef()
+		key, found := definitions.FindBy(bpmn.ExactId(keyRef).
+			And(bpmn.ElementType((*bpmn.CorrelationKey)(nil))))
+		if !found {
+			err = errors.NotFoundError{Expected: fmt.Sprintf("correlation key with ID %s", keyRef)}
+			return
+		}
+		propertyRefs := key.(*bpmn.CorrelationKey).CorrelationPropertyRefs()
+		if len(*propertyRefs) == 0 || !hasAll(messageValues, *propertyRefs) {
+			continue
+		}
+		applicable = true
+		var instanceValues Values
+		instanceValues, err = SubscriptionValues(ctx, definitions, subscription, locator)
+		if err != nil {
+			return
+		}
+		correlates = true
+		for _, propertyRef := range *propertyRefs {
+			value, present := instanceValues[propertyRef]
+			if !present || !reflect.DeepEqual(value, messageValues[propertyRef]) {
+				correlates = false
+				break
+			}
+		}
+		if correlates {
+			return
+		}
+	}
+	return
+}
+
+func hasAll(values Values, propertyRefs []string) bool {
+	for _, propertyRef := range propertyRefs {
+		if _, present := values[propertyRef]; !present {
+			return false
+		}
+	}
+	return true
+}
+
+// payload converts message's item to the form accepted
+// by expression engines
+func payload(item data.Item) interface{} {
+	if items, ok := item.(map[string]data.Item); ok {
+		converted := make(map[string]interface{}, len(items))
+		for name, item := range items {
+			converted[name] = item
+		}
+		return converted
+	}
+	return item
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+	_ "bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/flow_node/activity/receive_task"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/correlation.bpmn", testdata, &testDoc)
+}
+
+func TestCorrelation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	m := model.New(&testDoc, model.WithContext(ctx), model.WithTracer(tracer))
+	err := m.Run(ctx)
+	require.Nil(t, err)
+
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+
+	instances := make(map[string]*instance.Instance)
+	for _, orderId := range []string{"a", "b"} {
+		inst, err := proc.Instantiate()
+		require.Nil(t, err)
+		itemAware, found := inst.FindItemAwareByName("orderId")
+		require.True(t, found)
+		<-itemAware.Put(ctx, orderId)
+		err = inst.StartAll(ctx)
+		require.Nil(t, err)
+		instances[orderId] = inst
+	}
+
+	for waiting := 0; waiting < 2; {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case receive_task.WaitingForMessageTrace:
+			waiting++
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+
+	// Only the instance with the matching order ID receives the message
+	_, err = m.ConsumeEvent(event.NewMessageEvent("paymentReceived", nil,
+		map[string]interface{}{"orderId": "b"}))
+	require.Nil(t, err)
+
+	completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer completionCancel()
+	assert.True(t, instances["b"].WaitUntilComplete(completionCtx))
+
+	pendingCtx, pendingCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer pendingCancel()
+	assert.False(t, instances["a"].WaitUntilComplete(pendingCtx))
+
+	// A message that doesn't correlate with any instance
+	// instantiates the process with a message start event
+	_, err = m.ConsumeEvent(event.NewMessageEvent("paymentReceived", nil,
+		map[string]interface{}{"orderId": "c"}))
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case model.EventInstantiationAttemptedTrace:
+			messageEvent, ok := trace.Event.(*event.MessageEvent)
+			if !ok {
+				continue
+			}
+			// The message correlated with instance "b" should have
+			// never reached the start event
+			assert.Equal(t, map[string]interface{}{"orderId": "c"}, messageEvent.Item())
+			return
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
+
+func TestCorrelationWithCompletedInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	m := model.New(&testDoc, model.WithContext(ctx), model.WithTracer(tracer))
+	err := m.Run(ctx)
+	require.Nil(t, err)
+
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	itemAware, found := inst.FindItemAwareByName("orderId")
+	require.True(t, found)
+	<-itemAware.Put(ctx, "a")
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+
+	for waiting := false; !waiting; {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case receive_task.WaitingForMessageTrace:
+			waiting = true
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+
+	_, err = m.ConsumeEvent(event.NewMessageEvent("paymentReceived", nil,
+		map[string]interface{}{"orderId": "a"}))
+	require.Nil(t, err)
+
+	completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer completionCancel()
+	require.True(t, inst.WaitUntilComplete(completionCtx))
+
+	// Once the instance has completed, a message with the same
+	// order ID no longer correlates with it and instantiates
+	// the process with a message start event
+	_, err = m.ConsumeEvent(event.NewMessageEvent("paymentReceived", nil,
+		map[string]interface{}{"orderId": "a"}))
+	require.Nil(t, err)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case trace := <-traces:
+			switch trace := tracing.Unwrap(trace).(type) {
+			case model.EventInstantiationAttemptedTrace:
+				messageEvent, ok := trace.Event.(*event.MessageEvent)
+				if !ok {
+					continue
+				}
+				assert.Equal(t, map[string]interface{}{"orderId": "a"}, messageEvent.Item())
+				return
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		case <-timeout:
+			t.Fatal("the message hasn't instantiated the process")
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package association
+
+import (
+	"context"
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+)
+
+// MissingInputErrorCode is the error code of the BPMN error activities
+// raise when their data inputs don't satisfy any of their input sets
+// (see MissingInputError and MissingInputErrorRef)
+const MissingInputErrorCode = "missingInput"
+
+// MissingInputErrorRef returns the reference of the BPMN error activities
+// raise when their data inputs don't satisfy any of their input sets:
+// the ID of the error with MissingInputErrorCode as its error code in
+// given definitions
+//
+// If there's no such error, the reference is MissingInputErrorCode itself,
+// so the error can still be caught by an error boundary event referencing
+// an error with that ID.
+func MissingInputErrorRef(definitions *bpmn.Definitions) string {
+	bpmnErrors := definitions.Errors()
+	for i := range *bpmnErrors {
+		if errorCode, present := (*bpmnErrors)[i].ErrorCode(); present && *errorCode == MissingInputErrorCode {
+			if id, present := (*bpmnErrors)[i].Id(); present {
+				return *id
+			}
+		}
+	}
+	return MissingInputErrorCode
+}
+
+// MissingInputError denotes that none of activity's input sets
+// can be satisfied with the data inputs available
+type MissingInputError struct {
+	Activity bpmn.Id
+	// Names (or IDs) of required data inputs missing from
+	// the first input set
+	Inputs []string
+}
+
+func (e MissingInputError) Error() string {
+	return fmt.Sprintf("activity %s is missing required data inputs %v", e.Activity, e.Inputs)
+}
+
+// Inputs evaluates activity's data input associations and returns input
+// items keyed by the name of the data input they are associated with
+// (or its id, if the data input has no name or is not declared in
+// activity's ioSpecification)
+//
+// Every association yields either its transformation's result, its source
+// items (a single item or a collection), or nothing, if it has neither.
+// Association's assignments are applied on top of that (see Assignment).
+// Transformation and assignments are evaluated using definitions'
+// expression language (unless they specify their own) and have
+// source items available to them by source IDs and as `source`.
+//
+// If activity's ioSpecification declares input sets, returned inputs must
+// satisfy at least one of them (have all its non-optional data inputs
+// with non-nil items), otherwise MissingInputError is returned.
+func Inputs(ctx context.Context, definitions *bpmn.Definitions, element bpmn.ActivityInterface,
+	locator data.ItemAwareLocator) (inputs map[string]data.Item, err error) {
+	inputs = make(map[string]data.Item)
+	associations := element.DataInputAssociations()
+	for i := range *associations {
+		association := &(*associations)[i].DataAssociation
+		sourceRefs := association.SourceRefs()
+		sources := make(map[string]data.Item, len(*sourceRefs))
+		items := make([]data.Item, 0, len(*sourceRefs))
+		for _, sourceRef := range *sourceRefs {
+			var item data.Item
+			item, err = get(ctx, sourceRef, locator)
+			if err != nil {
+				return
+			}
+			sources[sourceRef] = item
+			items = append(items, item)
+		}
+		var target data.Item
+		if len(items) > 0 {
+			target = data.ItemOrCollection(items...)
+		}
+		target, err = apply(ctx, definitions, association, locator, sources, target)
+		if err != nil {
+			return
+		}
+		inputs[inputName(element, *association.TargetRef())] = target
+	}
+	err = satisfy(element, inputs)
+	return
+}
+
+// Outputs applies activity's data output associations, writing given
+// output items (keyed the same way as in Inputs, but by data outputs)
+// to the data objects and properties they are associated with.
+// Associations none of which sources are present in the map
+// are skipped.
+//
+// Transformations and assignments are evaluated the same way as in
+// Inputs, with output items available to them by their keys and
+// as `source`. Associations without sources only apply their
+// assignments to the current value of their target.
+func Outputs(ctx context.Context, definitions *bpmn.Definitions, element bpmn.ActivityInterface,
+	locator data.ItemAwareLocator, outputs map[string]data.Item) (err error) {
+	associations := element.DataOutputAssociations()
+	for i := range *associations {
+		association := &(*associations)[i].DataAssociation
+		sourceRefs := association.SourceRefs()
+		items := make([]data.Item, 0, len(*sourceRefs))
+		for _, sourceRef := range *sourceRefs {
+			if item, present := outputs[outputName(element, sourceRef)]; present {
+				items = append(items, item)
+			}
+		}
+		var target data.Item
+		switch {
+		case len(items) > 0:
+			target = data.ItemOrCollection(items...)
+		case len(*sourceRefs) > 0:
+			continue
+		default:
+			target, err = get(ctx, *association.TargetRef(), locator)
+			if err != nil {
+				return
+			}
+		}
+		target, err = apply(ctx, definitions, association, locator, outputs, target)
+		if err != nil {
+			return
+		}
+		err = put(ctx, *association.TargetRef(), locator, target)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func inputName(element bpmn.ActivityInterface, id bpmn.IdRef) string {
+	if ioSpecification, present := element.IoSpecification(); present {
+		for i := range *ioSpecification.DataInputs() {
+			dataInput := &(*ioSpecification.DataInputs())[i]
+			if idPtr, present := dataInput.Id(); present && *idPtr == id {
+				if name, present := dataInput.Name(); present {
+					return *name
+				}
+			}
+		}
+	}
+	return id
+}
+
+func outputName(element bpmn.ActivityInterface, id bpmn.IdRef) string {
+	if ioSpecification, present := element.IoSpecification(); present {
+		for i := range *ioSpecification.DataOutputs() {
+			dataOutput := &(*ioSpecification.DataOutputs())[i]
+			if idPtr, present := dataOutput.Id(); present && *idPtr == id {
+				if name, present := dataOutput.Name(); present {
+					return *name
+				}
+			}
+		}
+	}
+	return id
+}
+
+func get(ctx context.Context, id bpmn.IdRef, locator data.ItemAwareLocator) (item data.Item, err error) {
+	itemAware, found := locator.FindItemAwareById(id)
+	if !found {
+		err = errors.NotFoundError{Expected: fmt.Sprintf("item aware element with ID %s", id)}
+		return
+	}
+	ch := itemAware.Get(ctx)
+	if ch == nil {
+		err = ctx.Err()
+		return
+	}
+	select {
+	case item = <-ch:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+func put(ctx context.Context, id bpmn.IdRef, locator data.ItemAwareLocator, item data.Item) (err error) {
+	itemAware, found := locator.FindItemAwareById(id)
+	if !found {
+		err = errors.NotFoundError{Expected: fmt.Sprintf("item aware element with ID %s", id)}
+		return
+	}
+	ch := itemAware.Put(ctx, item)
+	if ch == nil {
+		err = ctx.Err()
+		return
+	}
+	select {
+	case <-ch:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package association
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/expression"
+)
+
+// apply evaluates association's transformation (if there's one) and
+// then its assignments, starting with a given target item
+//
+// Assignment evaluates its `from` expression and assigns the result to
+// the part of the target denoted by its `to` expression: a dot-separated
+// path of keys in (possibly nested) maps, creating them as necessary.
+// Empty `to` replaces the target entirely.
+func apply(ctx context.Context, definitions *bpmn.Definitions, association *bpmn.DataAssociation,
+	locator data.ItemAwareLocator, sources map[string]data.Item, target data.Item) (result data.Item, err error) {
+	result = target
+	env := make(map[string]interface{}, len(sources)+1)
+	for name, item := range sources {
+		env[name] = item
+	}
+	env["source"] = target
+
+	if transformation, present := association.Transformation(); present {
+		result, err = expression.Evaluate(ctx, definitions, transformation, locator, env)
+		if err != nil {
+			return
+		}
+	}
+
+	assignments := association.Assignments()
+	for i := range *assignments {
+		assignment := &(*assignments)[i]
+		var value data.Item
+		value, err = expression.Evaluate(ctx, definitions, assignment.From().Expression, locator, env)
+		if err != nil {
+			return
+		}
+		var path []string
+		if to := assignment.To().Expression; to != nil {
+			if text := strings.Trim(*to.TextPayload(), " \n"); text != "" {
+				path = strings.Split(text, ".")
+			}
+		}
+		result, err = assign(result, path, value)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// assign returns a copy of the target with a value assigned to the key
+// path within it
+func assign(target data.Item, path []string, value data.Item) (result data.Item, err error) {
+	if len(path) == 0 {
+		result = value
+		return
+	}
+	m := make(map[string]interface{})
+	switch t := target.(type) {
+	case nil:
+	case map[string]interface{}:
+		for k, v := range t {
+			m[k] = v
+		}
+	default:
+		err = errors.InvalidArgumentError{
+			Expected: fmt.Sprintf("a map to assign %s to", strings.Join(path, ".")),
+			Actual:   target,
+		}
+		return
+	}
+	m[path[0]], err = assign(m[path[0]], path[1:], value)
+	result = m
+	return
+}
+
+// satisfy checks that inputs satisfy at least one of activity's input sets,
+// if it declares any
+func satisfy(element bpmn.ActivityInterface, inputs map[string]data.Item) (err error) {
+	ioSpecification, present := element.IoSpecification()
+	if !present || len(*ioSpecification.InputSets()) == 0 {
+		return
+	}
+	var missing []string
+	inputSets := ioSpecification.InputSets()
+	for i := range *inputSets {
+		inputSet := &(*inputSets)[i]
+		optional := make(map[bpmn.IdRef]bool)
+		for _, ref := range *inputSet.OptionalInputRefses() {
+			optional[ref] = true
+		}
+		setMissing := make([]string, 0)
+		for _, ref := range *inputSet.DataInputRefses() {
+			if optional[ref] {
+				continue
+			}
+			name := inputName(element, ref)
+			if item, present := inputs[name]; !present || item == nil {
+				setMissing = append(setMissing, name)
+			}
+		}
+		if len(setMissing) == 0 {
+			return
+		}
+		if missing == nil {
+			missing = setMissing
+		}
+	}
+	missingInputError := MissingInputError{Inputs: missing}
+	if id, present := element.Id(); present {
+		missingInputError.Activity = *id
+	}
+	err = missingInputError
+	return
+}
@@ -31,10 +31,22 @@ type putMessage struct {
 
 func (p putMessage) implementsRunnerMessage() {}
 
+type subscribeMessage struct {
+	subscription subscription
+}
+
+func (s subscribeMessage) implementsRunnerMessage() {}
+
+type subscription struct {
+	ctx     context.Context
+	channel chan Item
+}
+
 type Container struct {
 	bpmn.ItemAwareInterface
 	runnerChannel chan runnerMessage
 	item          Item
+	subscriptions []subscription
 }
 
 func NewContainer(ctx context.Context, itemAware bpmn.ItemAwareInterface) *Container {
@@ -60,6 +72,9 @@ func (c *Container) run(ctx context.Context) {
 			case putMessage:
 				c.item = msg.item
 				close(msg.channel)
+				c.notify()
+			case subscribeMessage:
+				c.subscriptions = append(c.subscriptions, msg.subscription)
 			}
 		case <-ctx.Done():
 			return
@@ -86,3 +101,35 @@ func (c *Container) Put(ctx context.Context, item Item) <-chan struct{} {
 		return nil
 	}
 }
+
+func (c *Container) Subscribe(ctx context.Context) <-chan Item {
+	// The channel holds the latest update only, so that
+	// a slow subscriber never holds the container back
+	ch := make(chan Item, 1)
+	select {
+	case c.runnerChannel <- subscribeMessage{subscription: subscription{ctx: ctx, channel: ch}}:
+		return ch
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// notify sends the current item to all subscribers, replacing
+// any update they haven't received yet, and drops subscriptions
+// that are no longer needed
+func (c *Container) notify() {
+	subscriptions := c.subscriptions[:0]
+	for _, sub := range c.subscriptions {
+		if sub.ctx.Err() != nil {
+			close(sub.channel)
+			continue
+		}
+		select {
+		case <-sub.channel:
+		default:
+		}
+		sub.channel <- c.item
+		subscriptions = append(subscriptions, sub)
+	}
+	c.subscriptions = subscriptions
+}
@@ -112,6 +112,17 @@ type ItemAware interface {
 	// If context is cancelled while sending in a request for data,
 	// a nil channel will be returned.
 	Put(ctx context.Context, item Item) <-chan struct{}
+	// Subscribe returns a channel that will send the data item
+	// every time it is updated (see Put)
+	//
+	// Updates are not queued up: if the subscriber hasn't received
+	// the previous update yet, it only receives the latest one.
+	// Once the context is cancelled, the channel will be closed
+	// upon the next update.
+	//
+	// If context is cancelled while sending in a request for
+	// subscription, a nil channel will be returned.
+	Subscribe(ctx context.Context) <-chan Item
 }
 
 // ItemAwareLocator interface describes a way to find ItemAware
@@ -121,3 +132,12 @@ type ItemAwareLocator interface {
 	// FindItemAwareByName finds ItemAware by its name (where applicable)
 	FindItemAwareByName(name string) (itemAware ItemAware, found bool)
 }
+
+// ScopedItemAwareLocator is an ItemAwareLocator that is a part of
+// a hierarchy of nested scopes (such as a sub-process within a process)
+type ScopedItemAwareLocator interface {
+	ItemAwareLocator
+	// FindItemAwareLocatorByScope finds ItemAwareLocator of this or any
+	// enclosing scope by scope's name or bpmn.Id (where applicable)
+	FindItemAwareLocatorByScope(scope string) (itemAwareLocator ItemAwareLocator, found bool)
+}
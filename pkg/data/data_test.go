@@ -52,3 +52,24 @@ func TestSliceIterator_ItemIterator_ContextDone(t *testing.T) {
 	assert.Nil(t, value)
 	assert.False(t, ok)
 }
+
+func TestContainer_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	container := NewContainer(ctx, nil)
+	updates := container.Subscribe(ctx)
+	<-container.Put(ctx, 1)
+	assert.Equal(t, 1, <-updates)
+	// only the latest update is kept
+	<-container.Put(ctx, 2)
+	<-container.Put(ctx, 3)
+	assert.Equal(t, 3, <-updates)
+
+	subscriptionCtx, cancelSubscription := context.WithCancel(ctx)
+	unsubscribed := container.Subscribe(subscriptionCtx)
+	cancelSubscription()
+	<-container.Put(ctx, 4)
+	assert.Equal(t, 4, <-updates)
+	_, ok := <-unsubscribed
+	assert.False(t, ok)
+}
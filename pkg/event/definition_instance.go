@@ -18,6 +18,17 @@ type DefinitionInstance interface {
 	EventDefinition() bpmn.EventDefinitionInterface
 }
 
+// ConditionalDefinitionInstance is a definition instance of an event
+// that occurs once its condition becomes true (such as a conditional event)
+//
+// The event only occurs when the condition changes, so those who start
+// catching it when the condition is already true check it themselves.
+type ConditionalDefinitionInstance interface {
+	DefinitionInstance
+	// ConditionHolds evaluates the condition
+	ConditionHolds() (bool, error)
+}
+
 // wrappedDefinitionInstance is a simple wrapper for bpmn.EventDefinitionInterface
 // that adds no extra context
 type wrappedDefinitionInstance struct {
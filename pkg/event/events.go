@@ -50,11 +50,11 @@ type SignalEvent struct {
 }
 
 func MakeSignalEvent(signalRef string, items ...data.Item) SignalEvent {
-	return SignalEvent{signalRef: signalRef, item: data.ItemOrCollection(items)}
+	return SignalEvent{signalRef: signalRef, item: data.ItemOrCollection(items...)}
 }
 
 func NewSignalEvent(signalRef string, items ...data.Item) *SignalEvent {
-	event := MakeSignalEvent(signalRef, items)
+	event := MakeSignalEvent(signalRef, items...)
 	return &event
 }
 
@@ -103,13 +103,27 @@ type CompensationEvent struct {
 	activityRef string
 }
 
+// MakeCompensationEvent creates a compensation event for a given activity
+// (or for all completed activities, if activityRef is empty)
 func MakeCompensationEvent(activityRef string) CompensationEvent {
 	return CompensationEvent{activityRef: activityRef}
 }
 
+func NewCompensationEvent(activityRef string) *CompensationEvent {
+	event := MakeCompensationEvent(activityRef)
+	return &event
+}
+
 func (ev *CompensationEvent) MatchesEventInstance(instance DefinitionInstance) bool {
-	// always false because there's no event definition that matches
-	return false
+	definition, ok := instance.EventDefinition().(*bpmn.CompensateEventDefinition)
+	if !ok {
+		return false
+	}
+	activityRef, present := definition.ActivityRef()
+	if !present || ev.activityRef == "" {
+		return true
+	}
+	return *activityRef == ev.activityRef
 }
 
 func (ev *CompensationEvent) ActivityRef() *string {
@@ -127,7 +141,7 @@ func MakeMessageEvent(messageRef string, operationRef *string, items ...data.Ite
 	return MessageEvent{
 		messageRef:   messageRef,
 		operationRef: operationRef,
-		item:         data.ItemOrCollection(items),
+		item:         data.ItemOrCollection(items...),
 	}
 }
 
@@ -174,6 +188,11 @@ func (ev *MessageEvent) OperationRef() (result *string, present bool) {
 	return
 }
 
+// Item returns message's payload, if any
+func (ev *MessageEvent) Item() data.Item {
+	return ev.item
+}
+
 // Escalation event
 type EscalationEvent struct {
 	escalationRef string
@@ -181,7 +200,12 @@ type EscalationEvent struct {
 }
 
 func MakeEscalationEvent(escalationRef string, items ...data.Item) EscalationEvent {
-	return EscalationEvent{escalationRef: escalationRef, item: data.ItemOrCollection(items)}
+	return EscalationEvent{escalationRef: escalationRef, item: data.ItemOrCollection(items...)}
+}
+
+func NewEscalationEvent(escalationRef string, items ...data.Item) *EscalationEvent {
+	event := MakeEscalationEvent(escalationRef, items...)
+	return &event
 }
 
 func (ev *EscalationEvent) MatchesEventInstance(instance DefinitionInstance) bool {
@@ -191,7 +215,9 @@ func (ev *EscalationEvent) MatchesEventInstance(instance DefinitionInstance) boo
 	}
 	escalationRef, present := definition.EscalationRef()
 	if !present {
-		return false
+		// escalation event definition without escalationRef
+		// catches all escalations
+		return true
 	}
 	return ev.escalationRef == *escalationRef
 }
@@ -200,6 +226,11 @@ func (ev *EscalationEvent) EscalationRef() *string {
 	return &ev.escalationRef
 }
 
+// Item returns escalation's payload, if any
+func (ev *EscalationEvent) Item() data.Item {
+	return ev.item
+}
+
 // Link event
 type LinkEvent struct {
 	sources []string
@@ -273,7 +304,12 @@ type ErrorEvent struct {
 }
 
 func MakeErrorEvent(errorRef string, items ...data.Item) ErrorEvent {
-	return ErrorEvent{errorRef: errorRef, item: data.ItemOrCollection(items)}
+	return ErrorEvent{errorRef: errorRef, item: data.ItemOrCollection(items...)}
+}
+
+func NewErrorEvent(errorRef string, items ...data.Item) *ErrorEvent {
+	event := MakeErrorEvent(errorRef, items...)
+	return &event
 }
 
 func (ev *ErrorEvent) MatchesEventInstance(instance DefinitionInstance) bool {
@@ -283,7 +319,8 @@ func (ev *ErrorEvent) MatchesEventInstance(instance DefinitionInstance) bool {
 	}
 	errorRef, present := definition.ErrorRef()
 	if !present {
-		return false
+		// error event definition without errorRef catches all errors
+		return true
 	}
 	return *errorRef == ev.errorRef
 }
@@ -292,6 +329,11 @@ func (ev *ErrorEvent) ErrorRef() *string {
 	return &ev.errorRef
 }
 
+// Item returns error's payload, if any
+func (ev *ErrorEvent) Item() data.Item {
+	return ev.item
+}
+
 // TimerEvent represents an event that occurs when a certain timer
 // is triggered.
 type TimerEvent struct {
@@ -310,8 +352,8 @@ func (ev *TimerEvent) Instance() DefinitionInstance {
 	return ev.instance
 }
 
-// ConditionalEvent represents an event that occurs when a certain timer
-// is triggered.
+// ConditionalEvent represents an event that occurs when the condition
+// of a certain conditional event definition becomes true.
 type ConditionalEvent struct {
 	instance DefinitionInstance
 }
@@ -32,3 +32,16 @@ func GetEngine(ctx context.Context, url string) (engine Engine) {
 	}
 	return
 }
+
+// LookupEngine returns an engine for a given language URL,
+// if there is one registered. Unlike GetEngine, it doesn't fall back
+// to XPath.
+func LookupEngine(ctx context.Context, url string) (engine Engine, found bool) {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+	var engineConstructor func(ctx context.Context) Engine
+	if engineConstructor, found = enginesMap[url]; found {
+		engine = engineConstructor(ctx)
+	}
+	return
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package expression
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+)
+
+// Compiled is an expression compiled by the engine of its language
+type Compiled struct {
+	// Source is the source of the expression, as compiled
+	Source   string
+	engine   Engine
+	compiled CompiledExpression
+}
+
+// CompileSource compiles the source of an expression in a given language
+// (falling back to XPath if there is no engine registered for it, see
+// GetEngine), making data objects found by a given locator (unless it is nil)
+// available to it
+func CompileSource(ctx context.Context, lang string, source string,
+	locator data.ItemAwareLocator) (*Compiled, error) {
+	return CompileWith(GetEngine(ctx, lang), source, locator)
+}
+
+// CompileWith compiles the source of an expression using a given engine,
+// making data objects found by a given locator (unless it is nil)
+// available to it
+func CompileWith(engine Engine, source string,
+	locator data.ItemAwareLocator) (compiled *Compiled, err error) {
+	if locator != nil {
+		engine.SetItemAwareLocator(locator)
+	}
+	compiled = &Compiled{Source: strings.Trim(source, " \n"), engine: engine}
+	compiled.compiled, err = engine.CompileExpression(compiled.Source)
+	if err != nil {
+		compiled = nil
+	}
+	return
+}
+
+// Compile compiles a formal expression using its language (or definitions'
+// expression language, if it doesn't specify one), making data objects
+// found by a given locator (unless it is nil) available to it
+//
+// Informal expressions can't be compiled.
+func Compile(ctx context.Context, definitions *bpmn.Definitions, expr bpmn.ExpressionInterface,
+	locator data.ItemAwareLocator) (compiled *Compiled, err error) {
+	formalExpression, ok := expr.(*bpmn.FormalExpression)
+	if !ok {
+		err = errors.NotSupportedError{
+			What:   "informal expression",
+			Reason: "it can't be evaluated",
+		}
+		return
+	}
+	lang := *definitions.ExpressionLanguage()
+	if language, present := formalExpression.Language(); present {
+		lang = *language
+	}
+	compiled, err = CompileSource(ctx, lang, *formalExpression.TextPayload(), locator)
+	return
+}
+
+// Evaluate evaluates the compiled expression with given variables
+func (compiled *Compiled) Evaluate(env interface{}) (Result, error) {
+	return compiled.engine.EvaluateExpression(compiled.compiled, env)
+}
+
+// EvaluateCondition evaluates the compiled expression with given variables,
+// expecting it to yield a boolean
+func (compiled *Compiled) EvaluateCondition(env interface{}) (result bool, err error) {
+	var abstractResult Result
+	abstractResult, err = compiled.Evaluate(env)
+	if err != nil {
+		return
+	}
+	result, ok := abstractResult.(bool)
+	if !ok {
+		err = errors.InvalidArgumentError{
+			Expected: fmt.Sprintf("boolean result in condition (%s)", compiled.Source),
+			Actual:   abstractResult,
+		}
+	}
+	return
+}
+
+// Evaluate compiles (see Compile) and evaluates an expression
+// with given variables
+func Evaluate(ctx context.Context, definitions *bpmn.Definitions, expr bpmn.ExpressionInterface,
+	locator data.ItemAwareLocator, env interface{}) (result Result, err error) {
+	var compiled *Compiled
+	compiled, err = Compile(ctx, definitions, expr, locator)
+	if err != nil {
+		return
+	}
+	result, err = compiled.Evaluate(env)
+	return
+}
+
+// EvaluateCondition compiles (see Compile) and evaluates an expression
+// with given variables, expecting it to yield a boolean
+func EvaluateCondition(ctx context.Context, definitions *bpmn.Definitions, expr bpmn.ExpressionInterface,
+	locator data.ItemAwareLocator, env interface{}) (result bool, err error) {
+	var compiled *Compiled
+	compiled, err = Compile(ctx, definitions, expr, locator)
+	if err != nil {
+		return
+	}
+	result, err = compiled.EvaluateCondition(env)
+	return
+}
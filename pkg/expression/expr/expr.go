@@ -15,6 +15,7 @@ import (
 	"bpxe.org/pkg/data"
 	"bpxe.org/pkg/errors"
 	"bpxe.org/pkg/expression"
+	"bpxe.org/pkg/history"
 	"github.com/antonmedv/expr"
 	"github.com/antonmedv/expr/vm"
 )
@@ -36,10 +37,25 @@ func New(ctx context.Context) *Expr {
 	engine.env = map[string]interface{}{
 		"getDataObject": func(args ...string) data.Item {
 			var name string
-			if len(args) == 1 {
+			itemAwareLocator := engine.itemAwareLocator
+			switch len(args) {
+			case 1:
 				name = args[0]
+			case 2:
+				// Two-argument version looks the data object up
+				// in a particular (enclosing) scope
+				scopedItemAwareLocator, ok := itemAwareLocator.(data.ScopedItemAwareLocator)
+				if !ok {
+					return nil
+				}
+				var found bool
+				itemAwareLocator, found = scopedItemAwareLocator.FindItemAwareLocatorByScope(args[0])
+				if !found {
+					return nil
+				}
+				name = args[1]
 			}
-			itemAware, found := engine.itemAwareLocator.FindItemAwareByName(name)
+			itemAware, found := itemAwareLocator.FindItemAwareByName(name)
 			if !found {
 				return nil
 			}
@@ -54,6 +70,40 @@ func New(ctx context.Context) *Expr {
 				return item
 			}
 		},
+		// getExecutions returns executions of a given process
+		// (optionally, only those with a given status) recorded
+		// in the history store found in the context
+		"getExecutions": func(args ...string) []map[string]interface{} {
+			store, found := history.FromContext(ctx)
+			if !found || len(args) == 0 {
+				return nil
+			}
+			predicate := history.ProcessId(args[0])
+			if len(args) > 1 {
+				predicate = predicate.And(func(execution *history.Execution) bool {
+					return execution.Status.String() == args[1]
+				})
+			}
+			executions, err := store.Query(predicate)
+			if err != nil {
+				return nil
+			}
+			result := make([]map[string]interface{}, 0, len(executions))
+			for i := range executions {
+				result = append(result, map[string]interface{}{
+					"instanceId":    executions[i].InstanceId.String(),
+					"processId":     executions[i].ProcessId,
+					"status":        executions[i].Status.String(),
+					"startedAt":     executions[i].StartedAt,
+					"endedAt":       executions[i].EndedAt,
+					"visited":       executions[i].Visited,
+					"sequenceFlows": executions[i].SequenceFlows,
+					"links":         executions[i].Links,
+					"data":          executions[i].Data,
+				})
+			}
+			return result
+		},
 	}
 	return engine
 }
@@ -14,7 +14,9 @@ import (
 
 	"bpxe.org/pkg/bpmn"
 	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
 	"bpxe.org/pkg/expression"
+	_ "bpxe.org/pkg/expression/xpath"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,3 +57,63 @@ func TestExpr_getDataObject(t *testing.T) {
 	assert.Nil(t, err)
 	assert.True(t, result.(bool))
 }
+
+type scopedDataObjects struct {
+	dataObjects
+	scopes map[string]dataObjects
+}
+
+func (d scopedDataObjects) FindItemAwareLocatorByScope(scope string) (itemAwareLocator data.ItemAwareLocator, found bool) {
+	itemAwareLocator, found = d.scopes[scope]
+	return
+}
+
+func TestExpr_getDataObject_scoped(t *testing.T) {
+	var engine = New(context.Background())
+	container := data.NewContainer(context.Background(), nil)
+	container.Put(context.Background(), 1)
+	objs := scopedDataObjects{
+		dataObjects: map[string]data.ItemAware{},
+		scopes: map[string]dataObjects{
+			"subProcess": map[string]data.ItemAware{
+				"dataObject": container,
+			},
+		},
+	}
+	engine.SetItemAwareLocator(objs)
+	compiled, err := engine.CompileExpression("getDataObject('subProcess', 'dataObject') > 0")
+	assert.Nil(t, err)
+	result, err := engine.EvaluateExpression(compiled, map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, result.(bool))
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	definitions := bpmn.DefaultDefinitions()
+	formalExpression := bpmn.DefaultFormalExpression()
+	formalExpression.TextPayloadField = " a > 1\n"
+	language := bpmn.AnyURI("https://github.com/antonmedv/expr")
+	formalExpression.SetLanguage(&language)
+	result, err := expression.EvaluateCondition(context.Background(), &definitions, &formalExpression, nil,
+		map[string]interface{}{"a": 2})
+	assert.Nil(t, err)
+	assert.True(t, result)
+
+	formalExpression.TextPayloadField = "a + 1"
+	_, err = expression.EvaluateCondition(context.Background(), &definitions, &formalExpression, nil,
+		map[string]interface{}{"a": 2})
+	assert.IsType(t, errors.InvalidArgumentError{}, err)
+
+	// Languages without a registered engine fall back to XPath
+	unknown := bpmn.AnyURI("urn:unknown")
+	formalExpression.SetLanguage(&unknown)
+	formalExpression.TextPayloadField = "true()"
+	result, err = expression.EvaluateCondition(context.Background(), &definitions, &formalExpression, nil,
+		map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.True(t, result)
+
+	informalExpression := bpmn.DefaultExpression()
+	_, err = expression.EvaluateCondition(context.Background(), &definitions, &informalExpression, nil, nil)
+	assert.IsType(t, errors.NotSupportedError{}, err)
+}
@@ -63,6 +63,11 @@ func (engine *XPath) EvaluateExpression(e expression.CompiledExpression,
 		// Eventually, a direct implementation of `parser.Parser`
 		// over `interface{}` should be developed to optimize this path.
 
+		if datum == nil {
+			// Expressions evaluated without variables
+			// (such as sequence flow conditions)
+			datum = map[string]interface{}{}
+		}
 		var serialized []byte
 		serialized, err = anyxml.Xml(datum)
 		if err != nil {
@@ -111,18 +116,30 @@ var asXMLType = reflect.TypeOf(new(data.AsXML)).Elem()
 func (engine *XPath) getDataObject() func(context exec.Context, args ...exec.Result) (exec.Result, error) {
 	return func(context exec.Context, args ...exec.Result) (exec.Result, error) {
 		var name string
+		itemAwareLocator := engine.itemAwareLocator
 		switch len(args) {
 		case 0:
 			return nil, errors.InvalidArgumentError{Expected: "at least one argument", Actual: "none"}
-		case 2:
-			return nil, errors.NotSupportedError{
-				What:   "two-argument getDataObject",
-				Reason: "BPXE doesn't support sub-processes yet",
-			}
 		case 1:
 			name = args[0].String()
+		case 2:
+			scopedItemAwareLocator, ok := itemAwareLocator.(data.ScopedItemAwareLocator)
+			if !ok {
+				return nil, errors.NotSupportedError{
+					What:   "two-argument getDataObject",
+					Reason: "item aware locator is not aware of scopes",
+				}
+			}
+			var found bool
+			itemAwareLocator, found = scopedItemAwareLocator.FindItemAwareLocatorByScope(args[0].String())
+			if !found {
+				return exec.NodeSet{}, nil
+			}
+			name = args[1].String()
+		default:
+			return nil, errors.InvalidArgumentError{Expected: "at most two arguments", Actual: len(args)}
 		}
-		itemAware, found := engine.itemAwareLocator.FindItemAwareByName(name)
+		itemAware, found := itemAwareLocator.FindItemAwareByName(name)
 		if !found {
 			return exec.NodeSet{}, nil
 		}
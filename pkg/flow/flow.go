@@ -11,7 +11,6 @@ package flow
 import (
 	"context"
 	"fmt"
-	"strings"
 	"sync"
 
 	"bpxe.org/pkg/bpmn"
@@ -83,6 +82,23 @@ func New(definitions *bpmn.Definitions,
 	}
 }
 
+// Restore creates a flow with a given id that has arrived at a flow node
+// through a given sequence flow (nil if the flow has started at it)
+//
+// Much like New, the flow does nothing until it is explicitly started.
+func Restore(flowId id.Id, sequenceFlowId *string, definitions *bpmn.Definitions,
+	current flow_node.FlowNodeInterface, tracer tracing.Tracer,
+	flowNodeMapping *flow_node.FlowNodeMapping, flowWaitGroup *sync.WaitGroup,
+	idGenerator id.Generator, actionTransformer flow_node.ActionTransformer,
+	itemAwareLocator data.ItemAwareLocator,
+) *Flow {
+	flow := New(definitions, current, tracer, flowNodeMapping, flowWaitGroup,
+		idGenerator, actionTransformer, itemAwareLocator)
+	flow.id = flowId
+	flow.sequenceFlowId = sequenceFlowId
+	return flow
+}
+
 func (flow *Flow) testSequenceFlow(ctx context.Context, sequenceFlow *sequence_flow.SequenceFlow, unconditional bool) (result bool, err error) {
 	if unconditional {
 		result = true
@@ -91,44 +107,12 @@ func (flow *Flow) testSequenceFlow(ctx context.Context, sequenceFlow *sequence_f
 	if expr, present := sequenceFlow.SequenceFlow.ConditionExpression(); present {
 		switch e := expr.Expression.(type) {
 		case *bpmn.FormalExpression:
-			var lang string
-
-			if language, present := e.Language(); present {
-				lang = *language
-			} else {
-				lang = *flow.definitions.ExpressionLanguage()
-			}
-
-			engine := expression.GetEngine(ctx, lang)
-			engine.SetItemAwareLocator(flow.itemAwareLocator)
-			source := strings.Trim(*e.TextPayload(), " \n")
-			var compiled expression.CompiledExpression
-			compiled, err = engine.CompileExpression(source)
-			if err != nil {
-				result = false
-				flow.tracer.Trace(tracing.ErrorTrace{Error: err})
-				return
-			}
-			var abstractResult expression.Result
-			abstractResult, err = engine.EvaluateExpression(compiled, nil)
+			result, err = expression.EvaluateCondition(ctx, flow.definitions, e, flow.itemAwareLocator, nil)
 			if err != nil {
 				result = false
 				flow.tracer.Trace(tracing.ErrorTrace{Error: err})
 				return
 			}
-			switch actualResult := abstractResult.(type) {
-			case bool:
-				result = actualResult
-			default:
-				err = errors.InvalidArgumentError{
-					Expected: fmt.Sprintf("boolean result in conditionExpression (%s)",
-						source),
-					Actual: actualResult,
-				}
-				result = false
-				flow.tracer.Trace(tracing.ErrorTrace{Error: err})
-				return
-			}
 		case *bpmn.Expression:
 			// informal expression, can't execute
 			result = true
@@ -243,10 +227,25 @@ func (flow *Flow) Start(ctx context.Context) {
 	sender := flow.tracer.RegisterSender()
 	go func() {
 		defer sender.Done()
-		flow.tracer.Trace(NewFlowTrace{FlowId: flow.id})
+		flow.tracer.Trace(NewFlowTrace{FlowId: flow.id, Node: flow.current.Element()})
 		defer flow.flowWaitGroup.Done()
 		flow.tracer.Trace(VisitTrace{Node: flow.current.Element()})
+		hold, _ := HoldFromContext(ctx)
 		for {
+			for hold != nil && hold.IsHeld() {
+				flow.tracer.Trace(HoldTrace{
+					FlowId: flow.Id(),
+					Node:   flow.current.Element(),
+				})
+				select {
+				case <-hold.Released():
+				case <-ctx.Done():
+					flow.tracer.Trace(CancellationTrace{
+						FlowId: flow.Id(),
+					})
+					return
+				}
+			}
 		await:
 			select {
 			case <-ctx.Done():
@@ -331,6 +330,26 @@ func (flow *Flow) Start(ctx context.Context) {
 						// nowhere to flow, abort
 						return
 					}
+				case flow_node.LinkAction:
+					if flowNode, found := flow.flowNodeMapping.ResolveElementToFlowNode(a.Target); found {
+						// The flow arrives at the target without
+						// going through a sequence flow
+						flow.tracer.Trace(LinkTrace{
+							FlowId: flow.Id(),
+							Source: flow.current.Element(),
+							Target: a.Target,
+						})
+						flow.sequenceFlowId = nil
+						flow.current = flowNode
+						flow.terminate = nil
+						flow.actionTransformer = nil
+						flow.tracer.Trace(VisitTrace{Node: flow.current.Element()})
+					} else {
+						flow.tracer.Trace(tracing.ErrorTrace{
+							Error: errors.NotFoundError{Expected: fmt.Sprintf("flow node for element %#v", a.Target)},
+						})
+						return
+					}
 				case flow_node.CompleteAction:
 					flow.tracer.Trace(CompletionTrace{
 						Node: flow.current.Element(),
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"sync"
+)
+
+// Hold allows to hold flows at flow nodes they have arrived at,
+// until released
+//
+// Flows find the hold in their context (see HoldToContext) and check
+// it every time before a flow node is to act on them.
+type Hold struct {
+	lock     sync.Mutex
+	released chan struct{}
+	// Enclosing hold, if any
+	parent *Hold
+}
+
+// NewHold creates a released hold
+func NewHold() *Hold {
+	released := make(chan struct{})
+	close(released)
+	return &Hold{released: released}
+}
+
+// NewNestedHold creates a released hold within a given enclosing
+// hold (if it isn't nil): flows are held while either of them holds
+// them, so that flows of a scope (such as a sub-process) can be held
+// without releasing those held by the enclosing scope
+func NewNestedHold(parent *Hold) *Hold {
+	hold := NewHold()
+	hold.parent = parent
+	return hold
+}
+
+// Hold starts holding flows. Flows that are already
+// acted upon by flow nodes will be held at the next ones.
+func (hold *Hold) Hold() {
+	hold.lock.Lock()
+	defer hold.lock.Unlock()
+	select {
+	case <-hold.released:
+		hold.released = make(chan struct{})
+	default:
+	}
+}
+
+// Release lets held flows continue
+func (hold *Hold) Release() {
+	hold.lock.Lock()
+	defer hold.lock.Unlock()
+	select {
+	case <-hold.released:
+	default:
+		close(hold.released)
+	}
+}
+
+// IsHeld returns true if flows are being held
+func (hold *Hold) IsHeld() bool {
+	select {
+	case <-hold.Released():
+		return false
+	default:
+		return true
+	}
+}
+
+// Released returns a channel that is closed once flows are not
+// held by the hold itself or, if they are not, by the enclosing hold
+//
+// Flows can still be held by the other hold once the channel
+// is closed, so they check IsHeld again.
+func (hold *Hold) Released() <-chan struct{} {
+	hold.lock.Lock()
+	released := hold.released
+	hold.lock.Unlock()
+	if hold.parent != nil {
+		select {
+		case <-released:
+			return hold.parent.Released()
+		default:
+		}
+	}
+	return released
+}
+
+type contextKey string
+
+func (c contextKey) String() string {
+	return "flow package context key " + string(c)
+}
+
+// HoldFromContext retrieves a Hold from a given context, if there's any
+func HoldFromContext(ctx context.Context) (hold *Hold, found bool) {
+	hold, found = ctx.Value(contextKey("hold")).(*Hold)
+	return
+}
+
+// HoldToContext saves Hold into a given context, returning a new one
+func HoldToContext(ctx context.Context, hold *Hold) context.Context {
+	return context.WithValue(ctx, contextKey("hold"), hold)
+}
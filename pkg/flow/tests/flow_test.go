@@ -20,6 +20,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	_ "bpxe.org/pkg/expression/expr"
+	_ "bpxe.org/pkg/expression/xpath"
 )
 
 var testCondExpr bpmn.Definitions
@@ -149,3 +150,42 @@ func TestCondDataObject(t *testing.T) {
 	t.Run("cond1o", test("cond1o", "a1"))
 	t.Run("cond2o", test("cond2o", "a2"))
 }
+
+var testCondExprUnregistered bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/condexpr_unregistered.bpmn", testdata, &testCondExprUnregistered)
+}
+
+// Conditions in languages without a registered engine are evaluated as XPath
+func TestUnregisteredLanguageFormalExpression(t *testing.T) {
+	processElement := (*testCondExprUnregistered.Processes())[0]
+	proc := process.New(&processElement, &testCondExprUnregistered)
+	if instance, err := proc.Instantiate(); err == nil {
+		traces := instance.Tracer.Subscribe()
+		err := instance.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case flow.CompletionTrace:
+				if id, present := trace.Node.Id(); present {
+					if *id == "end" {
+						// success!
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		instance.Tracer.Unsubscribe(traces)
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
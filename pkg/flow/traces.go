@@ -15,6 +15,8 @@ import (
 
 type NewFlowTrace struct {
 	FlowId id.Id
+	// Node the flow starts at
+	Node bpmn.FlowNodeInterface
 }
 
 func (t NewFlowTrace) TraceInterface() {}
@@ -54,3 +56,23 @@ type VisitTrace struct {
 }
 
 func (t VisitTrace) TraceInterface() {}
+
+// LinkTrace denotes that the flow has moved from a link throw
+// event straight to its target link catch event (see
+// flow_node.LinkAction), without going through a sequence flow
+type LinkTrace struct {
+	FlowId id.Id
+	Source bpmn.FlowNodeInterface
+	Target bpmn.FlowNodeInterface
+}
+
+func (t LinkTrace) TraceInterface() {}
+
+// HoldTrace denotes that the flow is being held at
+// a given flow node (see Hold)
+type HoldTrace struct {
+	FlowId id.Id
+	Node   bpmn.FlowNodeInterface
+}
+
+func (t HoldTrace) TraceInterface() {}
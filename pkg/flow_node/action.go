@@ -10,6 +10,7 @@ package flow_node
 
 import (
 	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
 	"bpxe.org/pkg/sequence_flow"
 )
 
@@ -52,3 +53,28 @@ func (action CompleteAction) action() {}
 type NoAction struct{}
 
 func (action NoAction) action() {}
+
+// ErrorAction signals that the activity has failed with a BPMN error
+// (identified by ErrorRef and carrying an optional Item), which is to be
+// caught by a matching error boundary event or raised to the enclosing scope
+type ErrorAction struct {
+	ErrorRef string
+	Item     data.Item
+}
+
+func (action ErrorAction) action() {}
+
+// CancelAction signals that the transaction has been cancelled (and
+// rolled back), which is to be caught by its cancel boundary event
+type CancelAction struct{}
+
+func (action CancelAction) action() {}
+
+// LinkAction signals that the flow is to move straight to
+// the Target flow node, without following any sequence flows
+// (used by link events)
+type LinkAction struct {
+	Target bpmn.FlowNodeInterface
+}
+
+func (action LinkAction) action() {}
@@ -0,0 +1,200 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package call_activity
+
+import (
+	"context"
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/tracing"
+)
+
+// Instance is a called process instance
+type Instance interface {
+	StartAll(ctx context.Context) error
+	WaitUntilComplete(ctx context.Context) bool
+}
+
+// ProcessInstantiator instantiates a process called by a call activity,
+// with tracer being the tracer its traces should be relayed to and
+// raiseError being the function errors not caught within the called
+// process should be raised through.
+//
+// It is supplied by the owner of the call activity (typically, a process)
+// as call activities can't instantiate processes on their own.
+type ProcessInstantiator func(ctx context.Context, element *bpmn.Process, tracer tracing.Tracer,
+	raiseError func(*event.ErrorEvent)) (Instance, error)
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	flow     flow_interface.T
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+type cancelMessage struct {
+	response chan bool
+}
+
+func (m cancelMessage) message() {}
+
+// CallActivity instantiates a called process (resolved from
+// `calledElement` within the same definitions) every time a token
+// arrives and continues the flow once that process instance is complete.
+type CallActivity struct {
+	*flow_node.Wiring
+	element       *bpmn.CallActivity
+	calledProcess *bpmn.Process
+	instantiator  ProcessInstantiator
+	runnerChannel chan message
+	cancel        context.CancelFunc
+}
+
+func NewCallActivity(ctx context.Context, element *bpmn.CallActivity,
+	instantiator ProcessInstantiator,
+) activity.Constructor {
+	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
+		if instantiator == nil {
+			err = errors.NotSupportedError{
+				What:   "call activity",
+				Reason: "no process instantiator was supplied",
+			}
+			return
+		}
+		calledElement, present := element.CalledElement()
+		if !present {
+			err = errors.InvalidArgumentError{
+				Expected: "call activity to have calledElement",
+				Actual:   element,
+			}
+			return
+		}
+		calledProcess, found := wiring.Definitions.FindBy(bpmn.ExactId(*calledElement).
+			And(bpmn.ElementType((*bpmn.Process)(nil))))
+		if !found {
+			err = errors.NotFoundError{
+				Expected: fmt.Sprintf("called process %s", *calledElement),
+			}
+			return
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		callActivity := &CallActivity{
+			Wiring:        wiring,
+			element:       element,
+			calledProcess: calledProcess.(*bpmn.Process),
+			instantiator:  instantiator,
+			runnerChannel: make(chan message, len(wiring.Incoming)*2+1),
+			cancel:        cancel,
+		}
+		sender := wiring.Tracer.RegisterSender()
+		go callActivity.runner(ctx, sender)
+		node = callActivity
+		return
+	}
+}
+
+func (node *CallActivity) runner(ctx context.Context, sender tracing.SenderHandle) {
+	defer sender.Done()
+
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case cancelMessage:
+				node.cancel()
+				m.response <- true
+			case nextActionMessage:
+				go node.call(ctx, m.flow, m.response)
+			default:
+			}
+		case <-ctx.Done():
+			node.Tracer.Trace(flow_node.CancellationTrace{Node: node.element})
+			return
+		}
+	}
+}
+
+// call instantiates and starts the called process and
+// responds once its instance is complete
+//
+// The first error not caught within the called process shuts its
+// instance down and fails the call activity with that error. So does
+// the abandonment of the execution the flow is at (see activity.Context),
+// but without any error.
+func (node *CallActivity) call(ctx context.Context, flow flow_interface.T, response chan flow_node.Action) {
+	ctx, cancel := activity.Context(ctx, flow)
+	defer cancel()
+	raised := make(chan *event.ErrorEvent, 1)
+	raiseError := func(ev *event.ErrorEvent) {
+		select {
+		case raised <- ev:
+		default:
+		}
+	}
+	instance, err := node.instantiator(ctx, node.calledProcess, node.Tracer, raiseError)
+	if err == nil {
+		err = instance.StartAll(ctx)
+	}
+	if err != nil {
+		node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		response <- flow_node.NoAction{}
+		return
+	}
+	completed := make(chan bool, 1)
+	go func() {
+		completed <- instance.WaitUntilComplete(ctx)
+	}()
+	select {
+	case ev := <-raised:
+		response <- errorAction(ev)
+	case complete := <-completed:
+		select {
+		// the error was raised right before the instance completed
+		case ev := <-raised:
+			response <- errorAction(ev)
+		default:
+			if complete {
+				response <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+			} else {
+				// cancelled
+				response <- flow_node.NoAction{}
+			}
+		}
+	}
+}
+
+func errorAction(ev *event.ErrorEvent) flow_node.ErrorAction {
+	return flow_node.ErrorAction{ErrorRef: *ev.ErrorRef(), Item: ev.Item()}
+}
+
+func (node *CallActivity) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{flow: flow, response: response}
+	return response
+}
+
+func (node *CallActivity) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
+
+func (node *CallActivity) Cancel() <-chan bool {
+	response := make(chan bool)
+	node.runnerChannel <- cancelMessage{response: response}
+	return response
+}
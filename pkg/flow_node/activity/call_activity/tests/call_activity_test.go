@@ -0,0 +1,207 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallActivity(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/call_activity.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+	if inst, err := proc.Instantiate(); err == nil {
+		traces := inst.Tracer.Subscribe()
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+		visited := make(map[string]bool)
+		relayed := false
+	loop:
+		for {
+			wrapped := <-traces
+			trace := tracing.Unwrap(wrapped)
+			switch trace := trace.(type) {
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					if *id == "calleeTask" {
+						// called process' traces are relayed to the caller's tracer
+						relayed = tracedByProcess(wrapped, "callee")
+					}
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, visited["calleeStart"])
+		assert.True(t, visited["calleeTask"])
+		assert.True(t, visited["calleeEnd"])
+		assert.True(t, relayed)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.True(t, inst.WaitUntilComplete(ctx))
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
+
+func TestCallActivityBoundaryEvent(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/call_activity_boundary.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+
+	// explicit tracer, as boundary events start listening
+	// as soon as the instance is instantiated
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+
+	if inst, err := proc.Instantiate(instance.WithTracer(tracer)); err == nil {
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+
+		// wait until both the boundary event and the catch
+		// event in the called process are listening
+		listening := make(map[string]bool)
+		for !(listening["sig1listener"] && listening["wait"]) {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case catch.ActiveListeningTrace:
+				if id, present := trace.Node.Id(); present {
+					listening[*id] = true
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+
+		_, err = inst.ConsumeEvent(event.NewSignalEvent("sig1"))
+		assert.Nil(t, err)
+
+		visited := make(map[string]bool)
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, visited["interrupted"])
+		assert.False(t, visited["uninterrupted"])
+		assert.False(t, visited["calleeEnd"])
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
+
+func TestCallActivityError(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/call_activity_error.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+
+	if inst, err := proc.Instantiate(instance.WithTracer(tracer)); err == nil {
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+
+		visited := make(map[string]bool)
+		caught := false
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case activity.ErrorCaughtTrace:
+				caught = true
+				assert.Equal(t, "err1", *trace.Error.ErrorRef())
+			case instance.UncaughtErrorTrace:
+				t.Fatalf("error should have been raised to the call activity: %#v", trace)
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, caught)
+		assert.True(t, visited["caught"])
+		assert.False(t, visited["completed"])
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
+
+// tracedByProcess returns true if the trace was wrapped by a process
+// with a given id
+func tracedByProcess(trace tracing.Trace, processId string) bool {
+	for {
+		if processTrace, ok := trace.(process.Trace); ok {
+			if id, present := processTrace.Process.Id(); present && *id == processId {
+				return true
+			}
+		}
+		wrapped, ok := trace.(tracing.WrappedTrace)
+		if !ok {
+			return false
+		}
+		trace = wrapped.Unwrap()
+	}
+}
@@ -10,11 +10,13 @@ package activity
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 
 	"bpxe.org/pkg/bpmn"
 	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
 	"bpxe.org/pkg/event"
 	"bpxe.org/pkg/flow"
 	"bpxe.org/pkg/flow/flow_interface"
@@ -35,15 +37,36 @@ type nextActionMessage struct {
 
 func (m nextActionMessage) message() {}
 
+// Harness runs an activity, attaching its boundary events
+//
+// If the activity has multi-instance or standard loop characteristics,
+// Harness executes it multiple times (see Iteration).
+//
+// Escalations raised within the activity (see flow_node.Wiring.RaiseEscalation)
+// are delivered to its escalation boundary events. Non-interrupting boundary
+// events keep listening after they have been triggered. Conditional boundary
+// events are triggered right away if their conditions are true once the
+// activity starts.
+//
+// If the activity has a compensation boundary event associated with
+// a compensation handler (an activity marked as isForCompensation),
+// every completion of the activity is registered with scope's
+// compensation (see flow_node.Wiring.Compensation).
 type Harness struct {
 	*flow_node.Wiring
-	element            bpmn.FlowNodeInterface
-	runnerChannel      chan message
-	activity           Activity
-	active             int32
-	cancellation       sync.Once
-	eventConsumers     []event.Consumer
-	eventConsumersLock sync.RWMutex
+	element             bpmn.FlowNodeInterface
+	runnerChannel       chan message
+	activity            Activity
+	boundaryEvents      []*bpmn.BoundaryEvent
+	boundaryCatchEvents []*catch.Node
+	compensationHandler bpmn.FlowNodeInterface
+	raiseEscalation     func(*event.EscalationEvent)
+	idGenerator         id.Generator
+	active              int32
+	cancellation        sync.Once
+	eventConsumers      []event.Consumer
+	eventConsumersLock  sync.RWMutex
+	itemAwareLocator    data.ItemAwareLocator
 }
 
 func (node *Harness) ConsumeEvent(ev event.Event) (result event.ConsumptionResult, err error) {
@@ -83,20 +106,50 @@ func NewHarness(ctx context.Context,
 
 	boundaryEvents := make([]*bpmn.BoundaryEvent, 0)
 
-	for i := range *wiring.Process.BoundaryEvents() {
-		boundaryEvent := &(*wiring.Process.BoundaryEvents())[i]
-		if *boundaryEvent.AttachedToRef() == wiring.FlowNodeId {
+	// Boundary events can be nested within sub-processes, so we're
+	// collecting them by walking the entire process (the predicate
+	// never matches to make sure the walk is exhaustive)
+	wiring.Process.FindBy(func(e bpmn.Element) bool {
+		if boundaryEvent, ok := e.(*bpmn.BoundaryEvent); ok &&
+			*boundaryEvent.AttachedToRef() == wiring.FlowNodeId {
 			boundaryEvents = append(boundaryEvents, boundaryEvent)
 		}
+		return false
+	})
+
+	// Compensation boundary events are not triggered by events
+	// but merely associate the activity with its compensation handler
+	var compensationHandler bpmn.FlowNodeInterface
+	for i := 0; i < len(boundaryEvents); {
+		if len(*boundaryEvents[i].CompensateEventDefinitions()) == 0 {
+			i++
+			continue
+		}
+		compensationHandler, err = findCompensationHandler(wiring.Process, boundaryEvents[i])
+		if err != nil {
+			return
+		}
+		boundaryEvents = append(boundaryEvents[:i], boundaryEvents[i+1:]...)
 	}
 
 	node = &Harness{
-		Wiring:        wiring,
-		element:       element,
-		runnerChannel: make(chan message, len(wiring.Incoming)*2+1),
-		activity:      activity,
+		Wiring:              wiring,
+		raiseEscalation:     wiring.RaiseEscalation,
+		element:             element,
+		runnerChannel:       make(chan message, len(wiring.Incoming)*2+1),
+		activity:            activity,
+		boundaryEvents:      boundaryEvents,
+		compensationHandler: compensationHandler,
+		idGenerator:         idGenerator,
+		itemAwareLocator:    itemAwareLocator,
 	}
 
+	// Escalations raised within the activity (by the activity itself or by
+	// flow nodes nested within it) are handled by the harness first. The
+	// activity shares its wiring with the harness and reads the hook only
+	// once it is running.
+	wiring.RaiseEscalation = node.escalate
+
 	err = node.EventEgress.RegisterEventConsumer(node)
 	if err != nil {
 		return
@@ -113,11 +166,14 @@ func NewHarness(ctx context.Context,
 		catchEventFlowNode.EventEgress = node
 
 		var catchEvent *catch.Node
-		catchEvent, err = catch.New(ctx, catchEventFlowNode, &boundaryEvent.CatchEvent)
+		catchEvent, err = catch.NewBoundary(ctx, catchEventFlowNode, boundaryEvent)
 		if err != nil {
 			return
 		} else {
+			node.boundaryCatchEvents = append(node.boundaryCatchEvents, catchEvent)
 			var actionTransformer flow_node.ActionTransformer
+			// listen starts a flow awaiting the boundary event
+			var listen func()
 			if boundaryEvent.CancelActivity() {
 				actionTransformer = func(sequenceFlowId *bpmn.IdRef, action flow_node.Action) flow_node.Action {
 					node.cancellation.Do(func() {
@@ -125,10 +181,23 @@ func NewHarness(ctx context.Context,
 					})
 					return action
 				}
+			} else {
+				// Non-interrupting boundary event doesn't cancel the activity
+				// and can be triggered again, so once it is triggered, another
+				// flow starts awaiting it
+				actionTransformer = func(sequenceFlowId *bpmn.IdRef, action flow_node.Action) flow_node.Action {
+					if _, ok := action.(flow_node.FlowAction); ok {
+						listen()
+					}
+					return action
+				}
+			}
+			listen = func() {
+				newFlow := flow.New(node.Definitions, catchEvent, node.Tracer,
+					node.FlowNodeMapping, node.FlowWaitGroup, idGenerator, actionTransformer, itemAwareLocator)
+				newFlow.Start(ctx)
 			}
-			newFlow := flow.New(node.Definitions, catchEvent, node.Tracer,
-				node.FlowNodeMapping, node.FlowWaitGroup, idGenerator, actionTransformer, itemAwareLocator)
-			newFlow.Start(ctx)
+			listen()
 		}
 	}
 	sender := node.Tracer.RegisterSender()
@@ -146,11 +215,39 @@ func (node *Harness) runner(ctx context.Context, sender tracing.SenderHandle) {
 			case nextActionMessage:
 				atomic.StoreInt32(&node.active, 1)
 				node.Tracer.Trace(ActiveBoundaryTrace{Start: true, Node: node.activity.Element()})
-				in := node.activity.NextAction(m.flow)
+				// Boundary conditions that became true while
+				// the activity was inactive are caught right away
+				for _, catchEvent := range node.boundaryCatchEvents {
+					catchEvent.CheckConditions()
+				}
+				var in chan flow_node.Action
+				if characteristics, present := multiInstanceLoopCharacteristics(node.activity); present {
+					in = node.multiInstance(ctx, m.flow, characteristics)
+				} else if characteristics, present := standardLoopCharacteristics(node.activity); present {
+					in = node.standardLoop(ctx, m.flow, characteristics)
+				} else {
+					in = node.activity.NextAction(m.flow)
+				}
 				out := make(chan flow_node.Action)
-				go func(ctx2 context.Context) {
+				go func(ctx context.Context) {
+					var action flow_node.Action
+					select {
+					case action = <-in:
+					case <-ctx.Done():
+						return
+					}
+					switch a := action.(type) {
+					case flow_node.ErrorAction:
+						node.handleError(a)
+						action = flow_node.NoAction{}
+					case flow_node.CancelAction:
+						node.handleCancel()
+						action = flow_node.NoAction{}
+					case flow_node.FlowAction:
+						node.registerCompensation()
+					}
 					select {
-					case out <- <-in:
+					case out <- action:
 						atomic.StoreInt32(&node.active, 0)
 						node.Tracer.Trace(ActiveBoundaryTrace{Start: false, Node: node.activity.Element()})
 					case <-ctx.Done():
@@ -167,6 +264,136 @@ func (node *Harness) runner(ctx context.Context, sender tracing.SenderHandle) {
 	}
 }
 
+// handleError delivers the error to a matching error boundary event,
+// if there's one. Otherwise, the error is raised to the enclosing scope.
+//
+// Must be called while the activity is still active so that
+// boundary events get the error forwarded to them.
+func (node *Harness) handleError(action flow_node.ErrorAction) {
+	ev := event.NewErrorEvent(action.ErrorRef, action.Item)
+	for _, boundaryEvent := range node.boundaryEvents {
+		definitions := boundaryEvent.ErrorEventDefinitions()
+		for i := range *definitions {
+			if ev.MatchesEventInstance(event.WrapEventDefinition(&(*definitions)[i])) {
+				node.Tracer.Trace(ErrorCaughtTrace{Node: node.element, Error: ev})
+				if _, err := node.ConsumeEvent(ev); err != nil {
+					node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+				}
+				return
+			}
+		}
+	}
+	if node.RaiseError != nil {
+		node.RaiseError(ev)
+	} else {
+		node.Tracer.Trace(tracing.ErrorTrace{Error: errors.NotSupportedError{
+			What:   fmt.Sprintf("error %s", action.ErrorRef),
+			Reason: "there is no enclosing scope to raise it to",
+		}})
+	}
+}
+
+// escalate delivers an escalation raised within the activity to a matching
+// escalation boundary event, if there's one. Otherwise, the escalation
+// is raised to the enclosing scope.
+//
+// Unlike errors, escalations don't end the activity, unless caught
+// by an interrupting boundary event.
+func (node *Harness) escalate(ev *event.EscalationEvent) {
+	for _, boundaryEvent := range node.boundaryEvents {
+		definitions := boundaryEvent.EscalationEventDefinitions()
+		for i := range *definitions {
+			if ev.MatchesEventInstance(event.WrapEventDefinition(&(*definitions)[i])) {
+				node.Tracer.Trace(EscalationCaughtTrace{Node: node.element, Escalation: ev})
+				if _, err := node.ConsumeEvent(ev); err != nil {
+					node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+				}
+				return
+			}
+		}
+	}
+	if node.raiseEscalation != nil {
+		node.raiseEscalation(ev)
+	}
+}
+
+// handleCancel delivers transaction's cancellation
+// to its cancel boundary event
+//
+// Must be called while the activity is still active so that
+// boundary events get the cancellation forwarded to them.
+func (node *Harness) handleCancel() {
+	for _, boundaryEvent := range node.boundaryEvents {
+		if len(*boundaryEvent.CancelEventDefinitions()) > 0 {
+			if _, err := node.ConsumeEvent(event.MakeCancelEvent()); err != nil {
+				node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+			}
+			return
+		}
+	}
+	node.Tracer.Trace(tracing.ErrorTrace{Error: errors.NotFoundError{
+		Expected: fmt.Sprintf("cancel boundary event attached to transaction %s", node.FlowNodeId),
+	}})
+}
+
+// findCompensationHandler returns the compensation handler associated
+// with a given compensation boundary event
+func findCompensationHandler(process *bpmn.Process,
+	boundaryEvent *bpmn.BoundaryEvent) (handler bpmn.FlowNodeInterface, err error) {
+	boundaryEventId, _ := boundaryEvent.Id()
+	// Associations can be nested within sub-processes, too
+	association, found := process.FindBy(func(e bpmn.Element) bool {
+		association, ok := e.(*bpmn.Association)
+		return ok && *association.SourceRef() == *boundaryEventId
+	})
+	if !found {
+		err = errors.NotFoundError{
+			Expected: fmt.Sprintf("association of compensation boundary event %s with its handler", *boundaryEventId),
+		}
+		return
+	}
+	targetRef := *association.(*bpmn.Association).TargetRef()
+	element, found := process.FindBy(bpmn.ExactId(targetRef))
+	if !found {
+		err = errors.NotFoundError{Expected: fmt.Sprintf("compensation handler %s", targetRef)}
+		return
+	}
+	activity, ok := element.(bpmn.ActivityInterface)
+	if !ok || !activity.IsForCompensation() {
+		err = errors.InvalidArgumentError{
+			Expected: fmt.Sprintf("compensation handler %s to be an activity marked as isForCompensation", targetRef),
+			Actual:   element,
+		}
+		return
+	}
+	handler = activity
+	return
+}
+
+// registerCompensation registers the completion of the activity with
+// scope's compensation, if the activity has a compensation handler
+//
+// The handler is run by starting a new flow at it.
+func (node *Harness) registerCompensation() {
+	if node.compensationHandler == nil || node.Compensation == nil {
+		return
+	}
+	node.Compensation.Register(node.element, func(ctx context.Context) {
+		handler, found := node.FlowNodeMapping.ResolveElementToFlowNode(node.compensationHandler)
+		if !found {
+			node.Tracer.Trace(tracing.ErrorTrace{Error: errors.NotFoundError{
+				Expected: fmt.Sprintf("flow node for compensation handler %#v", node.compensationHandler),
+			}})
+			return
+		}
+		var wg sync.WaitGroup
+		newFlow := flow.New(node.Definitions, handler, node.Tracer, node.FlowNodeMapping,
+			&wg, node.idGenerator, nil, node.itemAwareLocator)
+		newFlow.Start(ctx)
+		wg.Wait()
+	})
+}
+
 func (node *Harness) NextAction(flow flow_interface.T) chan flow_node.Action {
 	response := make(chan chan flow_node.Action)
 	node.runnerChannel <- nextActionMessage{flow: flow, response: response}
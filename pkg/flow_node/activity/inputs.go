@@ -0,0 +1,31 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package activity
+
+import (
+	stderrors "errors"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data/association"
+	"bpxe.org/pkg/flow_node"
+)
+
+// InputErrorAction returns an action that raises a BPMN error (see
+// association.MissingInputErrorRef) if a given error denotes missing
+// data inputs (see association.Inputs)
+func InputErrorAction(definitions *bpmn.Definitions, err error) (action flow_node.ErrorAction, ok bool) {
+	var missingInputError association.MissingInputError
+	if ok = stderrors.As(err, &missingInputError); ok {
+		action = flow_node.ErrorAction{
+			ErrorRef: association.MissingInputErrorRef(definitions),
+			Item:     missingInputError,
+		}
+	}
+	return
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package activity
+
+import (
+	"context"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/flow/flow_interface"
+)
+
+// Iteration is a flow at one of the executions of a multi-instance
+// or looping activity (see Harness)
+//
+// Activities bind their data through ItemAwareLocator, so that every
+// execution gets its own input and output data items. Service, script,
+// send and user tasks do so, and so do sub-processes for their inner
+// flow nodes; other activities only see the data items of the enclosing
+// scope.
+//
+// Executions of a multi-instance activity can be abandoned before they
+// complete, and activities learn about that through Context.
+type Iteration struct {
+	flow_interface.T
+	// Zero-based index of the execution
+	LoopCounter int
+	locator     *iterationScope
+	// Done once the execution is abandoned, nil if it never is
+	ctx context.Context
+}
+
+// Context returns a context of the execution a given flow is at: it is
+// done once either a given context is done or, if the flow is at one of
+// the executions of a multi-instance activity, the execution is abandoned
+// (for example, once the completion condition has been satisfied)
+//
+// The returned function must be called once the execution is over.
+func Context(ctx context.Context, flow flow_interface.T) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if iteration, ok := flow.(*Iteration); ok && iteration.ctx != nil {
+		go func() {
+			select {
+			case <-iteration.ctx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+// ItemAwareLocator returns a locator of iteration's data items if the flow
+// is at one of the executions of a multi-instance activity, or a given
+// locator otherwise
+func ItemAwareLocator(flow flow_interface.T, locator data.ItemAwareLocator) data.ItemAwareLocator {
+	if iteration, ok := flow.(*Iteration); ok {
+		return iteration.locator
+	}
+	return locator
+}
+
+// iterationScope makes iteration's data items (inputDataItem and
+// outputDataItem) available in addition to those of the enclosing scope
+type iterationScope struct {
+	parent data.ItemAwareLocator
+	ids    map[bpmn.Id]data.ItemAware
+	names  map[string]data.ItemAware
+}
+
+func newIterationScope(parent data.ItemAwareLocator) *iterationScope {
+	return &iterationScope{
+		parent: parent,
+		ids:    make(map[bpmn.Id]data.ItemAware),
+		names:  make(map[string]data.ItemAware),
+	}
+}
+
+func (scope *iterationScope) add(element bpmn.BaseElementInterface, name *string, present bool, itemAware data.ItemAware) {
+	if id, present := element.Id(); present {
+		scope.ids[*id] = itemAware
+	}
+	if present {
+		scope.names[*name] = itemAware
+	}
+}
+
+func (scope *iterationScope) FindItemAwareById(id bpmn.IdRef) (itemAware data.ItemAware, found bool) {
+	if itemAware, found = scope.ids[id]; !found {
+		itemAware, found = scope.parent.FindItemAwareById(id)
+	}
+	return
+}
+
+func (scope *iterationScope) FindItemAwareByName(name string) (itemAware data.ItemAware, found bool) {
+	if itemAware, found = scope.names[name]; !found {
+		itemAware, found = scope.parent.FindItemAwareByName(name)
+	}
+	return
+}
+
+func (scope *iterationScope) FindItemAwareLocatorByScope(name string) (itemAwareLocator data.ItemAwareLocator, found bool) {
+	if scoped, ok := scope.parent.(data.ScopedItemAwareLocator); ok {
+		itemAwareLocator, found = scoped.FindItemAwareLocatorByScope(name)
+	}
+	return
+}
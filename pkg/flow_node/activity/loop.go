@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package activity
+
+import (
+	"context"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/expression"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/tracing"
+)
+
+// standardLoopCharacteristics returns activity's standard
+// loop characteristics, if there are any
+func standardLoopCharacteristics(activity Activity) (
+	characteristics *bpmn.StandardLoopCharacteristics, present bool) {
+	if element, ok := activity.Element().(bpmn.ActivityInterface); ok {
+		characteristics, present = element.StandardLoopCharacteristics()
+	}
+	return
+}
+
+// standardLoop executes the activity repeatedly while its loop condition
+// holds and returns a channel that will receive the action to be taken
+// once the loop is over
+//
+// If testBefore is set, the condition is tested before every execution
+// (so the activity may not be executed at all), otherwise after it.
+// The loop is also over once the number of executions reaches
+// loopMaximum, if it's specified. Absent loop condition never holds.
+//
+// Condition has `loopCounter` (number of executions completed so far)
+// available to it.
+func (node *Harness) standardLoop(ctx context.Context, flow flow_interface.T,
+	characteristics *bpmn.StandardLoopCharacteristics) chan flow_node.Action {
+	out := make(chan flow_node.Action, 1)
+	go func() {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		action, err := node.loop(ctx, flow, characteristics)
+		if err != nil {
+			if ctx.Err() == nil {
+				node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+			}
+			action = flow_node.NoAction{}
+		}
+		out <- action
+	}()
+	return out
+}
+
+func (node *Harness) loop(ctx context.Context, flow flow_interface.T,
+	characteristics *bpmn.StandardLoopCharacteristics) (action flow_node.Action, err error) {
+	action = flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+	loopMaximum := -1
+	if maximum, present := characteristics.LoopMaximum(); present {
+		loopMaximum = int(maximum.Int64())
+	}
+	// holds tests the loop condition after a given number of executions
+	holds := func(loopCounter int) (bool, error) {
+		condition := characteristics.LoopCondition()
+		if condition.Expression == nil {
+			return false, nil
+		}
+		return expression.EvaluateCondition(ctx, node.Definitions, condition.Expression, node.itemAwareLocator,
+			map[string]interface{}{"loopCounter": loopCounter})
+	}
+
+	for loopCounter := 0; loopMaximum < 0 || loopCounter < loopMaximum; loopCounter++ {
+		if characteristics.TestBefore() || loopCounter > 0 {
+			var proceed bool
+			if proceed, err = holds(loopCounter); err != nil || !proceed {
+				return
+			}
+		}
+		iteration := &Iteration{
+			T:           flow,
+			LoopCounter: loopCounter,
+			locator:     newIterationScope(node.itemAwareLocator),
+		}
+		node.Tracer.Trace(IterationTrace{Node: node.element, LoopCounter: loopCounter})
+		select {
+		case action = <-node.activity.NextAction(iteration):
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+		if _, ok := action.(flow_node.FlowAction); !ok {
+			// Execution didn't complete normally (e.g. it has thrown
+			// an error), so the loop is over
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package activity
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/expression"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/tracing"
+)
+
+// multiInstanceLoopCharacteristics returns activity's multi-instance
+// loop characteristics, if there are any
+func multiInstanceLoopCharacteristics(activity Activity) (
+	characteristics *bpmn.MultiInstanceLoopCharacteristics, present bool) {
+	if element, ok := activity.Element().(bpmn.ActivityInterface); ok {
+		characteristics, present = element.MultiInstanceLoopCharacteristics()
+	}
+	return
+}
+
+// iterationResult is an action an execution of
+// a multi-instance activity has ended with
+type iterationResult struct {
+	iteration *Iteration
+	output    data.ItemAware
+	action    flow_node.Action
+}
+
+// multiInstance executes the activity as many times as its multi-instance
+// loop characteristics require (in sequence or in parallel) and returns
+// a channel that will receive the action to be taken once it is done
+//
+// Executions are started until either all of them complete or
+// the completion condition is satisfied. In the latter case, executions
+// of a parallel multi-instance activity that haven't completed yet are
+// abandoned: activities stop them (see Context), so that, for example,
+// their work items are cancelled.
+//
+// An execution that ends with anything but a flow action (for example,
+// one that has thrown an error or failed) ends the activity with that
+// action right away, abandoning the rest of executions the same way.
+// Output collection is not written then.
+func (node *Harness) multiInstance(ctx context.Context, flow flow_interface.T,
+	characteristics *bpmn.MultiInstanceLoopCharacteristics) chan flow_node.Action {
+	out := make(chan flow_node.Action, 1)
+	go func() {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		action, err := node.iterate(ctx, flow, characteristics)
+		if err != nil {
+			if ctx.Err() == nil {
+				node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+			}
+			action = flow_node.NoAction{}
+		}
+		out <- action
+	}()
+	return out
+}
+
+func (node *Harness) iterate(ctx context.Context, flow flow_interface.T,
+	characteristics *bpmn.MultiInstanceLoopCharacteristics) (action flow_node.Action, err error) {
+	inputs, err := node.multiInstanceInputs(ctx, characteristics)
+	if err != nil {
+		return
+	}
+	numberOfInstances := len(inputs)
+	action = flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+
+	// start starts an execution that will send its result to a given channel
+	start := func(loopCounter int, results chan iterationResult) {
+		iteration := &Iteration{
+			T:           flow,
+			LoopCounter: loopCounter,
+			locator:     newIterationScope(node.itemAwareLocator),
+			ctx:         ctx,
+		}
+		if inputDataItem, present := characteristics.InputDataItem(); present {
+			input := data.NewContainer(ctx, inputDataItem)
+			<-input.Put(ctx, inputs[loopCounter])
+			name, present := inputDataItem.Name()
+			iteration.locator.add(inputDataItem, name, present, input)
+		}
+		var output data.ItemAware
+		if outputDataItem, present := characteristics.OutputDataItem(); present {
+			output = data.NewContainer(ctx, outputDataItem)
+			name, present := outputDataItem.Name()
+			iteration.locator.add(outputDataItem, name, present, output)
+		}
+		node.Tracer.Trace(IterationTrace{Node: node.element, LoopCounter: loopCounter})
+		in := node.activity.NextAction(iteration)
+		go func() {
+			select {
+			case action := <-in:
+				results <- iterationResult{iteration: iteration, output: output, action: action}
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	completed := make([]iterationResult, 0, numberOfInstances)
+	// complete accounts for a completed execution and returns
+	// true if no more executions are to be awaited
+	complete := func(result iterationResult, active int) (done bool, err error) {
+		completed = append(completed, result)
+		if _, ok := result.action.(flow_node.FlowAction); !ok {
+			// Execution didn't complete normally (e.g. it has thrown
+			// an error or failed), so neither are the other ones awaited
+			action = result.action
+			done = true
+			return
+		}
+		if completionCondition, present := characteristics.CompletionCondition(); present {
+			done, err = expression.EvaluateCondition(ctx, node.Definitions, completionCondition.Expression, result.iteration.locator,
+				map[string]interface{}{
+					"loopCounter":                result.iteration.LoopCounter,
+					"numberOfInstances":          numberOfInstances,
+					"numberOfActiveInstances":    active,
+					"numberOfCompletedInstances": len(completed),
+				})
+		}
+		return
+	}
+
+	done := false
+	if characteristics.IsSequential() {
+		results := make(chan iterationResult, 1)
+		for loopCounter := 0; loopCounter < numberOfInstances && !done; loopCounter++ {
+			start(loopCounter, results)
+			select {
+			case result := <-results:
+				if done, err = complete(result, 0); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			}
+		}
+	} else {
+		results := make(chan iterationResult, numberOfInstances)
+		for loopCounter := 0; loopCounter < numberOfInstances; loopCounter++ {
+			start(loopCounter, results)
+		}
+		for active := numberOfInstances; active > 0 && !done; {
+			select {
+			case result := <-results:
+				active--
+				if done, err = complete(result, active); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			}
+		}
+	}
+
+	if _, ok := action.(flow_node.FlowAction); ok {
+		err = node.multiInstanceOutputs(ctx, characteristics, completed)
+	}
+	return
+}
+
+// multiInstanceInputs returns input data items of activity's executions:
+// either items of the input collection (loopDataInputRef) or,
+// if there's none, as many nil items as loopCardinality evaluates to
+func (node *Harness) multiInstanceInputs(ctx context.Context,
+	characteristics *bpmn.MultiInstanceLoopCharacteristics) (inputs []data.Item, err error) {
+	if loopDataInputRef, present := characteristics.LoopDataInputRef(); present {
+		itemAware, found := node.itemAwareLocator.FindItemAwareById(*loopDataInputRef)
+		if !found {
+			err = errors.NotFoundError{Expected: fmt.Sprintf("item aware element with ID %s", *loopDataInputRef)}
+			return
+		}
+		ch := itemAware.Get(ctx)
+		if ch == nil {
+			err = ctx.Err()
+			return
+		}
+		var collection data.Item
+		select {
+		case collection = <-ch:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+		inputs, err = items(ctx, collection)
+		return
+	}
+	if loopCardinality, present := characteristics.LoopCardinality(); present {
+		var result interface{}
+		result, err = expression.Evaluate(ctx, node.Definitions, loopCardinality.Expression, node.itemAwareLocator, nil)
+		if err != nil {
+			return
+		}
+		value := reflect.ValueOf(result)
+		var cardinality int
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			cardinality = int(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			cardinality = int(value.Uint())
+		case reflect.Float32, reflect.Float64:
+			cardinality = int(value.Float())
+		default:
+			err = errors.InvalidArgumentError{Expected: "loopCardinality to be a number", Actual: result}
+			return
+		}
+		if cardinality < 0 {
+			err = errors.InvalidArgumentError{Expected: "non-negative loopCardinality", Actual: cardinality}
+			return
+		}
+		inputs = make([]data.Item, cardinality)
+		return
+	}
+	err = errors.InvalidArgumentError{
+		Expected: fmt.Sprintf("multi-instance activity %s to have loopDataInputRef or loopCardinality",
+			node.FlowNodeId),
+		Actual: characteristics,
+	}
+	return
+}
+
+// items returns items of a collection, which is either
+// a data.Collection or a slice
+func items(ctx context.Context, collection data.Item) (result []data.Item, err error) {
+	result = make([]data.Item, 0)
+	switch c := collection.(type) {
+	case nil:
+	case data.SliceIterator:
+		result = append(result, c...)
+	case data.Collection:
+		iterator, _ := c.ItemIterator(ctx)
+		for item := range iterator {
+			result = append(result, item)
+		}
+	default:
+		value := reflect.ValueOf(collection)
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			err = errors.InvalidArgumentError{Expected: "input collection", Actual: collection}
+			return
+		}
+		for i := 0; i < value.Len(); i++ {
+			result = append(result, value.Index(i).Interface())
+		}
+	}
+	return
+}
+
+// multiInstanceOutputs writes output data items of completed executions
+// (ordered by their loop counters) to the output collection, if any
+func (node *Harness) multiInstanceOutputs(ctx context.Context,
+	characteristics *bpmn.MultiInstanceLoopCharacteristics, completed []iterationResult) (err error) {
+	loopDataOutputRef, present := characteristics.LoopDataOutputRef()
+	if !present {
+		return
+	}
+	itemAware, found := node.itemAwareLocator.FindItemAwareById(*loopDataOutputRef)
+	if !found {
+		err = errors.NotFoundError{Expected: fmt.Sprintf("item aware element with ID %s", *loopDataOutputRef)}
+		return
+	}
+	// Executions of a parallel multi-instance activity
+	// complete in an arbitrary order
+	sort.Slice(completed, func(i, j int) bool {
+		return completed[i].iteration.LoopCounter < completed[j].iteration.LoopCounter
+	})
+	outputs := make(data.SliceIterator, len(completed))
+	for i := range completed {
+		if completed[i].output == nil {
+			continue
+		}
+		ch := completed[i].output.Get(ctx)
+		if ch == nil {
+			err = ctx.Err()
+			return
+		}
+		select {
+		case outputs[i] = <-ch:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+	ch := itemAware.Put(ctx, outputs)
+	if ch == nil {
+		err = ctx.Err()
+		return
+	}
+	select {
+	case <-ch:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
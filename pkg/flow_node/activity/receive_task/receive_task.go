@@ -0,0 +1,218 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package receive_task
+
+import (
+	"context"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/data/association"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/tracing"
+)
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	flow     flow_interface.T
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+type eventMessage struct {
+	event *event.MessageEvent
+}
+
+func (m eventMessage) message() {}
+
+type cancelMessage struct {
+	response chan bool
+}
+
+func (m cancelMessage) message() {}
+
+// ReceiveTask waits for a message event matching its messageRef
+// (and operationRef, if specified) every time a token arrives.
+//
+// Every matching message completes the token that has been waiting
+// for the longest time. Messages that arrive when there are no
+// waiting tokens are discarded, unless the task is an instantiating
+// one, in which case the first such message is retained until the
+// token arrives (as the message that instantiated the process is
+// typically delivered before the flow reaches the task). Tokens of
+// abandoned executions of a multi-instance receive task stop waiting
+// (see activity.Context).
+type ReceiveTask struct {
+	*flow_node.Wiring
+	element            *bpmn.ReceiveTask
+	runnerChannel      chan message
+	itemAwareLocator   data.ItemAwareLocator
+	definitionInstance event.DefinitionInstance
+	cancel             context.CancelFunc
+}
+
+func NewReceiveTask(ctx context.Context, element *bpmn.ReceiveTask,
+	itemAwareLocator data.ItemAwareLocator) activity.Constructor {
+	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		receiveTask := &ReceiveTask{
+			Wiring:             wiring,
+			element:            element,
+			runnerChannel:      make(chan message, len(wiring.Incoming)*2+1),
+			itemAwareLocator:   itemAwareLocator,
+			definitionInstance: event.WrapEventDefinition(EventDefinition(element)),
+			cancel:             cancel,
+		}
+		err = wiring.EventEgress.RegisterEventConsumer(receiveTask)
+		if err != nil {
+			cancel()
+			return
+		}
+		go receiveTask.runner(ctx)
+		node = receiveTask
+		return
+	}
+}
+
+// EventDefinition returns a message event definition equivalent to
+// the receive task's messageRef and operationRef, for the purpose
+// of matching incoming message events
+func EventDefinition(element *bpmn.ReceiveTask) *bpmn.MessageEventDefinition {
+	definition := bpmn.DefaultMessageEventDefinition()
+	if messageRef, present := element.MessageRef(); present {
+		definition.SetMessageRef(messageRef)
+	}
+	if operationRef, present := element.OperationRef(); present {
+		definition.SetOperationRef(operationRef)
+	}
+	return &definition
+}
+
+// waitingToken is a token waiting for a message
+type waitingToken struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	response chan flow_node.Action
+}
+
+func (node *ReceiveTask) runner(ctx context.Context) {
+	waiting := make([]waitingToken, 0)
+	var pending *event.MessageEvent
+	retainable := node.element.Instantiate() && len(node.Incoming) == 0
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case cancelMessage:
+				for _, token := range waiting {
+					token.cancel()
+					token.response <- flow_node.NoAction{}
+				}
+				waiting = waiting[:0]
+				node.cancel()
+				m.response <- true
+			case nextActionMessage:
+				if pending != nil {
+					node.receive(ctx, pending, m.response)
+					pending = nil
+					continue
+				}
+				tokenCtx, cancel := activity.Context(ctx, m.flow)
+				waiting = append(waiting, waitingToken{ctx: tokenCtx, cancel: cancel, response: m.response})
+				node.Tracer.Trace(WaitingForMessageTrace{Node: node.element})
+			case eventMessage:
+				// Tokens of abandoned executions no longer wait
+				for len(waiting) > 0 && waiting[0].ctx.Err() != nil {
+					waiting[0].cancel()
+					waiting[0].response <- flow_node.NoAction{}
+					waiting = waiting[1:]
+				}
+				if len(waiting) > 0 {
+					token := waiting[0]
+					waiting = waiting[1:]
+					token.cancel()
+					node.receive(ctx, m.event, token.response)
+				} else if retainable {
+					pending = m.event
+					retainable = false
+				}
+			default:
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// receive writes message's item to the data output and
+// lets the token through
+func (node *ReceiveTask) receive(ctx context.Context, ev *event.MessageEvent,
+	response chan flow_node.Action) {
+	node.Tracer.Trace(MessageReceivedTrace{Node: node.element, Event: ev})
+	go func() {
+		err := association.Outputs(ctx, node.Definitions, node.element, node.itemAwareLocator, node.outputs(ev.Item()))
+		if err != nil {
+			node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+			response <- flow_node.NoAction{}
+			return
+		}
+		response <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+	}()
+}
+
+// outputs maps message's item to the only data output of the task,
+// if there's one
+func (node *ReceiveTask) outputs(item data.Item) (outputs map[string]data.Item) {
+	outputs = make(map[string]data.Item)
+	ioSpecification, present := node.element.IoSpecification()
+	if !present || len(*ioSpecification.DataOutputs()) != 1 {
+		return
+	}
+	dataOutput := &(*ioSpecification.DataOutputs())[0]
+	if name, present := dataOutput.Name(); present {
+		outputs[*name] = item
+	} else if id, present := dataOutput.Id(); present {
+		outputs[*id] = item
+	}
+	return
+}
+
+func (node *ReceiveTask) ConsumeEvent(ev event.Event) (result event.ConsumptionResult, err error) {
+	messageEvent, ok := ev.(*event.MessageEvent)
+	if !ok || !messageEvent.MatchesEventInstance(node.definitionInstance) {
+		result = event.Consumed
+		return
+	}
+	node.runnerChannel <- eventMessage{event: messageEvent}
+	result = event.Consumed
+	return
+}
+
+func (node *ReceiveTask) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{flow: flow, response: response}
+	return response
+}
+
+func (node *ReceiveTask) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
+
+func (node *ReceiveTask) Cancel() <-chan bool {
+	response := make(chan bool)
+	node.runnerChannel <- cancelMessage{response: response}
+	return response
+}
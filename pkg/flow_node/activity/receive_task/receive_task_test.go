@@ -0,0 +1,19 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package receive_task
+
+import (
+	"testing"
+
+	"bpxe.org/pkg/flow_node/activity"
+)
+
+func TestReceiveTaskInterface(t *testing.T) {
+	var _ activity.Activity = &ReceiveTask{}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity/receive_task"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+var instantiatingDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/receive_task.bpmn", testdata, &testDoc)
+	internal.LoadTestFile("testdata/instantiating_receive_task.bpmn", testdata, &instantiatingDoc)
+}
+
+// waitForVisit waits until a flow node with a given id is visited
+func waitForVisit(t *testing.T, traces chan tracing.Trace, id string) {
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case flow.VisitTrace:
+			if idPtr, present := trace.Node.Id(); present && *idPtr == id {
+				return
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
+
+func TestReceiveTask(t *testing.T) {
+	ctx := context.Background()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	m := model.New(&testDoc, model.WithContext(ctx), model.WithTracer(tracer))
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		if _, ok := trace.(receive_task.WaitingForMessageTrace); ok {
+			break
+		}
+		t.Logf("%#v", trace)
+	}
+
+	// Messages that don't match the task are ignored
+	_, err = m.ConsumeEvent(event.NewMessageEvent("orderPlaced", nil, 1))
+	require.Nil(t, err)
+	_, err = m.ConsumeEvent(event.NewMessageEvent("paymentReceived", nil, 100))
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		if trace, ok := trace.(receive_task.MessageReceivedTrace); ok {
+			assert.Equal(t, "paymentReceived", *trace.Event.MessageRef())
+			assert.Equal(t, 100, trace.Event.Item())
+			break
+		}
+		t.Logf("%#v", trace)
+	}
+	waitForVisit(t, traces, "end")
+
+	itemAware, found := inst.FindItemAwareByName("payment")
+	require.True(t, found)
+	assert.Equal(t, 100, <-itemAware.Get(ctx))
+}
+
+func TestInstantiatingReceiveTask(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	m := model.New(&instantiatingDoc, model.WithContext(ctx), model.WithTracer(tracer))
+	err := m.Run(ctx)
+	require.Nil(t, err)
+
+	_, err = m.ConsumeEvent(event.NewMessageEvent("orderPlaced", nil, "o-1"))
+	require.Nil(t, err)
+
+	received := false
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case receive_task.MessageReceivedTrace:
+			assert.Equal(t, "o-1", trace.Event.Item())
+			received = true
+		case flow.CeaseFlowTrace:
+			assert.True(t, received)
+			return
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
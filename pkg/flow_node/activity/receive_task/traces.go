@@ -0,0 +1,31 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package receive_task
+
+import (
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+)
+
+// WaitingForMessageTrace denotes a token that started
+// waiting for a message
+type WaitingForMessageTrace struct {
+	Node *bpmn.ReceiveTask
+}
+
+func (t WaitingForMessageTrace) TraceInterface() {}
+
+// MessageReceivedTrace denotes a message received by a receive task
+// on behalf of one of the waiting tokens
+type MessageReceivedTrace struct {
+	Node  *bpmn.ReceiveTask
+	Event *event.MessageEvent
+}
+
+func (t MessageReceivedTrace) TraceInterface() {}
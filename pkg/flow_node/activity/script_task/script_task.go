@@ -0,0 +1,213 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package script_task
+
+import (
+	"context"
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/data/association"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/expression"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/tracing"
+)
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	flow     flow_interface.T
+	locator  data.ItemAwareLocator
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+type cancelMessage struct {
+	response chan bool
+}
+
+func (m cancelMessage) message() {}
+
+// ScriptTask executes its script using an expression engine
+// registered for task's scriptFormat (or definitions' expressionLanguage,
+// if scriptFormat is not specified)
+//
+// Script is evaluated with task's data inputs (see association.Inputs)
+// and has access to the data objects through the engine. Script's result
+// is written back through task's data output associations: if the task
+// has a single data output, the result is assigned to it, otherwise
+// the result is expected to be a map keyed by data output names.
+type ScriptTask struct {
+	*flow_node.Wiring
+	element          *bpmn.ScriptTask
+	runnerChannel    chan message
+	itemAwareLocator data.ItemAwareLocator
+	cancel           context.CancelFunc
+}
+
+func NewScriptTask(ctx context.Context, element *bpmn.ScriptTask,
+	itemAwareLocator data.ItemAwareLocator) activity.Constructor {
+	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		scriptTask := &ScriptTask{
+			Wiring:           wiring,
+			element:          element,
+			runnerChannel:    make(chan message, len(wiring.Incoming)*2+1),
+			itemAwareLocator: itemAwareLocator,
+			cancel:           cancel,
+		}
+		go scriptTask.runner(ctx)
+		node = scriptTask
+		return
+	}
+}
+
+func (node *ScriptTask) runner(ctx context.Context) {
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case cancelMessage:
+				node.cancel()
+				m.response <- true
+			case nextActionMessage:
+				go func() {
+					ctx, cancel := activity.Context(ctx, m.flow)
+					defer cancel()
+					if err := node.execute(ctx, m.locator); err != nil {
+						if action, ok := activity.InputErrorAction(node.Definitions, err); ok {
+							m.response <- action
+							return
+						}
+						// Task was cancelled while the script was running
+						if ctx.Err() == nil {
+							node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+						}
+						m.response <- flow_node.NoAction{}
+						return
+					}
+					m.response <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+				}()
+			default:
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// execute evaluates the script and applies its result, binding
+// its data through a given locator
+func (node *ScriptTask) execute(ctx context.Context, locator data.ItemAwareLocator) (err error) {
+	var lang string
+	if scriptFormat, present := node.element.ScriptFormat(); present {
+		lang = *scriptFormat
+	} else {
+		lang = *node.Definitions.ExpressionLanguage()
+	}
+	var source string
+	if script, present := node.element.Script(); present {
+		source = script.TextPayloadField
+	}
+	engine, found := expression.LookupEngine(ctx, lang)
+	if !found {
+		err = errors.NotSupportedError{
+			What:   fmt.Sprintf("script format %s", lang),
+			Reason: "there is no expression engine registered for it",
+		}
+		return
+	}
+	var compiled *expression.Compiled
+	compiled, err = expression.CompileWith(engine, source, locator)
+	if err != nil {
+		return
+	}
+
+	var inputs map[string]data.Item
+	inputs, err = association.Inputs(ctx, node.Definitions, node.element, locator)
+	if err != nil {
+		return
+	}
+	env := make(map[string]interface{}, len(inputs))
+	for name, item := range inputs {
+		env[name] = item
+	}
+
+	var result expression.Result
+	result, err = compiled.Evaluate(env)
+	if err != nil {
+		return
+	}
+
+	var outputs map[string]data.Item
+	outputs, err = node.outputs(result)
+	if err != nil {
+		return
+	}
+	err = association.Outputs(ctx, node.Definitions, node.element, locator, outputs)
+	return
+}
+
+// outputs maps script's result to task's data outputs
+func (node *ScriptTask) outputs(result expression.Result) (outputs map[string]data.Item, err error) {
+	outputs = make(map[string]data.Item)
+	ioSpecification, present := node.element.IoSpecification()
+	if !present || len(*ioSpecification.DataOutputs()) == 0 {
+		return
+	}
+	dataOutputs := ioSpecification.DataOutputs()
+	if len(*dataOutputs) == 1 {
+		dataOutput := &(*dataOutputs)[0]
+		if name, present := dataOutput.Name(); present {
+			outputs[*name] = result
+		} else if id, present := dataOutput.Id(); present {
+			outputs[*id] = result
+		}
+		return
+	}
+	results, ok := result.(map[string]interface{})
+	if !ok {
+		err = errors.InvalidArgumentError{
+			Expected: fmt.Sprintf("script of %s to return a map of data outputs", node.FlowNodeId),
+			Actual:   result,
+		}
+		return
+	}
+	for name, item := range results {
+		outputs[name] = item
+	}
+	return
+}
+
+func (node *ScriptTask) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{
+		flow:     flow,
+		locator:  activity.ItemAwareLocator(flow, node.itemAwareLocator),
+		response: response,
+	}
+	return response
+}
+
+func (node *ScriptTask) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
+
+func (node *ScriptTask) Cancel() <-chan bool {
+	response := make(chan bool)
+	node.runnerChannel <- cancelMessage{response: response}
+	return response
+}
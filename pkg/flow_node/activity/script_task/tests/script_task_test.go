@@ -0,0 +1,98 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
+	_ "bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/script_task.bpmn", testdata, &testDoc)
+}
+
+func TestScriptTask(t *testing.T) {
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+
+	for name, value := range map[string]int{"a": 3, "b": 2} {
+		itemAware, found := inst.FindItemAwareByName(name)
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), value)
+	}
+
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "end" {
+				break loop
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	tracer.Unsubscribe(traces)
+
+	for name, expected := range map[string]int{"product": 6, "sum": 5, "difference": 1} {
+		itemAware, found := inst.FindItemAwareByName(name)
+		require.True(t, found)
+		assert.Equal(t, expected, <-itemAware.Get(context.Background()), name)
+	}
+}
+
+func TestScriptTaskUnsupportedFormat(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/script_task.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	scriptTask, found := processElement.FindBy(bpmn.ExactId("multiply"))
+	require.True(t, found)
+	scriptFormat := "unsupported"
+	scriptTask.(*bpmn.ScriptTask).SetScriptFormat(&scriptFormat)
+
+	proc := process.New(&processElement, &testDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		if trace, ok := trace.(tracing.ErrorTrace); ok {
+			assert.IsType(t, errors.NotSupportedError{}, trace.Error)
+			break
+		}
+	}
+	tracer.Unsubscribe(traces)
+}
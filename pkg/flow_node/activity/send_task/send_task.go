@@ -0,0 +1,155 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package send_task
+
+import (
+	"context"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/data/association"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/tracing"
+)
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	flow     flow_interface.T
+	locator  data.ItemAwareLocator
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+type cancelMessage struct {
+	response chan bool
+}
+
+func (m cancelMessage) message() {}
+
+// SendTask publishes a message event built from its messageRef
+// and operationRef through wiring's event ingress.
+//
+// If the task has exactly one data input, its item becomes message's
+// payload. With multiple data inputs, the payload is a map of input
+// items keyed the same way as in association.Inputs.
+type SendTask struct {
+	*flow_node.Wiring
+	element          *bpmn.SendTask
+	runnerChannel    chan message
+	itemAwareLocator data.ItemAwareLocator
+	cancel           context.CancelFunc
+}
+
+func NewSendTask(ctx context.Context, element *bpmn.SendTask,
+	itemAwareLocator data.ItemAwareLocator) activity.Constructor {
+	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		sendTask := &SendTask{
+			Wiring:           wiring,
+			element:          element,
+			runnerChannel:    make(chan message, len(wiring.Incoming)*2+1),
+			itemAwareLocator: itemAwareLocator,
+			cancel:           cancel,
+		}
+		go sendTask.runner(ctx)
+		node = sendTask
+		return
+	}
+}
+
+func (node *SendTask) runner(ctx context.Context) {
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case cancelMessage:
+				node.cancel()
+				m.response <- true
+			case nextActionMessage:
+				go func() {
+					ctx, cancel := activity.Context(ctx, m.flow)
+					defer cancel()
+					err := node.send(ctx, m.locator)
+					if err != nil {
+						if action, ok := activity.InputErrorAction(node.Definitions, err); ok {
+							m.response <- action
+							return
+						}
+						node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+						m.response <- flow_node.NoAction{}
+						return
+					}
+					m.response <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+				}()
+			default:
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// send builds the message event out of data inputs
+// bound through a given locator and publishes it
+func (node *SendTask) send(ctx context.Context, locator data.ItemAwareLocator) (err error) {
+	messageRef, present := node.element.MessageRef()
+	if !present {
+		err = errors.InvalidArgumentError{Expected: "send task to have messageRef", Actual: node.element}
+		return
+	}
+	operationRef, _ := node.element.OperationRef()
+	inputs, err := association.Inputs(ctx, node.Definitions, node.element, locator)
+	if err != nil {
+		return
+	}
+	var item data.Item
+	switch len(inputs) {
+	case 0:
+	case 1:
+		for _, input := range inputs {
+			item = input
+		}
+	default:
+		item = inputs
+	}
+	ev := event.NewMessageEvent(*messageRef, operationRef, item)
+	// Trace the message before publishing it so that
+	// the trace precedes any traces caused by receiving it
+	node.Tracer.Trace(MessageSentTrace{Node: node.element, Event: ev})
+	_, err = node.EventIngress.ConsumeEvent(ev)
+	return
+}
+
+func (node *SendTask) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{
+		flow:     flow,
+		locator:  activity.ItemAwareLocator(flow, node.itemAwareLocator),
+		response: response,
+	}
+	return response
+}
+
+func (node *SendTask) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
+
+func (node *SendTask) Cancel() <-chan bool {
+	response := make(chan bool)
+	node.runnerChannel <- cancelMessage{response: response}
+	return response
+}
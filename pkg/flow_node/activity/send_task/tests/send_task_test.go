@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity/receive_task"
+	"bpxe.org/pkg/flow_node/activity/send_task"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/send_task.bpmn", testdata, &testDoc)
+}
+
+func TestSendTask(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	m := model.New(&testDoc, model.WithContext(ctx), model.WithTracer(tracer))
+	err := m.Run(ctx)
+	require.Nil(t, err)
+
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "sender"
+	})
+	require.True(t, found)
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	itemAware, found := inst.FindItemAwareByName("order")
+	require.True(t, found)
+	<-itemAware.Put(ctx, "o-1")
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+
+	sent := false
+	received := false
+	visited := make(map[string]bool)
+	for !(visited["sent"] && visited["received"]) {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case send_task.MessageSentTrace:
+			assert.Equal(t, "orderPlaced", *trace.Event.MessageRef())
+			assert.Equal(t, "o-1", trace.Event.Item())
+			sent = true
+		case receive_task.MessageReceivedTrace:
+			assert.True(t, sent)
+			assert.Equal(t, "o-1", trace.Event.Item())
+			received = true
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	assert.True(t, received)
+}
@@ -0,0 +1,22 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package send_task
+
+import (
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+)
+
+// MessageSentTrace denotes a message published by a send task
+type MessageSentTrace struct {
+	Node  *bpmn.SendTask
+	Event *event.MessageEvent
+}
+
+func (t MessageSentTrace) TraceInterface() {}
@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package service_task
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+)
+
+// Handler implements service task's behaviour
+//
+// It receives service task's data inputs (keyed by data input names,
+// see association.Inputs) and returns data outputs (keyed by data output
+// names) that are written back through task's data output associations.
+//
+// To raise a BPMN error, handler should return Error.
+type Handler func(ctx context.Context, inputs map[string]data.Item) (outputs map[string]data.Item, err error)
+
+// Error is a BPMN error returned by a Handler
+type Error struct {
+	ErrorRef string
+	Item     data.Item
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("BPMN error %s", e.ErrorRef)
+}
+
+// Registry maps service task implementations to their handlers
+//
+// Service tasks are matched by their operationRef first and, if there
+// is no handler registered for it, by their implementation.
+type Registry struct {
+	lock     sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+// Register registers a handler for a given operationRef or implementation
+func (registry *Registry) Register(key string, handler Handler) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	registry.handlers[key] = handler
+}
+
+// Handler finds a handler for a given service task
+func (registry *Registry) Handler(element *bpmn.ServiceTask) (handler Handler, found bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	if operationRef, present := element.OperationRef(); present {
+		if handler, found = registry.handlers[*operationRef]; found {
+			return
+		}
+	}
+	handler, found = registry.handlers[string(*element.Implementation())]
+	return
+}
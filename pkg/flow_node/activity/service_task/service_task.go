@@ -0,0 +1,154 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package service_task
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/data/association"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/tracing"
+)
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	flow     flow_interface.T
+	locator  data.ItemAwareLocator
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+type cancelMessage struct {
+	response chan bool
+}
+
+func (m cancelMessage) message() {}
+
+// ServiceTask executes a Go handler found in the Registry
+//
+// Handler is looked up every time a token arrives, so handlers
+// can be registered after the task has been instantiated.
+type ServiceTask struct {
+	*flow_node.Wiring
+	element          *bpmn.ServiceTask
+	runnerChannel    chan message
+	registry         *Registry
+	itemAwareLocator data.ItemAwareLocator
+	cancel           context.CancelFunc
+}
+
+func NewServiceTask(ctx context.Context, element *bpmn.ServiceTask, registry *Registry,
+	itemAwareLocator data.ItemAwareLocator) activity.Constructor {
+	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		serviceTask := &ServiceTask{
+			Wiring:           wiring,
+			element:          element,
+			runnerChannel:    make(chan message, len(wiring.Incoming)*2+1),
+			registry:         registry,
+			itemAwareLocator: itemAwareLocator,
+			cancel:           cancel,
+		}
+		go serviceTask.runner(ctx)
+		node = serviceTask
+		return
+	}
+}
+
+func (node *ServiceTask) runner(ctx context.Context) {
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case cancelMessage:
+				node.cancel()
+				m.response <- true
+			case nextActionMessage:
+				go func() {
+					ctx, cancel := activity.Context(ctx, m.flow)
+					defer cancel()
+					m.response <- node.execute(ctx, m.locator)
+				}()
+			default:
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// execute runs the handler, binding its data through a given locator,
+// and returns the action to be taken
+func (node *ServiceTask) execute(ctx context.Context, locator data.ItemAwareLocator) flow_node.Action {
+	handler, found := node.registry.Handler(node.element)
+	if !found {
+		node.Tracer.Trace(tracing.ErrorTrace{Error: errors.NotFoundError{
+			Expected: fmt.Sprintf("handler for service task %s", node.FlowNodeId),
+		}})
+		return flow_node.NoAction{}
+	}
+	inputs, err := association.Inputs(ctx, node.Definitions, node.element, locator)
+	if err != nil {
+		if action, ok := activity.InputErrorAction(node.Definitions, err); ok {
+			return action
+		}
+		node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		return flow_node.NoAction{}
+	}
+	outputs, err := handler(ctx, inputs)
+	if err != nil {
+		var bpmnError Error
+		if stderrors.As(err, &bpmnError) {
+			return flow_node.ErrorAction{ErrorRef: bpmnError.ErrorRef, Item: bpmnError.Item}
+		}
+		// Task was cancelled while the handler was running
+		if ctx.Err() != nil {
+			return flow_node.NoAction{}
+		}
+		node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		return flow_node.NoAction{}
+	}
+	err = association.Outputs(ctx, node.Definitions, node.element, locator, outputs)
+	if err != nil {
+		node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		return flow_node.NoAction{}
+	}
+	return flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+}
+
+func (node *ServiceTask) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{
+		flow:     flow,
+		locator:  activity.ItemAwareLocator(flow, node.itemAwareLocator),
+		response: response,
+	}
+	return response
+}
+
+func (node *ServiceTask) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
+
+func (node *ServiceTask) Cancel() <-chan bool {
+	response := make(chan bool)
+	node.runnerChannel <- cancelMessage{response: response}
+	return response
+}
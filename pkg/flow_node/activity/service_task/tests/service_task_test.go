@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity/service_task"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/service_task.bpmn", testdata, &testDoc)
+}
+
+func add(ctx context.Context, inputs map[string]data.Item) (map[string]data.Item, error) {
+	x, y := inputs["x"].(int), inputs["y"].(int)
+	if x+y > 100 {
+		return nil, service_task.Error{ErrorRef: "overflow", Item: x + y}
+	}
+	return map[string]data.Item{"result": x + y}, nil
+}
+
+// run runs the process with a and b data objects set to given values
+// until it ends and returns visited flow nodes and the instance
+func run(t *testing.T, registry *service_task.Registry, a, b int) (map[string]bool, *instance.Instance) {
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc, process.WithServiceTaskRegistry(registry))
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+
+	for name, value := range map[string]int{"a": a, "b": b} {
+		itemAware, found := inst.FindItemAwareByName(name)
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), value)
+	}
+
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	visited := make(map[string]bool)
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+				if *id == "end" {
+					break loop
+				}
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	tracer.Unsubscribe(traces)
+	return visited, inst
+}
+
+func TestServiceTask(t *testing.T) {
+	registry := service_task.NewRegistry()
+	registry.Register("add", add)
+	visited, inst := run(t, registry, 1, 2)
+	assert.True(t, visited["completed"])
+	assert.False(t, visited["failed"])
+
+	itemAware, found := inst.FindItemAwareByName("sum")
+	require.True(t, found)
+	assert.Equal(t, 3, <-itemAware.Get(context.Background()))
+}
+
+func TestServiceTaskError(t *testing.T) {
+	registry := service_task.NewRegistry()
+	registry.Register("add", add)
+	visited, inst := run(t, registry, 100, 2)
+	assert.True(t, visited["failed"])
+	assert.False(t, visited["completed"])
+
+	itemAware, found := inst.FindItemAwareByName("sum")
+	require.True(t, found)
+	assert.Nil(t, <-itemAware.Get(context.Background()))
+}
+
+func TestServiceTaskMissingHandler(t *testing.T) {
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		if trace, ok := trace.(tracing.ErrorTrace); ok {
+			assert.IsType(t, errors.NotFoundError{}, trace.Error)
+			break
+		}
+	}
+	tracer.Unsubscribe(traces)
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package sub_process
+
+import (
+	"context"
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/id"
+)
+
+// scope holds data objects and data object references of a sub-process
+// execution, falling back to the enclosing scope's ItemAwareLocator
+// when an item aware element can't be found locally
+type scope struct {
+	element                    *bpmn.SubProcess
+	parent                     data.ItemAwareLocator
+	dataObjectsByName          map[string]data.ItemAware
+	dataObjects                map[bpmn.Id]data.ItemAware
+	dataObjectReferencesByName map[string]data.ItemAware
+	dataObjectReferences       map[bpmn.Id]data.ItemAware
+}
+
+func newScope(ctx context.Context, element *bpmn.SubProcess, parent data.ItemAwareLocator,
+	idGenerator id.Generator) (s *scope, err error) {
+	s = &scope{
+		element:                    element,
+		parent:                     parent,
+		dataObjectsByName:          make(map[string]data.ItemAware),
+		dataObjects:                make(map[bpmn.Id]data.ItemAware),
+		dataObjectReferencesByName: make(map[string]data.ItemAware),
+		dataObjectReferences:       make(map[bpmn.Id]data.ItemAware),
+	}
+
+	for i := range *element.DataObjects() {
+		dataObject := &(*element.DataObjects())[i]
+		var name string
+		if namePtr, present := dataObject.Name(); present {
+			name = *namePtr
+		} else {
+			name = idGenerator.New().String()
+		}
+		container := data.NewContainer(ctx, dataObject)
+		s.dataObjectsByName[name] = container
+		if idPtr, present := dataObject.Id(); present {
+			s.dataObjects[*idPtr] = container
+		}
+	}
+
+	for i := range *element.DataObjectReferences() {
+		dataObjectReference := &(*element.DataObjectReferences())[i]
+		var name string
+		if namePtr, present := dataObjectReference.Name(); present {
+			name = *namePtr
+		} else {
+			name = idGenerator.New().String()
+		}
+		dataObjPtr, present := dataObjectReference.DataObjectRef()
+		if !present {
+			err = errors.InvalidArgumentError{
+				Expected: "data object reference to have dataObjectRef",
+				Actual:   dataObjectReference,
+			}
+			return
+		}
+		// Data object references can refer to data objects
+		// of this or any enclosing scope
+		container, found := s.FindItemAwareById(*dataObjPtr)
+		if !found {
+			err = errors.NotFoundError{
+				Expected: fmt.Sprintf("data object with ID %s", *dataObjPtr),
+			}
+			return
+		}
+		s.dataObjectReferencesByName[name] = container
+		if idPtr, present := dataObjectReference.Id(); present {
+			s.dataObjectReferences[*idPtr] = container
+		}
+	}
+
+	return
+}
+
+func (s *scope) FindItemAwareById(id bpmn.IdRef) (itemAware data.ItemAware, found bool) {
+	if itemAware, found = s.dataObjects[id]; found {
+		return
+	}
+	if itemAware, found = s.dataObjectReferences[id]; found {
+		return
+	}
+	if s.parent != nil {
+		itemAware, found = s.parent.FindItemAwareById(id)
+	}
+	return
+}
+
+func (s *scope) FindItemAwareByName(name string) (itemAware data.ItemAware, found bool) {
+	if itemAware, found = s.dataObjectsByName[name]; found {
+		return
+	}
+	if itemAware, found = s.dataObjectReferencesByName[name]; found {
+		return
+	}
+	if s.parent != nil {
+		itemAware, found = s.parent.FindItemAwareByName(name)
+	}
+	return
+}
+
+func (s *scope) FindItemAwareLocatorByScope(name string) (itemAwareLocator data.ItemAwareLocator, found bool) {
+	if namePtr, present := s.element.Name(); present && *namePtr == name {
+		itemAwareLocator = s
+		found = true
+		return
+	}
+	if idPtr, present := s.element.Id(); present && *idPtr == name {
+		itemAwareLocator = s
+		found = true
+		return
+	}
+	if parent, ok := s.parent.(data.ScopedItemAwareLocator); ok {
+		itemAwareLocator, found = parent.FindItemAwareLocatorByScope(name)
+	}
+	return
+}
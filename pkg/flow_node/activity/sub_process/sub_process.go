@@ -0,0 +1,560 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package sub_process
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/compensation"
+	"bpxe.org/pkg/conditional"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/tracing"
+)
+
+// FlowNodesInstantiator instantiates all flow nodes contained within a given
+// container, using wiringMaker to produce wiring for every flow node,
+// and registers them in flowNodeMapping.
+//
+// It is supplied by the owner of the sub-process (typically, a process
+// instance) so that sub-processes can have the same set of flow nodes
+// supported as processes do, including nested sub-processes.
+type FlowNodesInstantiator func(
+	ctx context.Context,
+	container bpmn.FlowElementsContainerInterface,
+	wiringMaker func(*bpmn.FlowNode) (*flow_node.Wiring, error),
+	flowNodeMapping *flow_node.FlowNodeMapping,
+	itemAwareLocator data.ItemAwareLocator,
+) error
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	flow     flow_interface.T
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+type cancelMessage struct {
+	response chan bool
+}
+
+func (m cancelMessage) message() {}
+
+type completionMessage struct {
+	execution *execution
+}
+
+func (m completionMessage) message() {}
+
+type errorMessage struct {
+	execution *execution
+	event     *event.ErrorEvent
+}
+
+func (m errorMessage) message() {}
+
+type transactionCancelMessage struct {
+	execution *execution
+}
+
+func (m transactionCancelMessage) message() {}
+
+type compensationMessage struct {
+	execution *execution
+}
+
+func (m compensationMessage) message() {}
+
+type abandonmentMessage struct {
+	token *token
+}
+
+func (m abandonmentMessage) message() {}
+
+// token is a flow that has arrived at the sub-process
+type token struct {
+	response chan flow_node.Action
+	// data items the execution's inner scope sees in addition
+	// to its own (see activity.ItemAwareLocator)
+	locator data.ItemAwareLocator
+	// stops watching for the abandonment of the execution
+	// the flow is at (see activity.Context)
+	cancel context.CancelFunc
+}
+
+// respond sends the action to be taken to the flow
+func (t *token) respond(action flow_node.Action) {
+	t.cancel()
+	t.response <- action
+}
+
+// execution represents a single run of sub-process' inner scope
+type execution struct {
+	token     *token
+	cancel    context.CancelFunc
+	cancelled bool
+	// holds inner flows while inner activities are being compensated
+	hold *flow.Hold
+	// error raised within the execution, if any
+	error *event.ErrorEvent
+	// completed inner activities that can be compensated
+	compensation *compensation.Scope
+	// true while inner activities are being compensated, the execution
+	// can't complete until then
+	compensating bool
+	// functions to call once the compensation is done
+	compensated []func()
+	// true if inner tokens are gone while compensating
+	drained bool
+	// true if the transaction has been cancelled by
+	// one of its cancel end events
+	rolledBack bool
+}
+
+// SubProcess is an embedded sub-process activity
+//
+// Every time a token arrives, SubProcess instantiates its inner flow nodes
+// and data objects anew, starts its start event(s) and tracks inner tokens
+// with its own wait group. Once there are no tokens left in the inner scope,
+// the sub-process completes and the token continues through outgoing sequence
+// flows. Tokens arriving while the sub-process is running are queued up.
+//
+// Executions of a multi-instance sub-process see their iteration's data
+// items (see activity.Iteration). As executions run one at a time, those
+// of a parallel multi-instance sub-process are queued up, too. Abandoned
+// executions are dropped if they haven't started yet, or cancelled
+// otherwise.
+//
+// Cancelling the sub-process compensates inner activities completed
+// during the current execution (see compensation.Scope) first, while
+// the rest of the execution's flows are held. Once the sub-process has
+// completed, its inner activities are compensated when the sub-process
+// itself is (unless it has a compensation handler of its own).
+type SubProcess struct {
+	*flow_node.Wiring
+	element            *bpmn.SubProcess
+	transaction        *bpmn.Transaction
+	runnerChannel      chan message
+	idGenerator        id.Generator
+	itemAwareLocator   data.ItemAwareLocator
+	instantiator       FlowNodesInstantiator
+	current            *execution
+	pending            []*token
+	eventConsumersLock sync.RWMutex
+	eventConsumers     []event.Consumer
+}
+
+func NewSubProcess(ctx context.Context, element *bpmn.SubProcess,
+	idGenerator id.Generator, itemAwareLocator data.ItemAwareLocator,
+	instantiator FlowNodesInstantiator,
+) activity.Constructor {
+	return newSubProcess(ctx, element, nil, idGenerator, itemAwareLocator, instantiator)
+}
+
+// NewTransaction creates a transaction sub-process
+//
+// Transaction is a SubProcess that can be cancelled by its cancel end
+// events. Cancellation compensates inner activities completed during
+// the current execution, after which the transaction is left through
+// its cancel boundary event (see flow_node.CancelAction). Only the
+// ##Compensate transaction method is supported.
+func NewTransaction(ctx context.Context, element *bpmn.Transaction,
+	idGenerator id.Generator, itemAwareLocator data.ItemAwareLocator,
+	instantiator FlowNodesInstantiator,
+) activity.Constructor {
+	return newSubProcess(ctx, &element.SubProcess, element, idGenerator, itemAwareLocator, instantiator)
+}
+
+func newSubProcess(ctx context.Context, element *bpmn.SubProcess, transaction *bpmn.Transaction,
+	idGenerator id.Generator, itemAwareLocator data.ItemAwareLocator,
+	instantiator FlowNodesInstantiator,
+) activity.Constructor {
+	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
+		if transaction != nil {
+			if method := *transaction.Method(); method != "##Compensate" {
+				err = errors.NotSupportedError{
+					What:   fmt.Sprintf("transaction method %s", method),
+					Reason: "only ##Compensate is supported",
+				}
+				return
+			}
+		}
+		subProcess := &SubProcess{
+			Wiring:           wiring,
+			element:          element,
+			transaction:      transaction,
+			runnerChannel:    make(chan message, len(wiring.Incoming)*2+1),
+			idGenerator:      idGenerator,
+			itemAwareLocator: itemAwareLocator,
+			instantiator:     instantiator,
+			pending:          make([]*token, 0),
+		}
+		// Sub-process becomes event egress for its inner flow nodes
+		err = wiring.EventEgress.RegisterEventConsumer(subProcess)
+		if err != nil {
+			return
+		}
+		sender := wiring.Tracer.RegisterSender()
+		go subProcess.runner(ctx, sender)
+		node = subProcess
+		return
+	}
+}
+
+func (node *SubProcess) ConsumeEvent(ev event.Event) (result event.ConsumptionResult, err error) {
+	node.eventConsumersLock.RLock()
+	// We're copying the list of consumers here to ensure that
+	// new consumers can subscribe during event forwarding
+	eventConsumers := node.eventConsumers
+	node.eventConsumersLock.RUnlock()
+	result, err = event.ForwardEvent(ev, &eventConsumers)
+	return
+}
+
+func (node *SubProcess) RegisterEventConsumer(consumer event.Consumer) (err error) {
+	node.eventConsumersLock.Lock()
+	defer node.eventConsumersLock.Unlock()
+	node.eventConsumers = append(node.eventConsumers, consumer)
+	return
+}
+
+func (node *SubProcess) runner(ctx context.Context, sender tracing.SenderHandle) {
+	defer sender.Done()
+
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case nextActionMessage:
+				token := node.newToken(ctx, m)
+				if node.current == nil {
+					node.execute(ctx, token)
+				} else {
+					node.pending = append(node.pending, token)
+				}
+			case completionMessage:
+				if m.execution.compensating {
+					m.execution.drained = true
+					continue
+				}
+				node.finish(ctx, m.execution)
+			case compensationMessage:
+				m.execution.compensating = false
+				m.execution.cancel()
+				for _, f := range m.execution.compensated {
+					f()
+				}
+				m.execution.compensated = nil
+				if m.execution.drained {
+					node.finish(ctx, m.execution)
+				}
+			case cancelMessage:
+				// Cancel queued up tokens
+				for _, token := range node.pending {
+					token.respond(flow_node.NoAction{})
+				}
+				node.pending = make([]*token, 0)
+				// and the current execution, if any (it'll be
+				// completed once its inner tokens are gone)
+				if node.current != nil {
+					node.current.cancelled = true
+					node.compensate(ctx, node.current, func() { m.response <- true })
+					continue
+				}
+				m.response <- true
+			case transactionCancelMessage:
+				if m.execution == node.current && m.execution.error == nil &&
+					!m.execution.cancelled && !m.execution.rolledBack {
+					m.execution.rolledBack = true
+					node.compensate(ctx, m.execution, nil)
+				}
+			case errorMessage:
+				// Only the first error raised within the execution counts,
+				// the rest of the execution is shut down
+				if m.execution == node.current && m.execution.error == nil &&
+					!m.execution.cancelled && !m.execution.rolledBack {
+					m.execution.error = m.event
+					m.execution.cancel()
+				}
+			case abandonmentMessage:
+				for i, token := range node.pending {
+					if token == m.token {
+						node.pending = append(node.pending[:i], node.pending[i+1:]...)
+						token.respond(flow_node.NoAction{})
+						break
+					}
+				}
+				// The execution is shut down (once the compensation
+				// in progress, if any, is done)
+				if current := node.current; current != nil && current.token == m.token &&
+					!current.cancelled {
+					current.cancelled = true
+					if !current.compensating {
+						current.cancel()
+					}
+				}
+			default:
+			}
+		case <-ctx.Done():
+			node.Tracer.Trace(flow_node.CancellationTrace{Node: node.Element()})
+			return
+		}
+	}
+}
+
+// compensate compensates inner activities completed during the execution
+// and then cancels it, calling a given function (unless it is nil) once done
+//
+// Inner flows are held in the meantime so that no more inner activities
+// complete. The runner is not blocked while compensation handlers run
+// as they may need it.
+func (node *SubProcess) compensate(ctx context.Context, current *execution, done func()) {
+	if done != nil {
+		current.compensated = append(current.compensated, done)
+	}
+	if current.compensating {
+		return
+	}
+	current.compensating = true
+	current.hold.Hold()
+	go func() {
+		current.compensation.Compensate(ctx, nil)
+		select {
+		case node.runnerChannel <- compensationMessage{execution: current}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// newToken accepts a flow that has arrived at the sub-process and watches
+// for the abandonment of the execution it is at, if it ever happens
+func (node *SubProcess) newToken(ctx context.Context, m nextActionMessage) *token {
+	abandonment, cancel := activity.Context(ctx, m.flow)
+	t := &token{
+		response: m.response,
+		locator:  activity.ItemAwareLocator(m.flow, node.itemAwareLocator),
+		cancel:   cancel,
+	}
+	go func() {
+		<-abandonment.Done()
+		// Tokens that have been responded to are ignored by the runner
+		select {
+		case node.runnerChannel <- abandonmentMessage{token: t}:
+		case <-ctx.Done():
+		}
+	}()
+	return t
+}
+
+// finish completes the execution and starts
+// the next one, if there are tokens queued up
+func (node *SubProcess) finish(ctx context.Context, current *execution) {
+	node.complete(current)
+	if node.current == nil && len(node.pending) > 0 {
+		token := node.pending[0]
+		node.pending = node.pending[1:]
+		node.execute(ctx, token)
+	}
+}
+
+// execute starts a new execution of the inner scope
+func (node *SubProcess) execute(ctx context.Context, token *token) {
+	executionCtx, cancel := context.WithCancel(ctx)
+	hold, _ := flow.HoldFromContext(ctx)
+	current := &execution{
+		token:        token,
+		cancel:       cancel,
+		hold:         flow.NewNestedHold(hold),
+		compensation: compensation.NewScope(node.Tracer),
+	}
+	executionCtx = flow.HoldToContext(executionCtx, current.hold)
+	node.current = current
+	var wg sync.WaitGroup
+	if err := node.start(executionCtx, current, &wg); err != nil {
+		node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		current.cancelled = true
+	}
+	go func() {
+		wg.Wait()
+		select {
+		case node.runnerChannel <- completionMessage{execution: current}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// start instantiates inner scope's data objects and flow nodes
+// and starts flows from its start event(s)
+func (node *SubProcess) start(ctx context.Context, current *execution, wg *sync.WaitGroup) (err error) {
+	var locator *scope
+	locator, err = newScope(ctx, node.element, current.token.locator, node.idGenerator)
+	if err != nil {
+		return
+	}
+
+	flowNodeMapping := flow_node.NewLockedFlowNodeMapping()
+	wiringMaker := func(element *bpmn.FlowNode) (wiring *flow_node.Wiring, err error) {
+		wiring, err = node.Wiring.CloneFor(element)
+		if err != nil {
+			return
+		}
+		wiring.EventEgress = node
+		wiring.FlowNodeMapping = flowNodeMapping
+		wiring.FlowWaitGroup = wg
+		// Terminate end events only terminate the current execution,
+		// after which the sub-process completes normally
+		wiring.TerminateScope = current.cancel
+		// Errors not caught within the sub-process end its execution
+		// and are thrown by the sub-process itself
+		wiring.RaiseError = func(ev *event.ErrorEvent) {
+			select {
+			case node.runnerChannel <- errorMessage{execution: current, event: ev}:
+			case <-ctx.Done():
+			}
+		}
+		wiring.Compensation = current.compensation
+		// Conditional events within the sub-process are evaluated
+		// against its own data (as well as that of enclosing scopes)
+		// for as long as the execution lasts
+		wiring.EventDefinitionInstanceBuilder = event.DefinitionInstanceBuildingChain(
+			conditional.EventDefinitionInstanceBuilder(ctx, node, node.Tracer,
+				node.Definitions, locator),
+			node.Wiring.EventDefinitionInstanceBuilder,
+		)
+		// Cancel end events are only allowed directly within transactions
+		wiring.CancelTransaction = nil
+		if node.transaction != nil {
+			wiring.CancelTransaction = func() {
+				select {
+				case node.runnerChannel <- transactionCancelMessage{execution: current}:
+				case <-ctx.Done():
+				}
+			}
+		}
+		return
+	}
+	err = node.instantiator(ctx, node.element, wiringMaker, flowNodeMapping, locator)
+	flowNodeMapping.Finalize()
+	if err != nil {
+		return
+	}
+
+	startEvents := node.element.StartEvents()
+	if len(*startEvents) == 0 {
+		err = errors.NotFoundError{
+			Expected: fmt.Sprintf("start event in sub-process %s", node.FlowNodeId),
+		}
+		return
+	}
+
+	for i := range *startEvents {
+		startEvent := &(*startEvents)[i]
+		startEventNode, found := flowNodeMapping.ResolveElementToFlowNode(startEvent)
+		if !found {
+			err = errors.NotFoundError{
+				Expected: fmt.Sprintf("flow node for start event %#v", startEvent),
+			}
+			return
+		}
+		// We're starting the flow directly (as opposed to triggering the
+		// start event) to make sure it is accounted for in the wait group
+		// before we start waiting on it
+		newFlow := flow.New(node.Definitions, startEventNode, node.Tracer,
+			flowNodeMapping, wg, node.idGenerator, nil, locator)
+		newFlow.Start(ctx)
+	}
+	return
+}
+
+// complete finalizes an execution once there are no inner tokens left
+func (node *SubProcess) complete(current *execution) {
+	// Stop forwarding events to inner flow nodes before shutting them down
+	node.eventConsumersLock.Lock()
+	node.eventConsumers = nil
+	node.eventConsumersLock.Unlock()
+	node.current = nil
+	completed := !current.cancelled && current.error == nil && !current.rolledBack
+	if completed && !current.compensation.IsEmpty() && node.Compensation != nil &&
+		!node.hasCompensationHandler() {
+		// Inner activities completed during the execution are compensated
+		// once the sub-process is, so inner flow nodes (including their
+		// compensation handlers) are only shut down after that
+		node.Compensation.Register(node.Element(), func(ctx context.Context) {
+			defer current.cancel()
+			current.compensation.Compensate(ctx, nil)
+		})
+	} else {
+		current.cancel()
+	}
+
+	if current.cancelled {
+		current.token.respond(flow_node.NoAction{})
+	} else if current.error != nil {
+		current.token.respond(flow_node.ErrorAction{
+			ErrorRef: *current.error.ErrorRef(),
+			Item:     current.error.Item(),
+		})
+	} else if current.rolledBack {
+		node.Tracer.Trace(RollbackTrace{Transaction: node.transaction})
+		current.token.respond(flow_node.CancelAction{})
+	} else {
+		node.Tracer.Trace(flow.CompletionTrace{Node: node.Element()})
+		current.token.respond(flow_node.FlowAction{
+			SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing),
+		})
+	}
+}
+
+// hasCompensationHandler returns true if the sub-process has a compensation
+// boundary event (which associates it with its own compensation handler)
+func (node *SubProcess) hasCompensationHandler() bool {
+	_, found := node.Process.FindBy(func(e bpmn.Element) bool {
+		boundaryEvent, ok := e.(*bpmn.BoundaryEvent)
+		return ok && *boundaryEvent.AttachedToRef() == node.FlowNodeId &&
+			len(*boundaryEvent.CompensateEventDefinitions()) > 0
+	})
+	return found
+}
+
+// NextAction queues up an execution of the inner scope
+//
+// The response channel is buffered, so that the runner never blocks
+// responding once the token is no longer awaited (for example, because
+// the enclosing scope is being shut down).
+func (node *SubProcess) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{flow: flow, response: response}
+	return response
+}
+
+func (node *SubProcess) Element() bpmn.FlowNodeInterface {
+	if node.transaction != nil {
+		return node.transaction
+	}
+	return node.element
+}
+
+func (node *SubProcess) Cancel() <-chan bool {
+	response := make(chan bool)
+	node.runnerChannel <- cancelMessage{response: response}
+	return response
+}
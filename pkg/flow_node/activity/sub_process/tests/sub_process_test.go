@@ -0,0 +1,233 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+	_ "bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubProcess(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/sub_process.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+	if inst, err := proc.Instantiate(); err == nil {
+		traces := inst.Tracer.Subscribe()
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+		visited := make(map[string]bool)
+		completed := false
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case flow.CompletionTrace:
+				if id, present := trace.Node.Id(); present && *id == "sub" {
+					completed = true
+				}
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, visited["subStart"])
+		assert.True(t, visited["subTask"])
+		assert.True(t, visited["subEnd"])
+		assert.True(t, completed)
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
+
+func TestSubProcessBoundaryEvent(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/sub_process_boundary.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+
+	// explicit tracer, as boundary events start listening
+	// as soon as the instance is instantiated
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+
+	if inst, err := proc.Instantiate(instance.WithTracer(tracer)); err == nil {
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+
+		// wait until both the boundary event and the inner
+		// catch event are listening
+		listening := make(map[string]bool)
+		for !(listening["sig1listener"] && listening["wait"]) {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case catch.ActiveListeningTrace:
+				if id, present := trace.Node.Id(); present {
+					listening[*id] = true
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+
+		_, err = inst.ConsumeEvent(event.NewSignalEvent("sig1"))
+		assert.Nil(t, err)
+
+		visited := make(map[string]bool)
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, visited["interrupted"])
+		assert.False(t, visited["uninterrupted"])
+		assert.False(t, visited["subEnd"])
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
+
+func TestSubProcessError(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/sub_process_error.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+
+	if inst, err := proc.Instantiate(instance.WithTracer(tracer)); err == nil {
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+
+		visited := make(map[string]bool)
+		caught := false
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case activity.ErrorCaughtTrace:
+				if id, present := trace.Node.Id(); present && *id == "sub" {
+					assert.Equal(t, "err1", *trace.Error.ErrorRef())
+					caught = true
+				}
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, caught)
+		assert.True(t, visited["subEnd"])
+		assert.True(t, visited["interrupted"])
+		assert.False(t, visited["uninterrupted"])
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
+
+func TestSubProcessEnclosingDataObject(t *testing.T) {
+	testSubProcessEnclosingDataObject(t, true, "a1", "a2")
+	testSubProcessEnclosingDataObject(t, false, "a2", "a1")
+}
+
+func testSubProcessEnclosingDataObject(t *testing.T, cond bool, expected, unexpected string) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/sub_process_data_object.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+	if inst, err := proc.Instantiate(); err == nil {
+		traces := inst.Tracer.Subscribe()
+		itemAware, found := inst.FindItemAwareByName("cond")
+		require.True(t, found)
+		itemAware.Put(context.Background(), cond)
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+		visited := make(map[string]bool)
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, visited[expected])
+		assert.False(t, visited[unexpected])
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
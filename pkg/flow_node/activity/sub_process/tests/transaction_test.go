@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/internal/run"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/compensation"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity/sub_process"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionCancellation(t *testing.T) {
+	var doc bpmn.Definitions
+	internal.LoadTestFile("testdata/transaction.bpmn", testdata, &doc)
+	proc := process.New(&(*doc.Processes())[0], &doc)
+	compensated, rolledBack := make([]string, 0), make([]string, 0)
+	visited := make(map[string]bool)
+	run.Until(t, proc, "end", nil, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case compensation.CompensationTrace:
+			id, _ := trace.Activity.Id()
+			compensated = append(compensated, *id)
+		case sub_process.RollbackTrace:
+			id, _ := trace.Transaction.Id()
+			rolledBack = append(rolledBack, *id)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+			}
+		}
+	})
+	assert.Equal(t, []string{"bookFlight", "bookHotel"}, compensated)
+	assert.Equal(t, []string{"booking"}, rolledBack)
+	assert.True(t, visited["cancelHotel"])
+	assert.True(t, visited["cancelFlight"])
+	assert.True(t, visited["cancelled"])
+	assert.False(t, visited["completed"])
+}
+
+func TestTransactionCompletion(t *testing.T) {
+	var doc bpmn.Definitions
+	internal.LoadTestFile("testdata/transaction.bpmn", testdata, &doc)
+	endEvent, found := doc.FindBy(bpmn.ExactId("bookingEnd"))
+	require.True(t, found)
+	cancelEventDefinitions := endEvent.(*bpmn.EndEvent).CancelEventDefinitions()
+	*cancelEventDefinitions = (*cancelEventDefinitions)[:0]
+	proc := process.New(&(*doc.Processes())[0], &doc)
+	compensated, rolledBack := make([]string, 0), make([]string, 0)
+	visited := make(map[string]bool)
+	run.Until(t, proc, "end", nil, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case compensation.CompensationTrace:
+			id, _ := trace.Activity.Id()
+			compensated = append(compensated, *id)
+		case sub_process.RollbackTrace:
+			id, _ := trace.Transaction.Id()
+			rolledBack = append(rolledBack, *id)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+			}
+		}
+	})
+	assert.Empty(t, compensated)
+	assert.Empty(t, rolledBack)
+	assert.True(t, visited["completed"])
+	assert.False(t, visited["cancelled"])
+}
@@ -13,9 +13,12 @@ import (
 	"sync"
 
 	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/data/association"
 	"bpxe.org/pkg/flow/flow_interface"
 	"bpxe.org/pkg/flow_node"
 	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/tracing"
 )
 
 type message interface {
@@ -23,6 +26,8 @@ type message interface {
 }
 
 type nextActionMessage struct {
+	flow     flow_interface.T
+	locator  data.ItemAwareLocator
 	response chan flow_node.Action
 }
 
@@ -36,31 +41,65 @@ func (m cancelMessage) message() {}
 
 type Task struct {
 	*flow_node.Wiring
-	element       *bpmn.Task
-	runnerChannel chan message
-	bodyLock      sync.RWMutex
-	body          func(*Task, context.Context) flow_node.Action
-	cancel        context.CancelFunc
+	element          *bpmn.Task
+	runnerChannel    chan message
+	bodyLock         sync.RWMutex
+	body             func(*Task, context.Context) flow_node.Action
+	itemAwareLocator data.ItemAwareLocator
+	cancel           context.CancelFunc
+}
+
+// Data is task's data made available to its body
+// through the context (see DataFromContext)
+type Data struct {
+	// Inputs are task's data inputs (see association.Inputs)
+	Inputs map[string]data.Item
+	// Outputs are to be filled by the body with task's data outputs,
+	// which are written to their targets if the body returns
+	// a flow action (see association.Outputs)
+	Outputs map[string]data.Item
+}
+
+type contextKey string
+
+func (c contextKey) String() string {
+	return "task package context key " + string(c)
+}
+
+// DataFromContext retrieves task's Data from a given context, if there's any
+func DataFromContext(ctx context.Context) (taskData *Data, found bool) {
+	taskData, found = ctx.Value(contextKey("data")).(*Data)
+	return
+}
+
+// DataToContext saves task's Data into a given context, returning a new one
+func DataToContext(ctx context.Context, taskData *Data) context.Context {
+	return context.WithValue(ctx, contextKey("data"), taskData)
 }
 
 // SetBody override Task's body with an arbitrary function
 //
 // Since Task implements Abstract Task, it does nothing by default.
 // This allows to add an implementation. Primarily used for testing.
+//
+// Task's data inputs and outputs are available to the body
+// through DataFromContext.
 func (node *Task) SetBody(body func(*Task, context.Context) flow_node.Action) {
 	node.bodyLock.Lock()
 	defer node.bodyLock.Unlock()
 	node.body = body
 }
 
-func NewTask(ctx context.Context, startEvent *bpmn.Task) activity.Constructor {
+func NewTask(ctx context.Context, element *bpmn.Task,
+	itemAwareLocator data.ItemAwareLocator) activity.Constructor {
 	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
 		ctx, cancel := context.WithCancel(ctx)
 		taskNode := &Task{
-			Wiring:        wiring,
-			element:       startEvent,
-			runnerChannel: make(chan message, len(wiring.Incoming)*2+1),
-			cancel:        cancel,
+			Wiring:           wiring,
+			element:          element,
+			runnerChannel:    make(chan message, len(wiring.Incoming)*2+1),
+			itemAwareLocator: itemAwareLocator,
+			cancel:           cancel,
 		}
 		go taskNode.runner(ctx)
 		node = taskNode
@@ -78,14 +117,9 @@ func (node *Task) runner(ctx context.Context) {
 				m.response <- true
 			case nextActionMessage:
 				go func() {
-					var action flow_node.Action
-					action = flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
-					if node.body != nil {
-						node.bodyLock.RLock()
-						action = node.body(node, ctx)
-						node.bodyLock.RUnlock()
-					}
-					m.response <- action
+					ctx, cancel := activity.Context(ctx, m.flow)
+					defer cancel()
+					m.response <- node.execute(ctx, m.locator)
 				}()
 			default:
 			}
@@ -95,9 +129,42 @@ func (node *Task) runner(ctx context.Context) {
 	}
 }
 
-func (node *Task) NextAction(flow_interface.T) chan flow_node.Action {
-	response := make(chan flow_node.Action)
-	node.runnerChannel <- nextActionMessage{response: response}
+// execute runs the body (if any), binding task's data
+// through a given locator, and returns the action to be taken
+func (node *Task) execute(ctx context.Context, locator data.ItemAwareLocator) flow_node.Action {
+	inputs, err := association.Inputs(ctx, node.Definitions, node.element, locator)
+	if err != nil {
+		if action, ok := activity.InputErrorAction(node.Definitions, err); ok {
+			return action
+		}
+		node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		return flow_node.NoAction{}
+	}
+	taskData := &Data{Inputs: inputs, Outputs: make(map[string]data.Item)}
+	var action flow_node.Action
+	action = flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+	node.bodyLock.RLock()
+	if node.body != nil {
+		action = node.body(node, DataToContext(ctx, taskData))
+	}
+	node.bodyLock.RUnlock()
+	if _, ok := action.(flow_node.FlowAction); ok {
+		err = association.Outputs(ctx, node.Definitions, node.element, locator, taskData.Outputs)
+		if err != nil {
+			node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+			return flow_node.NoAction{}
+		}
+	}
+	return action
+}
+
+func (node *Task) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{
+		flow:     flow,
+		locator:  activity.ItemAwareLocator(flow, node.itemAwareLocator),
+		response: response,
+	}
 	return response
 }
 
@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/internal/run"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/data/association"
+	_ "bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/activity/task"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dataDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/data.bpmn", testdata, &dataDoc)
+}
+
+// setTaskBody makes the task pass its data inputs to a given channel
+// and output a summary of the order's total
+func setTaskBody(t *testing.T, inst *instance.Instance, inputs chan map[string]data.Item) {
+	node, found := dataDoc.FindBy(bpmn.ExactId("task"))
+	require.True(t, found)
+	taskNode, found := inst.FlowNodeMapping().ResolveElementToFlowNode(node.(bpmn.FlowNodeInterface))
+	require.True(t, found)
+	taskNode.(*activity.Harness).Activity().(*task.Task).SetBody(
+		func(aTask *task.Task, ctx context.Context) flow_node.Action {
+			taskData, found := task.DataFromContext(ctx)
+			if !found {
+				t.Errorf("task data not found in the context")
+				return flow_node.NoAction{}
+			}
+			inputs <- taskData.Inputs
+			taskData.Outputs["summary"] = map[string]interface{}{
+				"total": taskData.Inputs["total"],
+			}
+			return flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&aTask.Outgoing)}
+		})
+}
+
+func TestTaskData(t *testing.T) {
+	proc := process.New(&(*dataDoc.Processes())[0], &dataDoc)
+	inputs := make(chan map[string]data.Item, 1)
+	visited := make(map[string]bool)
+	inst := run.Until(t, proc, "end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("order")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), map[string]interface{}{"quantity": 3, "price": 5})
+		setTaskBody(t, inst, inputs)
+	}, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case activity.ErrorCaughtTrace:
+			t.Errorf("unexpected error caught: %s", *trace.Error.ErrorRef())
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+			}
+		}
+	})
+	assert.True(t, visited["completed"])
+	assert.False(t, visited["missing"])
+
+	received := <-inputs
+	assert.Equal(t, map[string]interface{}{"quantity": 3, "price": 5}, received["order"])
+	assert.Equal(t, 15, received["total"])
+	assert.Nil(t, received["note"])
+
+	itemAware, found := inst.FindItemAwareByName("summary")
+	require.True(t, found)
+	assert.Equal(t, map[string]interface{}{"total": 15, "status": "processed"},
+		<-itemAware.Get(context.Background()))
+}
+
+func TestTaskMissingInput(t *testing.T) {
+	proc := process.New(&(*dataDoc.Processes())[0], &dataDoc)
+	inputs := make(chan map[string]data.Item, 1)
+	visited := make(map[string]bool)
+	caught := make([]string, 0)
+	inst := run.Until(t, proc, "end", func(inst *instance.Instance) {
+		setTaskBody(t, inst, inputs)
+	}, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case activity.ErrorCaughtTrace:
+			caught = append(caught, *trace.Error.ErrorRef())
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+			}
+		}
+	})
+	// The error is the one with the missing input error code
+	assert.Equal(t, []string{"Error_missingInput"}, caught)
+	assert.Equal(t, "Error_missingInput", association.MissingInputErrorRef(&dataDoc))
+	assert.True(t, visited["missing"])
+	assert.False(t, visited["completed"])
+	assert.Empty(t, inputs)
+
+	itemAware, found := inst.FindItemAwareByName("summary")
+	require.True(t, found)
+	assert.Nil(t, <-itemAware.Get(context.Background()))
+}
+
+func TestMissingInputErrorRefWithoutErrorCode(t *testing.T) {
+	var doc bpmn.Definitions
+	assert.Equal(t, association.MissingInputErrorCode, association.MissingInputErrorRef(&doc))
+}
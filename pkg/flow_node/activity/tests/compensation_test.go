@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/internal/run"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/compensation"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompensationThrowEvent(t *testing.T) {
+	var doc bpmn.Definitions
+	internal.LoadTestFile("testdata/compensation.bpmn", testdata, &doc)
+	proc := process.New(&(*doc.Processes())[0], &doc)
+	compensated, visited := make([]string, 0), make([]string, 0)
+	run.Until(t, proc, "end", nil, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case compensation.CompensationTrace:
+			id, _ := trace.Activity.Id()
+			compensated = append(compensated, *id)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited = append(visited, *id)
+			}
+		}
+	})
+	// Activities are compensated in reverse order of their completion,
+	// before the flow continues past the throw event
+	assert.Equal(t, []string{"bookFlight", "bookHotel"}, compensated)
+	assert.Equal(t, []string{"start", "bookHotel", "bookFlight", "compensate",
+		"cancelFlight", "cancelHotel", "end"}, visited)
+}
+
+func TestCompensationThrowEventActivityRef(t *testing.T) {
+	var doc bpmn.Definitions
+	internal.LoadTestFile("testdata/compensation.bpmn", testdata, &doc)
+	throwEvent, found := doc.FindBy(bpmn.ExactId("compensate"))
+	require.True(t, found)
+	activityRef := "bookHotel"
+	(*throwEvent.(*bpmn.IntermediateThrowEvent).CompensateEventDefinitions())[0].SetActivityRef(&activityRef)
+	proc := process.New(&(*doc.Processes())[0], &doc)
+	compensated, visited := make([]string, 0), make([]string, 0)
+	run.Until(t, proc, "end", nil, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case compensation.CompensationTrace:
+			id, _ := trace.Activity.Id()
+			compensated = append(compensated, *id)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited = append(visited, *id)
+			}
+		}
+	})
+	assert.Equal(t, []string{"bookHotel"}, compensated)
+	assert.Contains(t, visited, "cancelHotel")
+	assert.NotContains(t, visited, "cancelFlight")
+}
+
+func TestCompensationOfCompletedSubProcess(t *testing.T) {
+	var doc bpmn.Definitions
+	internal.LoadTestFile("testdata/compensation.bpmn", testdata, &doc)
+	proc := process.New(&(*doc.Processes())[1], &doc)
+	compensated, visited := make([]string, 0), make([]string, 0)
+	run.Until(t, proc, "sub_end", nil, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case compensation.CompensationTrace:
+			id, _ := trace.Activity.Id()
+			compensated = append(compensated, *id)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited = append(visited, *id)
+			}
+		}
+	})
+	// The sub-process has no compensation handler of its own, so its
+	// completed inner activities are compensated instead
+	assert.Equal(t, []string{"trip", "tripHotel"}, compensated)
+	assert.Equal(t, []string{"sub_start", "trip", "trip_start", "tripHotel", "trip_end",
+		"sub_compensate", "cancelTripHotel", "sub_end"}, visited)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/internal/run"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/activity/task"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var conditionalDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/conditional_boundary_event.bpmn", testdata, &conditionalDoc)
+}
+
+func TestConditionalBoundaryEvent(t *testing.T) {
+	processElement := (*conditionalDoc.Processes())[0]
+	proc := process.New(&processElement, &conditionalDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	node, found := conditionalDoc.FindBy(bpmn.ExactId("task"))
+	require.True(t, found)
+	taskNode, found := inst.FlowNodeMapping().ResolveElementToFlowNode(node.(bpmn.FlowNodeInterface))
+	require.True(t, found)
+	// the task never completes on its own
+	taskNode.(*activity.Harness).Activity().(*task.Task).SetBody(
+		func(task *task.Task, ctx context.Context) flow_node.Action {
+			<-ctx.Done()
+			return flow_node.CompleteAction{}
+		})
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	overdue, found := inst.FindItemAwareByName("overdue")
+	require.True(t, found)
+
+	listening := false
+	activeBoundary := false
+	triggered := false
+	visited := make(map[string]bool)
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case catch.ActiveListeningTrace:
+			if id, present := trace.Node.Id(); present && *id == "overdue" {
+				listening = true
+			}
+		case activity.ActiveBoundaryTrace:
+			if id, present := trace.Node.Id(); present && trace.Start && *id == "task" {
+				activeBoundary = true
+			}
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+				if *id == "end" {
+					break loop
+				}
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+		if listening && activeBoundary && !triggered {
+			triggered = true
+			<-overdue.Put(context.Background(), true)
+		}
+	}
+	inst.Tracer.Unsubscribe(traces)
+
+	assert.True(t, visited["interrupted"])
+	assert.False(t, visited["uninterrupted"])
+}
+
+func TestConditionalBoundaryEventTrueOnStart(t *testing.T) {
+	proc := process.New(&(*conditionalDoc.Processes())[0], &conditionalDoc)
+	visited := make(map[string]bool)
+	run.Until(t, proc, "end", func(inst *instance.Instance) {
+		node, found := conditionalDoc.FindBy(bpmn.ExactId("task"))
+		require.True(t, found)
+		taskNode, found := inst.FlowNodeMapping().ResolveElementToFlowNode(node.(bpmn.FlowNodeInterface))
+		require.True(t, found)
+		// the task never completes on its own
+		taskNode.(*activity.Harness).Activity().(*task.Task).SetBody(
+			func(task *task.Task, ctx context.Context) flow_node.Action {
+				<-ctx.Done()
+				return flow_node.CompleteAction{}
+			})
+		// the condition becomes true before the task starts
+		overdue, found := inst.FindItemAwareByName("overdue")
+		require.True(t, found)
+		<-overdue.Put(context.Background(), true)
+	}, func(trace tracing.Trace) {
+		if trace, ok := trace.(flow.VisitTrace); ok {
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+			}
+		}
+	})
+	assert.True(t, visited["interrupted"])
+	assert.False(t, visited["uninterrupted"])
+}
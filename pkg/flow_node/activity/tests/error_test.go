@@ -0,0 +1,115 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/activity/task"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errorDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/error_boundary_event.bpmn", testdata, &errorDoc)
+}
+
+// instantiateFailing instantiates the process with `task` failing
+// with a given error
+func instantiateFailing(t *testing.T, errorRef string, item interface{}) (*instance.Instance, chan tracing.Trace) {
+	processElement := (*errorDoc.Processes())[0]
+	proc := process.New(&processElement, &errorDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	node, found := errorDoc.FindBy(bpmn.ExactId("task"))
+	require.True(t, found)
+	taskNode, found := inst.FlowNodeMapping().ResolveElementToFlowNode(node.(bpmn.FlowNodeInterface))
+	require.True(t, found)
+	taskNode.(*activity.Harness).Activity().(*task.Task).SetBody(
+		func(*task.Task, context.Context) flow_node.Action {
+			return flow_node.ErrorAction{ErrorRef: errorRef, Item: item}
+		})
+	return inst, traces
+}
+
+func TestErrorBoundaryEvent(t *testing.T) {
+	inst, traces := instantiateFailing(t, "err1", 42)
+	err := inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	visited := make(map[string]bool)
+	caught := false
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case activity.ErrorCaughtTrace:
+			caught = true
+			assert.Equal(t, "err1", *trace.Error.ErrorRef())
+			assert.Equal(t, 42, trace.Error.Item())
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+				if *id == "end" {
+					break loop
+				}
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	inst.Tracer.Unsubscribe(traces)
+
+	assert.True(t, caught)
+	assert.True(t, visited["caught"])
+	assert.False(t, visited["completed"])
+}
+
+func TestUncaughtError(t *testing.T) {
+	inst, traces := instantiateFailing(t, "err2", "payload")
+	err := inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case instance.UncaughtErrorTrace:
+			assert.Equal(t, inst.Id(), trace.InstanceId)
+			assert.Equal(t, "err2", *trace.Error.ErrorRef())
+			assert.Equal(t, "payload", trace.Error.Item())
+			inst.Tracer.Unsubscribe(traces)
+			return
+		case activity.ErrorCaughtTrace:
+			t.Fatalf("error should not have been caught: %#v", trace)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "completed" {
+				t.Fatalf("task should not have completed")
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
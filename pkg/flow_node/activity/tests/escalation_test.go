@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var escalationDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/escalation.bpmn", testdata, &escalationDoc)
+}
+
+func TestNonInterruptingEscalation(t *testing.T) {
+	processElement := (*escalationDoc.Processes())[0]
+	proc := process.New(&processElement, &escalationDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	visited := make(map[string]int)
+	caught := 0
+	uncaught := 0
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case activity.EscalationCaughtTrace:
+			caught++
+			assert.Equal(t, "esc1", *trace.Escalation.EscalationRef())
+		case instance.UncaughtEscalationTrace:
+			uncaught++
+			assert.Equal(t, inst.Id(), trace.InstanceId)
+			assert.Equal(t, "esc2", *trace.Escalation.EscalationRef())
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id]++
+				// both escalations and the completion of the sub-process
+				// lead to the end event
+				if *id == "end" && visited["end"] == 3 {
+					break loop
+				}
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	inst.Tracer.Unsubscribe(traces)
+
+	assert.Equal(t, 2, caught)
+	assert.Equal(t, 1, uncaught)
+	assert.Equal(t, 2, visited["escalated"])
+	assert.Equal(t, 1, visited["completed"])
+}
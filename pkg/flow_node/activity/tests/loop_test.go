@@ -0,0 +1,137 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/internal/run"
+	"bpxe.org/pkg/bpmn"
+	_ "bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandardLoop(t *testing.T) {
+	var loopDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/loop.bpmn", testdata, &loopDoc)
+	proc := process.New(&(*loopDoc.Processes())[0], &loopDoc)
+	loopCounters := make([]int, 0)
+	inst := run.Until(t, proc, "end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("count")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), 0)
+	}, func(trace tracing.Trace) {
+		if trace, ok := trace.(activity.IterationTrace); ok {
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		}
+	})
+	itemAware, found := inst.FindItemAwareByName("count")
+	require.True(t, found)
+	assert.Equal(t, 3, <-itemAware.Get(context.Background()))
+	assert.Equal(t, []int{0, 1, 2}, loopCounters)
+}
+
+func TestStandardLoopTestBefore(t *testing.T) {
+	var loopDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/loop.bpmn", testdata, &loopDoc)
+	proc := process.New(&(*loopDoc.Processes())[0], &loopDoc)
+	iterated := false
+	// Condition doesn't hold from the start
+	inst := run.Until(t, proc, "end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("count")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), 5)
+	}, func(trace tracing.Trace) {
+		_, ok := trace.(activity.IterationTrace)
+		iterated = iterated || ok
+	})
+	itemAware, found := inst.FindItemAwareByName("count")
+	require.True(t, found)
+	assert.Equal(t, 5, <-itemAware.Get(context.Background()))
+	assert.False(t, iterated)
+}
+
+func TestStandardLoopTestAfter(t *testing.T) {
+	var loopDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/loop.bpmn", testdata, &loopDoc)
+	element, found := loopDoc.FindBy(bpmn.ExactId("loop"))
+	require.True(t, found)
+	testBefore := false
+	element.(*bpmn.StandardLoopCharacteristics).SetTestBefore(&testBefore)
+	proc := process.New(&(*loopDoc.Processes())[0], &loopDoc)
+	loopCounters := make([]int, 0)
+	inst := run.Until(t, proc, "end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("count")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), 5)
+	}, func(trace tracing.Trace) {
+		if trace, ok := trace.(activity.IterationTrace); ok {
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		}
+	})
+	itemAware, found := inst.FindItemAwareByName("count")
+	require.True(t, found)
+	assert.Equal(t, 6, <-itemAware.Get(context.Background()))
+	assert.Equal(t, []int{0}, loopCounters)
+}
+
+func TestStandardLoopMaximum(t *testing.T) {
+	var loopDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/loop.bpmn", testdata, &loopDoc)
+	element, found := loopDoc.FindBy(bpmn.ExactId("loop"))
+	require.True(t, found)
+	element.(*bpmn.StandardLoopCharacteristics).SetLoopMaximum(big.NewInt(4))
+	proc := process.New(&(*loopDoc.Processes())[0], &loopDoc)
+	loopCounters := make([]int, 0)
+	inst := run.Until(t, proc, "end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("count")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), -10)
+	}, func(trace tracing.Trace) {
+		if trace, ok := trace.(activity.IterationTrace); ok {
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		}
+	})
+	itemAware, found := inst.FindItemAwareByName("count")
+	require.True(t, found)
+	assert.Equal(t, -6, <-itemAware.Get(context.Background()))
+	assert.Equal(t, []int{0, 1, 2, 3}, loopCounters)
+}
+
+func TestStandardLoopCounter(t *testing.T) {
+	var loopDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/loop.bpmn", testdata, &loopDoc)
+	element, found := loopDoc.FindBy(bpmn.ExactId("loop"))
+	require.True(t, found)
+	element.(*bpmn.StandardLoopCharacteristics).LoopCondition().Expression.(*bpmn.FormalExpression).
+		TextPayloadField = "loopCounter < 2"
+	proc := process.New(&(*loopDoc.Processes())[0], &loopDoc)
+	loopCounters := make([]int, 0)
+	inst := run.Until(t, proc, "end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("count")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), 0)
+	}, func(trace tracing.Trace) {
+		if trace, ok := trace.(activity.IterationTrace); ok {
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		}
+	})
+	itemAware, found := inst.FindItemAwareByName("count")
+	require.True(t, found)
+	assert.Equal(t, 2, <-itemAware.Get(context.Background()))
+	assert.Equal(t, []int{0, 1}, loopCounters)
+}
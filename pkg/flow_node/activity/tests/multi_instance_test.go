@@ -0,0 +1,255 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/internal/run"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	_ "bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/activity/service_task"
+	"bpxe.org/pkg/flow_node/activity/user_task"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var multiInstanceDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/multi_instance.bpmn", testdata, &multiInstanceDoc)
+}
+
+func TestParallelMultiInstance(t *testing.T) {
+	processElement, found := multiInstanceDoc.FindBy(bpmn.ExactId("parallel"))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &multiInstanceDoc)
+	loopCounters := make([]int, 0)
+	inst := run.Until(t, proc, "parallel_end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("items")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), data.SliceIterator{1, 2, 3, 4})
+	}, func(trace tracing.Trace) {
+		if trace, ok := trace.(activity.IterationTrace); ok {
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		}
+	})
+	assert.ElementsMatch(t, []int{0, 1, 2, 3}, loopCounters)
+
+	itemAware, found := inst.FindItemAwareByName("doubled")
+	require.True(t, found)
+	assert.Equal(t, data.SliceIterator{2, 4, 6, 8}, <-itemAware.Get(context.Background()))
+}
+
+func TestParallelMultiInstanceEmptyCollection(t *testing.T) {
+	processElement, found := multiInstanceDoc.FindBy(bpmn.ExactId("parallel"))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &multiInstanceDoc)
+	iterated := false
+	inst := run.Until(t, proc, "parallel_end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("items")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), []int{})
+	}, func(trace tracing.Trace) {
+		_, ok := trace.(activity.IterationTrace)
+		iterated = iterated || ok
+	})
+	assert.False(t, iterated)
+
+	itemAware, found := inst.FindItemAwareByName("doubled")
+	require.True(t, found)
+	assert.Equal(t, data.SliceIterator{}, <-itemAware.Get(context.Background()))
+}
+
+func TestSequentialMultiInstance(t *testing.T) {
+	var lock sync.Mutex
+	calls, running, overlapped := 0, 0, false
+	registry := service_task.NewRegistry()
+	registry.Register("call", func(ctx context.Context, inputs map[string]data.Item) (map[string]data.Item, error) {
+		lock.Lock()
+		calls++
+		running++
+		overlapped = overlapped || running > 1
+		lock.Unlock()
+		defer func() {
+			lock.Lock()
+			running--
+			lock.Unlock()
+		}()
+		return nil, nil
+	})
+	processElement, found := multiInstanceDoc.FindBy(bpmn.ExactId("sequential"))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &multiInstanceDoc,
+		process.WithServiceTaskRegistry(registry))
+	loopCounters := make([]int, 0)
+	run.Until(t, proc, "sequential_end", nil, func(trace tracing.Trace) {
+		if trace, ok := trace.(activity.IterationTrace); ok {
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		}
+	})
+	// loopCardinality is 5, but the completion condition
+	// is satisfied after the third execution
+	assert.Equal(t, []int{0, 1, 2}, loopCounters)
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 3, calls)
+	assert.False(t, overlapped)
+}
+
+func TestMultiInstanceFailedExecution(t *testing.T) {
+	var lock sync.Mutex
+	calls := 0
+	registry := service_task.NewRegistry()
+	registry.Register("call", func(ctx context.Context, inputs map[string]data.Item) (map[string]data.Item, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if calls == 2 {
+			return nil, errors.New("failed")
+		}
+		return nil, nil
+	})
+	processElement, found := multiInstanceDoc.FindBy(bpmn.ExactId("sequential"))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &multiInstanceDoc,
+		process.WithServiceTaskRegistry(registry))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	defer tracer.Unsubscribe(traces)
+	inst, err := proc.Instantiate(instance.WithTracer(tracer), instance.WithContext(ctx))
+	require.Nil(t, err)
+	require.Nil(t, inst.StartAll(ctx))
+
+	loopCounters := make([]int, 0)
+	failed := false
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		t.Logf("%#v", trace)
+		switch trace := trace.(type) {
+		case activity.IterationTrace:
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		case tracing.ErrorTrace:
+			failed = true
+		case flow.FlowTerminationTrace:
+			// The activity ends without taking its outgoing flow
+			if id, present := trace.Source.Id(); present && *id == "call" {
+				break loop
+			}
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "sequential_end" {
+				t.Fatalf("activity should not have completed")
+			}
+		}
+	}
+	assert.True(t, failed)
+	// The completion condition is satisfied after the third execution,
+	// but the failed second one ends the activity
+	assert.Equal(t, []int{0, 1}, loopCounters)
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 2, calls)
+}
+
+func TestParallelMultiInstanceEarlyCompletion(t *testing.T) {
+	processElement, found := multiInstanceDoc.FindBy(bpmn.ExactId("review"))
+	require.True(t, found)
+	inbox := user_task.NewInbox()
+	proc := process.New(processElement.(*bpmn.Process), &multiInstanceDoc, process.WithInbox(inbox))
+	items := make([]*user_task.WorkItem, 0)
+	run.Until(t, proc, "review_end", nil, func(trace tracing.Trace) {
+		if trace, ok := trace.(user_task.WorkItemCreatedTrace); ok {
+			items = append(items, trace.WorkItem)
+			// The completion condition is satisfied
+			// once any of the executions completes
+			if len(items) == 3 {
+				require.Nil(t, items[0].Claim("reviewer"))
+				require.Nil(t, items[0].Complete(nil))
+			}
+		}
+	})
+
+	// Work items of the abandoned executions are not left behind
+	require.Eventually(t, func() bool {
+		return len(inbox.WorkItems()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, item := range items[1:] {
+		assert.Equal(t, user_task.Cancelled, item.State())
+	}
+}
+
+func TestMultiInstanceSubProcess(t *testing.T) {
+	processElement, found := multiInstanceDoc.FindBy(bpmn.ExactId("sub"))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &multiInstanceDoc)
+	loopCounters := make([]int, 0)
+	inst := run.Until(t, proc, "sub_end", func(inst *instance.Instance) {
+		itemAware, found := inst.FindItemAwareByName("items")
+		require.True(t, found)
+		<-itemAware.Put(context.Background(), data.SliceIterator{1, 2, 3})
+	}, func(trace tracing.Trace) {
+		if trace, ok := trace.(activity.IterationTrace); ok {
+			loopCounters = append(loopCounters, trace.LoopCounter)
+		}
+	})
+	assert.ElementsMatch(t, []int{0, 1, 2}, loopCounters)
+
+	// Inner flow nodes see data items of their execution
+	itemAware, found := inst.FindItemAwareByName("doubled")
+	require.True(t, found)
+	assert.Equal(t, data.SliceIterator{2, 4, 6}, <-itemAware.Get(context.Background()))
+}
+
+func TestMultiInstanceSubProcessEarlyCompletion(t *testing.T) {
+	processElement, found := multiInstanceDoc.FindBy(bpmn.ExactId("sub_review"))
+	require.True(t, found)
+	inbox := user_task.NewInbox()
+	proc := process.New(processElement.(*bpmn.Process), &multiInstanceDoc, process.WithInbox(inbox))
+	items := make([]*user_task.WorkItem, 0)
+	iterations := 0
+	run.Until(t, proc, "sub_review_end", nil, func(trace tracing.Trace) {
+		switch trace := trace.(type) {
+		case activity.IterationTrace:
+			iterations++
+		case user_task.WorkItemCreatedTrace:
+			items = append(items, trace.WorkItem)
+			// The completion condition is satisfied
+			// once any of the executions completes
+			if len(items) == 1 {
+				require.Nil(t, trace.WorkItem.Claim("reviewer"))
+				require.Nil(t, trace.WorkItem.Complete(nil))
+			}
+		}
+	})
+	assert.Equal(t, 3, iterations)
+
+	// Executions run one at a time, so the rest of them are either
+	// dropped before they start or stopped, leaving no work items behind
+	require.Eventually(t, func() bool {
+		return len(inbox.WorkItems()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Less(t, len(items), 3)
+	for _, item := range items[1:] {
+		assert.Equal(t, user_task.Cancelled, item.State())
+	}
+}
@@ -10,6 +10,7 @@ package activity
 
 import (
 	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
 )
 
 type ActiveBoundaryTrace struct {
@@ -18,3 +19,31 @@ type ActiveBoundaryTrace struct {
 }
 
 func (b ActiveBoundaryTrace) TraceInterface() {}
+
+// ErrorCaughtTrace denotes an error thrown by the activity
+// that is going to be caught by one of its boundary events
+type ErrorCaughtTrace struct {
+	Node  bpmn.FlowNodeInterface
+	Error *event.ErrorEvent
+}
+
+func (t ErrorCaughtTrace) TraceInterface() {}
+
+// EscalationCaughtTrace denotes an escalation raised within the
+// activity that is going to be caught by one of its boundary events
+type EscalationCaughtTrace struct {
+	Node       bpmn.FlowNodeInterface
+	Escalation *event.EscalationEvent
+}
+
+func (t EscalationCaughtTrace) TraceInterface() {}
+
+// IterationTrace denotes the start of an execution of a
+// multi-instance or looping activity
+type IterationTrace struct {
+	Node bpmn.FlowNodeInterface
+	// Zero-based index of the execution
+	LoopCounter int
+}
+
+func (t IterationTrace) TraceInterface() {}
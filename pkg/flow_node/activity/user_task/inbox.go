@@ -0,0 +1,98 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package user_task
+
+import (
+	"sync"
+
+	"bpxe.org/pkg/id"
+)
+
+// Inbox is a collection of work items of active user tasks
+type Inbox interface {
+	// Add adds a work item to the inbox
+	Add(item *WorkItem)
+	// Remove removes a work item from the inbox
+	Remove(item *WorkItem)
+	// WorkItems returns all work items in the inbox
+	WorkItems() []*WorkItem
+	// WorkItemsFor returns work items that a given owner
+	// can claim or has claimed
+	WorkItemsFor(owner string) []*WorkItem
+	// FindWorkItem finds a work item by its id
+	FindWorkItem(id id.Id) (item *WorkItem, found bool)
+}
+
+// memoryInbox is a simple in-memory Inbox
+type memoryInbox struct {
+	lock  sync.RWMutex
+	items []*WorkItem
+}
+
+// NewInbox creates an in-memory Inbox
+func NewInbox() Inbox {
+	return &memoryInbox{items: make([]*WorkItem, 0)}
+}
+
+func (inbox *memoryInbox) Add(item *WorkItem) {
+	inbox.lock.Lock()
+	defer inbox.lock.Unlock()
+	inbox.items = append(inbox.items, item)
+}
+
+func (inbox *memoryInbox) Remove(item *WorkItem) {
+	inbox.lock.Lock()
+	defer inbox.lock.Unlock()
+	for i := range inbox.items {
+		if inbox.items[i] == item {
+			inbox.items = append(inbox.items[:i], inbox.items[i+1:]...)
+			return
+		}
+	}
+}
+
+func (inbox *memoryInbox) WorkItems() []*WorkItem {
+	inbox.lock.RLock()
+	defer inbox.lock.RUnlock()
+	items := make([]*WorkItem, len(inbox.items))
+	copy(items, inbox.items)
+	return items
+}
+
+func (inbox *memoryInbox) WorkItemsFor(owner string) []*WorkItem {
+	inbox.lock.RLock()
+	defer inbox.lock.RUnlock()
+	items := make([]*WorkItem, 0)
+	for _, item := range inbox.items {
+		switch item.State() {
+		case Ready:
+			if item.IsPotentialOwner(owner) {
+				items = append(items, item)
+			}
+		case Claimed:
+			if currentOwner, present := item.Owner(); present && currentOwner == owner {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func (inbox *memoryInbox) FindWorkItem(id id.Id) (item *WorkItem, found bool) {
+	inbox.lock.RLock()
+	defer inbox.lock.RUnlock()
+	for i := range inbox.items {
+		if inbox.items[i].Id.String() == id.String() {
+			item = inbox.items[i]
+			found = true
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	_ "bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity/user_task"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/user_task.bpmn", testdata, &testDoc)
+}
+
+// start starts the process within a model with given amount
+// and reviewer and waits until the work item is created
+func start(t *testing.T, amount int) (*model.Model, *instance.Instance, *user_task.WorkItem, chan tracing.Trace) {
+	ctx := context.Background()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	m := model.New(&testDoc, model.WithContext(ctx), model.WithTracer(tracer))
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+
+	for name, value := range map[string]interface{}{"amount": amount, "reviewer": "bob"} {
+		itemAware, found := inst.FindItemAwareByName(name)
+		require.True(t, found)
+		<-itemAware.Put(ctx, value)
+	}
+
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case user_task.WorkItemCreatedTrace:
+			return m, inst, trace.WorkItem, traces
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
+
+func waitForEnd(t *testing.T, traces chan tracing.Trace) (visited map[string]bool) {
+	visited = make(map[string]bool)
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+				if *id == "end" {
+					return
+				}
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
+
+func TestUserTask(t *testing.T) {
+	m, inst, item, traces := start(t, 10)
+
+	assert.Equal(t, []string{"alice", "bob"}, item.PotentialOwners)
+	assert.Equal(t, 10, item.Inputs["amount"])
+	assert.Equal(t, user_task.Ready, item.State())
+
+	found, present := m.Inbox().FindWorkItem(item.Id)
+	require.True(t, present)
+	assert.Equal(t, item, found)
+	assert.Equal(t, []*user_task.WorkItem{item}, m.Inbox().WorkItemsFor("bob"))
+	assert.Empty(t, m.Inbox().WorkItemsFor("mallory"))
+
+	// work item can't be completed before it is claimed
+	assert.IsType(t, errors.InvalidStateError{}, item.Complete(nil))
+	// only potential owners can claim it
+	assert.IsType(t, errors.InvalidArgumentError{}, item.Claim("mallory"))
+
+	require.Nil(t, item.Claim("alice"))
+	owner, present := item.Owner()
+	assert.True(t, present)
+	assert.Equal(t, "alice", owner)
+	assert.Empty(t, m.Inbox().WorkItemsFor("bob"))
+	assert.IsType(t, errors.InvalidStateError{}, item.Claim("bob"))
+
+	require.Nil(t, item.Release())
+	assert.Equal(t, user_task.Ready, item.State())
+	require.Nil(t, item.Claim("bob"))
+	require.Nil(t, item.Complete(map[string]data.Item{"approved": true}))
+	assert.Equal(t, user_task.Completed, item.State())
+
+	visited := waitForEnd(t, traces)
+	assert.True(t, visited["completed"])
+	assert.False(t, visited["rejected"])
+	assert.Empty(t, m.Inbox().WorkItems())
+
+	itemAware, present := inst.FindItemAwareByName("approved")
+	require.True(t, present)
+	assert.Equal(t, true, <-itemAware.Get(context.Background()))
+}
+
+func TestUserTaskFail(t *testing.T) {
+	m, _, item, traces := start(t, 1000)
+
+	assert.Equal(t, []string{"alice", "bob", "auditor"}, item.PotentialOwners)
+
+	require.Nil(t, item.Claim("auditor"))
+	require.Nil(t, item.Fail("rejected", "too expensive"))
+	assert.Equal(t, user_task.Failed, item.State())
+
+	visited := waitForEnd(t, traces)
+	assert.True(t, visited["rejected"])
+	assert.False(t, visited["completed"])
+	assert.Empty(t, m.Inbox().WorkItems())
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package user_task
+
+// WorkItemCreatedTrace denotes creation of a work item
+// (the work item has been already added to the inbox)
+type WorkItemCreatedTrace struct {
+	WorkItem *WorkItem
+}
+
+func (t WorkItemCreatedTrace) TraceInterface() {}
+
+// WorkItemClaimedTrace denotes a work item claimed by an owner
+type WorkItemClaimedTrace struct {
+	WorkItem *WorkItem
+	Owner    string
+}
+
+func (t WorkItemClaimedTrace) TraceInterface() {}
+
+// WorkItemReleasedTrace denotes a claimed work item being released
+type WorkItemReleasedTrace struct {
+	WorkItem *WorkItem
+}
+
+func (t WorkItemReleasedTrace) TraceInterface() {}
+
+// WorkItemCompletedTrace denotes a completed work item
+type WorkItemCompletedTrace struct {
+	WorkItem *WorkItem
+}
+
+func (t WorkItemCompletedTrace) TraceInterface() {}
+
+// WorkItemFailedTrace denotes a work item failed with a BPMN error
+type WorkItemFailedTrace struct {
+	WorkItem *WorkItem
+	ErrorRef string
+}
+
+func (t WorkItemFailedTrace) TraceInterface() {}
+
+// WorkItemCancelledTrace denotes a work item cancelled
+// along with its user task
+type WorkItemCancelledTrace struct {
+	WorkItem *WorkItem
+}
+
+func (t WorkItemCancelledTrace) TraceInterface() {}
@@ -0,0 +1,237 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package user_task
+
+import (
+	"context"
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/data/association"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/expression"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/tracing"
+)
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	flow     flow_interface.T
+	locator  data.ItemAwareLocator
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+type cancelMessage struct {
+	response chan bool
+}
+
+func (m cancelMessage) message() {}
+
+// UserTask is a wait state that creates a work item in the Inbox
+// every time a token arrives and waits until the work item
+// is completed or failed
+type UserTask struct {
+	*flow_node.Wiring
+	element          *bpmn.UserTask
+	runnerChannel    chan message
+	inbox            Inbox
+	idGenerator      id.Generator
+	itemAwareLocator data.ItemAwareLocator
+	cancel           context.CancelFunc
+}
+
+func NewUserTask(ctx context.Context, element *bpmn.UserTask, inbox Inbox,
+	idGenerator id.Generator, itemAwareLocator data.ItemAwareLocator) activity.Constructor {
+	return func(wiring *flow_node.Wiring) (node activity.Activity, err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		userTask := &UserTask{
+			Wiring:           wiring,
+			element:          element,
+			runnerChannel:    make(chan message, len(wiring.Incoming)*2+1),
+			inbox:            inbox,
+			idGenerator:      idGenerator,
+			itemAwareLocator: itemAwareLocator,
+			cancel:           cancel,
+		}
+		go userTask.runner(ctx)
+		node = userTask
+		return
+	}
+}
+
+func (node *UserTask) runner(ctx context.Context) {
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case cancelMessage:
+				node.cancel()
+				m.response <- true
+			case nextActionMessage:
+				go func() {
+					ctx, cancel := activity.Context(ctx, m.flow)
+					defer cancel()
+					action, err := node.execute(ctx, m.locator)
+					if err != nil {
+						var ok bool
+						if action, ok = activity.InputErrorAction(node.Definitions, err); !ok {
+							node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+							action = flow_node.NoAction{}
+						}
+					}
+					m.response <- action
+				}()
+			default:
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// execute creates a work item and waits until it is done, binding
+// its data through a given locator
+func (node *UserTask) execute(ctx context.Context, locator data.ItemAwareLocator) (action flow_node.Action, err error) {
+	item := &WorkItem{
+		Id:                node.idGenerator.New(),
+		ProcessInstanceId: node.ProcessInstanceId,
+		Element:           node.element,
+		tracer:            node.Tracer,
+		state:             Ready,
+		result:            make(chan flow_node.Action, 1),
+	}
+	item.Inputs, err = association.Inputs(ctx, node.Definitions, node.element, locator)
+	if err != nil {
+		return
+	}
+	item.PotentialOwners, err = node.potentialOwners(ctx, locator, item.Inputs)
+	if err != nil {
+		return
+	}
+
+	node.inbox.Add(item)
+	defer node.inbox.Remove(item)
+	node.Tracer.Trace(WorkItemCreatedTrace{WorkItem: item})
+
+	select {
+	case action = <-item.result:
+	case <-ctx.Done():
+		item.cancel()
+		action = flow_node.NoAction{}
+		return
+	}
+
+	if _, ok := action.(flow_node.FlowAction); ok {
+		err = association.Outputs(ctx, node.Definitions, node.element, locator, item.outputs)
+		if err != nil {
+			return
+		}
+		action = flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+	}
+	return
+}
+
+// potentialOwners resolves task's potential owners, either by referenced
+// resources' names or by evaluating resource assignment expressions
+// (using definitions' expression language, with data inputs available
+// to the expression)
+//
+// Potential owners are task's resource roles. The schema doesn't capture
+// members of resourceRole's substitution group, so potential owners are
+// to be specified as resource roles of tPotentialOwner type:
+//
+//	<resourceRole xsi:type="tPotentialOwner">
+//	  <resourceRef>manager</resourceRef>
+//	</resourceRole>
+func (node *UserTask) potentialOwners(ctx context.Context, locator data.ItemAwareLocator,
+	inputs map[string]data.Item) (owners []string, err error) {
+	owners = make([]string, 0)
+	potentialOwners := node.element.ResourceRoles()
+	for i := range *potentialOwners {
+		potentialOwner := &(*potentialOwners)[i]
+		if resourceRef := *potentialOwner.ResourceRef(); resourceRef != "" {
+			resource, found := node.Definitions.FindBy(bpmn.ExactId(resourceRef).
+				And(bpmn.ElementType((*bpmn.Resource)(nil))))
+			if !found {
+				err = errors.NotFoundError{Expected: fmt.Sprintf("resource with ID %s", resourceRef)}
+				return
+			}
+			owners = append(owners, *resource.(*bpmn.Resource).Name())
+		}
+		if assignment, present := potentialOwner.ResourceAssignmentExpression(); present {
+			var result expression.Result
+			env := make(map[string]interface{}, len(inputs))
+			for name, item := range inputs {
+				env[name] = item
+			}
+			// resource assignment expressions are always in definitions' expression language
+			var compiled *expression.Compiled
+			compiled, err = expression.CompileSource(ctx, *node.Definitions.ExpressionLanguage(),
+				*assignment.ExpressionField.TextPayload(), locator)
+			if err != nil {
+				return
+			}
+			result, err = compiled.Evaluate(env)
+			if err != nil {
+				return
+			}
+			switch result := result.(type) {
+			case string:
+				owners = append(owners, result)
+			case []string:
+				owners = append(owners, result...)
+			case []interface{}:
+				for _, owner := range result {
+					if owner, ok := owner.(string); ok {
+						owners = append(owners, owner)
+					} else {
+						err = errors.InvalidArgumentError{Expected: "potential owner to be a string", Actual: owner}
+						return
+					}
+				}
+			default:
+				err = errors.InvalidArgumentError{
+					Expected: "resource assignment expression to return a string or a list of strings",
+					Actual:   result,
+				}
+				return
+			}
+		}
+	}
+	return
+}
+
+func (node *UserTask) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action, 1)
+	node.runnerChannel <- nextActionMessage{
+		flow:     flow,
+		locator:  activity.ItemAwareLocator(flow, node.itemAwareLocator),
+		response: response,
+	}
+	return response
+}
+
+func (node *UserTask) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
+
+func (node *UserTask) Cancel() <-chan bool {
+	response := make(chan bool)
+	node.runnerChannel <- cancelMessage{response: response}
+	return response
+}
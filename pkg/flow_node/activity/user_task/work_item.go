@@ -0,0 +1,198 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package user_task
+
+import (
+	"fmt"
+	"sync"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/tracing"
+)
+
+// State is a state of a work item
+type State int
+
+const (
+	// Ready work item can be claimed
+	Ready State = iota
+	// Claimed work item can be completed, failed or released
+	Claimed
+	// Completed work item is done
+	Completed
+	// Failed work item has raised a BPMN error
+	Failed
+	// Cancelled work item's user task has been cancelled
+	// (for example, by an interrupting boundary event)
+	Cancelled
+)
+
+func (state State) String() string {
+	switch state {
+	case Ready:
+		return "ready"
+	case Claimed:
+		return "claimed"
+	case Completed:
+		return "completed"
+	case Failed:
+		return "failed"
+	case Cancelled:
+		return "cancelled"
+	default:
+		return fmt.Sprintf("unknown state %d", int(state))
+	}
+}
+
+// WorkItem represents a piece of work that a user task is waiting for
+// to be done by one of its potential owners (or anybody,
+// if no potential owners were specified)
+type WorkItem struct {
+	Id                id.Id
+	ProcessInstanceId id.Id
+	Element           *bpmn.UserTask
+	PotentialOwners   []string
+	Inputs            map[string]data.Item
+	tracer            tracing.Tracer
+	lock              sync.RWMutex
+	state             State
+	owner             string
+	outputs           map[string]data.Item
+	// result is sent to once the work item is completed or failed
+	result chan flow_node.Action
+}
+
+// State returns current state of the work item
+func (item *WorkItem) State() State {
+	item.lock.RLock()
+	defer item.lock.RUnlock()
+	return item.state
+}
+
+// Owner returns the owner that has claimed the work item, if any
+func (item *WorkItem) Owner() (owner string, present bool) {
+	item.lock.RLock()
+	defer item.lock.RUnlock()
+	if item.state != Ready {
+		owner = item.owner
+		present = owner != ""
+	}
+	return
+}
+
+// IsPotentialOwner returns true if a given owner can claim the work item
+func (item *WorkItem) IsPotentialOwner(owner string) bool {
+	if len(item.PotentialOwners) == 0 {
+		return true
+	}
+	for _, potentialOwner := range item.PotentialOwners {
+		if potentialOwner == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// Claim assigns a ready work item to a given owner
+func (item *WorkItem) Claim(owner string) (err error) {
+	err = item.transition(Ready, Claimed, func() (err error) {
+		if !item.IsPotentialOwner(owner) {
+			err = errors.InvalidArgumentError{
+				Expected: fmt.Sprintf("one of potential owners %v", item.PotentialOwners),
+				Actual:   owner,
+			}
+			return
+		}
+		item.owner = owner
+		return
+	})
+	if err != nil {
+		return
+	}
+	item.tracer.Trace(WorkItemClaimedTrace{WorkItem: item, Owner: owner})
+	return
+}
+
+// Release makes a claimed work item ready to be claimed again
+func (item *WorkItem) Release() (err error) {
+	err = item.transition(Claimed, Ready, func() error {
+		item.owner = ""
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	item.tracer.Trace(WorkItemReleasedTrace{WorkItem: item})
+	return
+}
+
+// Complete completes a claimed work item with given outputs (keyed by
+// user task's data output names), which are then written back through
+// task's data output associations
+func (item *WorkItem) Complete(outputs map[string]data.Item) (err error) {
+	err = item.transition(Claimed, Completed, func() error {
+		item.outputs = outputs
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	item.tracer.Trace(WorkItemCompletedTrace{WorkItem: item})
+	item.result <- flow_node.FlowAction{}
+	return
+}
+
+// Fail fails a claimed work item by raising a BPMN error
+func (item *WorkItem) Fail(errorRef string, payload data.Item) (err error) {
+	err = item.transition(Claimed, Failed, nil)
+	if err != nil {
+		return
+	}
+	item.tracer.Trace(WorkItemFailedTrace{WorkItem: item, ErrorRef: errorRef})
+	item.result <- flow_node.ErrorAction{ErrorRef: errorRef, Item: payload}
+	return
+}
+
+// transition moves the work item from an expected state to a new one,
+// applying an update (unless it is nil or fails) while the item is locked
+//
+// Work item's traces are to be emitted after the transition, so that
+// tracers can inspect the item without deadlocking.
+func (item *WorkItem) transition(expected, state State, update func() error) (err error) {
+	item.lock.Lock()
+	defer item.lock.Unlock()
+	if item.state != expected {
+		err = errors.InvalidStateError{Expected: expected.String(), Actual: item.state.String()}
+		return
+	}
+	if update != nil {
+		if err = update(); err != nil {
+			return
+		}
+	}
+	item.state = state
+	return
+}
+
+// cancel cancels the work item unless it is already done
+func (item *WorkItem) cancel() {
+	item.lock.Lock()
+	cancelled := item.state == Ready || item.state == Claimed
+	if cancelled {
+		item.state = Cancelled
+	}
+	item.lock.Unlock()
+	if cancelled {
+		item.tracer.Trace(WorkItemCancelledTrace{WorkItem: item})
+	}
+}
@@ -35,6 +35,10 @@ type processEventMessage struct {
 
 func (m processEventMessage) message() {}
 
+type checkConditionsMessage struct{}
+
+func (m checkConditionsMessage) message() {}
+
 type Node struct {
 	*flow_node.Wiring
 	element         *bpmn.CatchEvent
@@ -42,9 +46,36 @@ type Node struct {
 	activated       bool
 	awaitingActions []chan flow_node.Action
 	satisfier       *logic.CatchEventSatisfier
+	repeating       bool
+	// Number of times the event was caught while no flow awaited it
+	// (only counted by repeating catch events)
+	occurrences int
+	// Boundary events are activated by their activities
+	// (see CheckConditions) rather than by arriving flows
+	boundary bool
+	// Conditions are to be checked once the node is activated
+	conditionsPending bool
 }
 
 func New(ctx context.Context, wiring *flow_node.Wiring, catchEvent *bpmn.CatchEvent) (node *Node, err error) {
+	return newNode(ctx, wiring, catchEvent, false, false)
+}
+
+// NewBoundary creates a catch event of a boundary event
+//
+// Non-interrupting boundary event keeps listening after it has been
+// triggered. Every time the event is caught, a flow awaiting it
+// continues. Events caught while no flow awaits them are not lost,
+// but counted and let the next arriving flows continue immediately.
+//
+// Conditions of conditional boundary events are checked when their
+// activity starts (see CheckConditions) rather than when flows arrive.
+func NewBoundary(ctx context.Context, wiring *flow_node.Wiring, boundaryEvent *bpmn.BoundaryEvent) (node *Node, err error) {
+	return newNode(ctx, wiring, &boundaryEvent.CatchEvent, !boundaryEvent.CancelActivity(), true)
+}
+
+func newNode(ctx context.Context, wiring *flow_node.Wiring, catchEvent *bpmn.CatchEvent,
+	repeating bool, boundary bool) (node *Node, err error) {
 	node = &Node{
 		Wiring:          wiring,
 		element:         catchEvent,
@@ -52,6 +83,8 @@ func New(ctx context.Context, wiring *flow_node.Wiring, catchEvent *bpmn.CatchEv
 		activated:       false,
 		awaitingActions: make([]chan flow_node.Action, 0),
 		satisfier:       logic.NewCatchEventSatisfier(catchEvent, wiring.EventDefinitionInstanceBuilder),
+		repeating:       repeating,
+		boundary:        boundary,
 	}
 	sender := node.Tracer.RegisterSender()
 	go node.runner(ctx, sender)
@@ -70,23 +103,36 @@ func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
 		case msg := <-node.runnerChannel:
 			switch m := msg.(type) {
 			case processEventMessage:
+				node.catch(m.event)
+			case checkConditionsMessage:
 				if node.activated {
-					node.Tracer.Trace(EventObservedTrace{Node: node.element, Event: m.event})
-					if satisfied, _ := node.satisfier.Satisfy(m.event); satisfied {
-						awaitingActions := node.awaitingActions
-						for _, actionChan := range awaitingActions {
-							actionChan <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
-						}
-						node.awaitingActions = make([]chan flow_node.Action, 0)
-						node.activated = false
-					}
+					node.checkConditions()
+				} else {
+					node.conditionsPending = true
 				}
 			case nextActionMessage:
+				// Flows only arrive at link catch events from their link
+				// throw events, so there's nothing to wait for
+				if len(*node.element.LinkEventDefinitions()) > 0 {
+					m.response <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+					continue
+				}
+				if node.repeating && node.occurrences > 0 {
+					node.occurrences--
+					m.response <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+					continue
+				}
+				node.awaitingActions = append(node.awaitingActions, m.response)
 				if !node.activated {
 					node.activated = true
 					node.Tracer.Trace(ActiveListeningTrace{Node: node.element})
+					// Conditions that became true before the flow
+					// has arrived are not going to change anymore
+					if !node.boundary || node.conditionsPending {
+						node.conditionsPending = false
+						node.checkConditions()
+					}
 				}
-				node.awaitingActions = append(node.awaitingActions, m.response)
 			default:
 			}
 		case <-ctx.Done():
@@ -96,6 +142,49 @@ func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
 	}
 }
 
+// catch handles an event caught by the node
+func (node *Node) catch(ev event.Event) {
+	if !node.activated {
+		return
+	}
+	node.Tracer.Trace(EventObservedTrace{Node: node.element, Event: ev})
+	if satisfied, _ := node.satisfier.Satisfy(ev); satisfied {
+		awaitingActions := node.awaitingActions
+		for _, actionChan := range awaitingActions {
+			actionChan <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+		}
+		node.awaitingActions = make([]chan flow_node.Action, 0)
+		if node.repeating {
+			if len(awaitingActions) == 0 {
+				node.occurrences++
+			}
+		} else {
+			node.activated = false
+		}
+	}
+}
+
+// checkConditions catches conditional events, conditions of which
+// are true already, as if they have just occurred
+func (node *Node) checkConditions() {
+	for _, definitionInstance := range *node.satisfier.EventDefinitionInstances() {
+		if conditional, ok := definitionInstance.(event.ConditionalDefinitionInstance); ok {
+			// Data the condition depends on may not be initialized
+			// yet, in which case the condition doesn't hold
+			if holds, err := conditional.ConditionHolds(); err == nil && holds {
+				node.catch(event.MakeConditionalEvent(conditional))
+			}
+		}
+	}
+}
+
+// CheckConditions checks conditions of conditional events once the
+// node is activated, catching those that are true already. Boundary
+// events' activities use it when they start.
+func (node *Node) CheckConditions() {
+	node.runnerChannel <- checkConditionsMessage{}
+}
+
 func (node *Node) ConsumeEvent(
 	ev event.Event,
 ) (result event.ConsumptionResult, err error) {
@@ -64,9 +64,6 @@ func (e eventDefinitionInstanceBuilder) NewEventDefinitionInstance(def bpmn.Even
 	case *bpmn.TimerEventDefinition:
 		id, _ := d.Id()
 		return eventInstance{id: *id}, nil
-	case *bpmn.ConditionalEventDefinition:
-		id, _ := d.Id()
-		return eventInstance{id: *id}, nil
 	default:
 		return event.WrapEventDefinition(d), nil
 	}
@@ -78,12 +75,6 @@ func TestTimerEvent(t *testing.T) {
 	testEvent(t, "testdata/intermediate_catch_event.bpmn", "timerCatch", &b, false, event.MakeTimerEvent(i))
 }
 
-func TestConditionalEvent(t *testing.T) {
-	i := eventInstance{id: "conditional_1"}
-	b := eventDefinitionInstanceBuilder{}
-	testEvent(t, "testdata/intermediate_catch_event.bpmn", "conditionalCatch", &b, false, event.MakeTimerEvent(i))
-}
-
 func testEvent(t *testing.T, filename string, nodeId string, eventDefinitionInstanceBuilder event.DefinitionInstanceBuilder, eventObservationOnly bool, events ...event.Event) {
 	var testDoc bpmn.Definitions
 	var err error
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	ev "bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var conditionalDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/conditional.bpmn", testdata, &conditionalDoc)
+}
+
+func instantiateConditional(t *testing.T, processId string) (*instance.Instance, chan tracing.Trace) {
+	processElement, found := conditionalDoc.FindBy(bpmn.ExactId(processId))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &conditionalDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	return inst, traces
+}
+
+func put(t *testing.T, inst *instance.Instance, name string, item data.Item) {
+	itemAware, found := inst.FindItemAwareByName(name)
+	require.True(t, found)
+	<-itemAware.Put(context.Background(), item)
+}
+
+func TestConditionalEvent(t *testing.T) {
+	inst, traces := instantiateConditional(t, "conditionalCatch")
+	err := inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	observed := 0
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case ev.ActiveListeningTrace:
+			// the condition remains false
+			put(t, inst, "amount", 5)
+			// the condition becomes true
+			put(t, inst, "amount", 20)
+		case ev.EventObservedTrace:
+			observed++
+			assert.IsType(t, event.ConditionalEvent{}, trace.Event)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "end" {
+				break loop
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	inst.Tracer.Unsubscribe(traces)
+
+	assert.Equal(t, 1, observed)
+}
+
+func TestConditionalEventTrueOnArrival(t *testing.T) {
+	inst, traces := instantiateConditional(t, "conditionalCatch")
+	// the condition becomes true before the flow arrives
+	put(t, inst, "amount", 20)
+	err := inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	observed := 0
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case ev.EventObservedTrace:
+			observed++
+			assert.IsType(t, event.ConditionalEvent{}, trace.Event)
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "end" {
+				break loop
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	inst.Tracer.Unsubscribe(traces)
+
+	assert.Equal(t, 1, observed)
+}
+
+func TestConditionalStartEvent(t *testing.T) {
+	inst, traces := instantiateConditional(t, "conditionalStart")
+	// the instance is not started explicitly
+	put(t, inst, "ready", true)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "started" {
+				inst.Tracer.Unsubscribe(traces)
+				return
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"bpxe.org/pkg/event"
 	"bpxe.org/pkg/flow/flow_interface"
 	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/event/throw"
 	"bpxe.org/pkg/tracing"
 )
 
@@ -62,9 +63,15 @@ func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
 				if !node.activated {
 					node.activated = true
 				}
+				// Every token reaching the end event throws
+				// events for its event definitions, if any
+				if err := throw.Throw(ctx, node.Wiring, &node.element.ThrowEvent); err != nil {
+					node.Wiring.Tracer.Trace(tracing.ErrorTrace{Error: err})
+				}
 				// If the node already completed, then we essentially fuse it
 				if node.completed {
 					m.response <- flow_node.CompleteAction{}
+					node.terminateScope()
 					continue
 				}
 
@@ -73,6 +80,7 @@ func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
 				); err == nil {
 					node.completed = true
 					m.response <- flow_node.CompleteAction{}
+					node.terminateScope()
 				} else {
 					node.Wiring.Tracer.Trace(tracing.ErrorTrace{Error: err})
 				}
@@ -85,6 +93,17 @@ func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
 	}
 }
 
+// terminateScope terminates the scope the node belongs to if this is
+// a terminate end event.
+//
+// It must be called after the response has been sent, as termination
+// cancels the flow awaiting it.
+func (node *Node) terminateScope() {
+	if len(*node.element.TerminateEventDefinitions()) > 0 && node.TerminateScope != nil {
+		node.TerminateScope()
+	}
+}
+
 func (node *Node) NextAction(flow_interface.T) chan flow_node.Action {
 	response := make(chan flow_node.Action)
 	node.runnerChannel <- nextActionMessage{response: response}
@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminateEndEvent(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/terminate.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+	if inst, err := proc.Instantiate(); err == nil {
+		traces := inst.Tracer.Subscribe()
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case instance.TerminationTrace:
+				assert.Equal(t, inst.Id(), trace.InstanceId)
+				break loop
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					assert.NotEqual(t, "end", *id)
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.True(t, inst.WaitUntilComplete(ctx))
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
+
+func TestTerminateEndEventInSubProcess(t *testing.T) {
+	var testDoc bpmn.Definitions
+	internal.LoadTestFile("testdata/terminate_sub_process.bpmn", testdata, &testDoc)
+	processElement := (*testDoc.Processes())[0]
+	proc := process.New(&processElement, &testDoc)
+	if inst, err := proc.Instantiate(); err == nil {
+		traces := inst.Tracer.Subscribe()
+		err := inst.StartAll(context.Background())
+		if err != nil {
+			t.Fatalf("failed to run the instance: %s", err)
+		}
+		visited := make(map[string]bool)
+	loop:
+		for {
+			trace := tracing.Unwrap(<-traces)
+			switch trace := trace.(type) {
+			case instance.TerminationTrace:
+				t.Fatalf("sub-process termination should not terminate the instance")
+			case flow.VisitTrace:
+				if id, present := trace.Node.Id(); present {
+					visited[*id] = true
+					// sub-process has been terminated and completed
+					if *id == "end" {
+						break loop
+					}
+				}
+			case tracing.ErrorTrace:
+				t.Fatalf("%#v", trace)
+			default:
+				t.Logf("%#v", trace)
+			}
+		}
+		inst.Tracer.Unsubscribe(traces)
+
+		assert.True(t, visited["terminate"])
+		assert.False(t, visited["subEnd"])
+	} else {
+		t.Fatalf("failed to instantiate the process: %s", err)
+	}
+}
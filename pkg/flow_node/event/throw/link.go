@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package throw
+
+import (
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
+)
+
+// LinkTarget finds the intermediate catch event a given link throw event
+// definition leads to within a given container (process or sub-process)
+//
+// If the definition specifies its target, the catch event with the link
+// event definition of that id is the target. Otherwise, the catch event
+// with the link event definition of the same name is.
+func LinkTarget(container bpmn.FlowElementsContainerInterface,
+	definition *bpmn.LinkEventDefinition) (target *bpmn.IntermediateCatchEvent, err error) {
+	matches := func(candidate *bpmn.LinkEventDefinition) bool {
+		if targetRef, present := definition.Target(); present {
+			id, present := candidate.Id()
+			return present && *id == string(*targetRef)
+		}
+		return *candidate.Name() != "" && *candidate.Name() == *definition.Name()
+	}
+
+	catchEvents := container.IntermediateCatchEvents()
+	for i := range *catchEvents {
+		catchEvent := &(*catchEvents)[i]
+		linkEventDefinitions := catchEvent.LinkEventDefinitions()
+		for j := range *linkEventDefinitions {
+			if !matches(&(*linkEventDefinitions)[j]) {
+				continue
+			}
+			if target != nil {
+				err = errors.InvalidArgumentError{
+					Expected: "link to have a single target",
+					Actual:   fmt.Sprintf("%#v", definition),
+				}
+				target = nil
+				return
+			}
+			target = catchEvent
+		}
+	}
+	if target == nil {
+		err = errors.NotFoundError{Expected: fmt.Sprintf("link target for %#v", definition)}
+	}
+	return
+}
+
+// ValidateLinks checks that every link throw event within a given
+// container (process or sub-process), including those within its nested
+// sub-processes, leads to an intermediate catch event (see LinkTarget)
+func ValidateLinks(container bpmn.FlowElementsContainerInterface) (err error) {
+	throwEvents := container.IntermediateThrowEvents()
+	for i := range *throwEvents {
+		linkEventDefinitions := (*throwEvents)[i].LinkEventDefinitions()
+		for j := range *linkEventDefinitions {
+			_, err = LinkTarget(container, &(*linkEventDefinitions)[j])
+			if err != nil {
+				return
+			}
+		}
+	}
+	for i := range *container.SubProcesses() {
+		err = ValidateLinks(&(*container.SubProcesses())[i])
+		if err != nil {
+			return
+		}
+	}
+	for i := range *container.Transactions() {
+		err = ValidateLinks(&(*container.Transactions())[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
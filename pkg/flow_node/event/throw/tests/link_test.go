@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var linkDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/link.bpmn", testdata, &linkDoc)
+}
+
+func TestLinkEvents(t *testing.T) {
+	processElement, found := linkDoc.FindBy(bpmn.ExactId("linker"))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &linkDoc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 64))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	visited := make(map[string]bool)
+	links := make(map[string]string)
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case flow.LinkTrace:
+			source, _ := trace.Source.Id()
+			target, _ := trace.Target.Id()
+			links[*source] = *target
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+				if *id == "end" {
+					break loop
+				}
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	inst.Tracer.Unsubscribe(traces)
+
+	assert.Equal(t, map[string]string{
+		// matched by name
+		"toSecond": "fromFirst",
+		// matched by target
+		"toSubEnd": "fromSubStart",
+		"toEnd":    "fromSecond",
+	}, links)
+	for _, id := range []string{"first", "fromFirst", "second", "fromSubStart", "subEnd", "fromSecond"} {
+		assert.True(t, visited[id], id)
+	}
+}
+
+func TestUnmatchedLink(t *testing.T) {
+	processElement, found := linkDoc.FindBy(bpmn.ExactId("unmatched"))
+	require.True(t, found)
+	proc := process.New(processElement.(*bpmn.Process), &linkDoc)
+	_, err := proc.Instantiate()
+	assert.IsType(t, errors.NotFoundError{}, err)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/event/throw"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/throw_event.bpmn", testdata, &testDoc)
+}
+
+func TestThrowEvents(t *testing.T) {
+	ctx := context.Background()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	m := model.New(&testDoc, model.WithContext(ctx), model.WithTracer(tracer))
+	err := m.Run(ctx)
+	require.Nil(t, err)
+
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "thrower"
+	})
+	require.True(t, found)
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+
+	visited := make(map[string]bool)
+	thrown := make(map[string]bool)
+	for !(visited["signalCaught"] && visited["messageCaught"] &&
+		thrown["esc1"] && thrown["err1"]) {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case throw.EventThrownTrace:
+			switch ev := trace.Event.(type) {
+			case *event.SignalEvent:
+				thrown[*ev.SignalRef()] = true
+			case *event.MessageEvent:
+				thrown[*ev.MessageRef()] = true
+			case *event.EscalationEvent:
+				thrown[*ev.EscalationRef()] = true
+			case *event.ErrorEvent:
+				thrown[*ev.ErrorRef()] = true
+			}
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id] = true
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	tracer.Unsubscribe(traces)
+
+	assert.True(t, thrown["sig1"])
+	assert.True(t, thrown["msg1"])
+}
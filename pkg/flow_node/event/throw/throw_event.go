@@ -0,0 +1,213 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package throw
+
+import (
+	"context"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/tracing"
+)
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	response chan flow_node.Action
+}
+
+func (m nextActionMessage) message() {}
+
+// Node is an intermediate throw event
+type Node struct {
+	*flow_node.Wiring
+	element       *bpmn.IntermediateThrowEvent
+	runnerChannel chan message
+	// Intermediate catch event the flow is moved to,
+	// if this is a link throw event
+	linkTarget *bpmn.IntermediateCatchEvent
+}
+
+// New creates an intermediate throw event within a given container
+// (process or sub-process)
+//
+// Link throw events (the ones with a link event definition) don't
+// throw anything but move the flow straight to their target intermediate
+// catch event in the same container (see LinkTarget).
+func New(ctx context.Context, wiring *flow_node.Wiring, throwEvent *bpmn.IntermediateThrowEvent,
+	container bpmn.FlowElementsContainerInterface) (node *Node, err error) {
+	node = &Node{
+		Wiring:        wiring,
+		element:       throwEvent,
+		runnerChannel: make(chan message, len(wiring.Incoming)*2+1),
+	}
+	if linkEventDefinitions := throwEvent.LinkEventDefinitions(); len(*linkEventDefinitions) > 0 {
+		node.linkTarget, err = LinkTarget(container, &(*linkEventDefinitions)[0])
+		if err != nil {
+			return
+		}
+	}
+	sender := node.Tracer.RegisterSender()
+	go node.runner(ctx, sender)
+	return
+}
+
+func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
+	defer sender.Done()
+
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case nextActionMessage:
+				if node.linkTarget != nil {
+					m.response <- flow_node.LinkAction{Target: node.linkTarget}
+					continue
+				}
+				if err := Throw(ctx, node.Wiring, &node.element.ThrowEvent); err != nil {
+					node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+					m.response <- flow_node.CompleteAction{}
+					continue
+				}
+				m.response <- flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&node.Outgoing)}
+			default:
+			}
+		case <-ctx.Done():
+			node.Tracer.Trace(flow_node.CancellationTrace{Node: node.element})
+			return
+		}
+	}
+}
+
+func (node *Node) NextAction(flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action)
+	node.runnerChannel <- nextActionMessage{response: response}
+	return response
+}
+
+func (node *Node) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
+
+// Events returns events that correspond to throw event's
+// signal, message, escalation, error, compensate and cancel event definitions.
+//
+// Other event definitions are not represented by events
+// and are ignored.
+func Events(throwEvent *bpmn.ThrowEvent) (events []event.Event, err error) {
+	events = make([]event.Event, 0, len(throwEvent.EventDefinitions()))
+	for _, definition := range throwEvent.EventDefinitions() {
+		switch d := definition.(type) {
+		case *bpmn.SignalEventDefinition:
+			signalRef, present := d.SignalRef()
+			if !present {
+				err = errors.InvalidArgumentError{Expected: "signal event definition to have signalRef", Actual: d}
+				return
+			}
+			events = append(events, event.NewSignalEvent(*signalRef))
+		case *bpmn.MessageEventDefinition:
+			messageRef, present := d.MessageRef()
+			if !present {
+				err = errors.InvalidArgumentError{Expected: "message event definition to have messageRef", Actual: d}
+				return
+			}
+			operationRef, _ := d.OperationRef()
+			events = append(events, event.NewMessageEvent(*messageRef, operationRef))
+		case *bpmn.EscalationEventDefinition:
+			escalationRef, present := d.EscalationRef()
+			if !present {
+				err = errors.InvalidArgumentError{Expected: "escalation event definition to have escalationRef", Actual: d}
+				return
+			}
+			events = append(events, event.NewEscalationEvent(*escalationRef))
+		case *bpmn.ErrorEventDefinition:
+			errorRef, present := d.ErrorRef()
+			if !present {
+				err = errors.InvalidArgumentError{Expected: "error event definition to have errorRef", Actual: d}
+				return
+			}
+			events = append(events, event.NewErrorEvent(*errorRef))
+		case *bpmn.CompensateEventDefinition:
+			var activityRef string
+			if ref, present := d.ActivityRef(); present {
+				activityRef = *ref
+			}
+			events = append(events, event.NewCompensationEvent(activityRef))
+		case *bpmn.CancelEventDefinition:
+			events = append(events, event.MakeCancelEvent())
+		default:
+		}
+	}
+	return
+}
+
+// Throw publishes events corresponding to throw event's event
+// definitions through wiring's event ingress
+//
+// Error and escalation events are not published but raised to the
+// enclosing scope (see flow_node.Wiring.RaiseError and RaiseEscalation)
+// instead, if it is available.
+// Likewise, compensation events compensate completed activities
+// of the enclosing scope (see flow_node.Wiring.Compensation) and,
+// unless the event definition says otherwise, Throw waits until
+// their compensation is done. Cancel events cancel the enclosing
+// transaction (see flow_node.Wiring.CancelTransaction) and are
+// not allowed outside of one.
+func Throw(ctx context.Context, wiring *flow_node.Wiring, throwEvent *bpmn.ThrowEvent) (err error) {
+	var events []event.Event
+	events, err = Events(throwEvent)
+	if err != nil {
+		return
+	}
+	compensations := 0
+	for _, ev := range events {
+		// Tracing before the event is published to make sure
+		// the trace precedes any traces caused by catching it
+		wiring.Tracer.Trace(EventThrownTrace{Node: throwEvent, Event: ev})
+		if errorEvent, ok := ev.(*event.ErrorEvent); ok && wiring.RaiseError != nil {
+			wiring.RaiseError(errorEvent)
+		} else if escalationEvent, ok := ev.(*event.EscalationEvent); ok && wiring.RaiseEscalation != nil {
+			wiring.RaiseEscalation(escalationEvent)
+		} else if compensationEvent, ok := ev.(*event.CompensationEvent); ok && wiring.Compensation != nil {
+			var activityRef *bpmn.IdRef
+			if *compensationEvent.ActivityRef() != "" {
+				activityRef = compensationEvent.ActivityRef()
+			}
+			// Every compensate event definition yields an event, in order
+			definition := &(*throwEvent.CompensateEventDefinitions())[compensations]
+			compensations++
+			// WaitForCompletion() can't be used when the attribute is absent
+			if waitForCompletion := definition.WaitForCompletionField; waitForCompletion != nil && !*waitForCompletion {
+				go wiring.Compensation.Compensate(ctx, activityRef)
+			} else {
+				wiring.Compensation.Compensate(ctx, activityRef)
+			}
+		} else if _, ok := ev.(event.CancelEvent); ok {
+			if wiring.CancelTransaction == nil {
+				err = errors.NotSupportedError{
+					What:   "cancel event outside of a transaction",
+					Reason: "only transactions can be cancelled",
+				}
+				return
+			}
+			wiring.CancelTransaction()
+		} else {
+			_, err = wiring.EventIngress.ConsumeEvent(ev)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
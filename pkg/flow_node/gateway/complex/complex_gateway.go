@@ -0,0 +1,315 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package complex
+
+import (
+	"context"
+	"fmt"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/expression"
+	"bpxe.org/pkg/flow/flow_interface"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/gateway"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/sequence_flow"
+	"bpxe.org/pkg/tracing"
+
+	_ "bpxe.org/pkg/expression/expr"
+)
+
+type NoEffectiveSequenceFlows struct {
+	*bpmn.ComplexGateway
+}
+
+func (e NoEffectiveSequenceFlows) Error() string {
+	ownId := "<unnamed>"
+	if ownIdPtr, present := e.ComplexGateway.Id(); present {
+		ownId = *ownIdPtr
+	}
+	return fmt.Sprintf("No effective sequence flows found in complex gateway `%v`", ownId)
+}
+
+type message interface {
+	message()
+}
+
+type nextActionMessage struct {
+	response chan flow_node.Action
+	flow     flow_interface.T
+}
+
+func (m nextActionMessage) message() {}
+
+type probingReport struct {
+	result []int
+	flowId id.Id
+}
+
+func (m probingReport) message() {}
+
+type flowSync struct {
+	response chan flow_node.Action
+	flow     flow_interface.T
+}
+
+// Node is a complex gateway
+//
+// It goes through two states. While waiting for start, the gateway
+// collects arriving tokens until its activation condition (which has
+// `activationCount`, the number of tokens arrived so far, available to it)
+// is met or, if there's no activation condition, until all the tokens it
+// waits for (as tracked by gateway.FlowTracker) have arrived. Then the
+// gateway fires, following its outgoing sequence flows the same way
+// inclusive gateway does.
+//
+// While waiting for reset, the gateway absorbs tokens that arrive late
+// (they don't produce any tokens on outgoing sequence flows) until all
+// the tokens it has been waiting for have either arrived or terminated.
+// After that, the gateway is ready to be activated again.
+type Node struct {
+	*flow_node.Wiring
+	element                 *bpmn.ComplexGateway
+	runnerChannel           chan message
+	defaultSequenceFlow     *sequence_flow.SequenceFlow
+	nonDefaultSequenceFlows []*sequence_flow.SequenceFlow
+	itemAwareLocator        data.ItemAwareLocator
+	flowTracker             *gateway.FlowTracker
+	// First token that has arrived while waiting for start
+	activated *flowSync
+	// Tokens that are expected to arrive. After the activation,
+	// only those that haven't arrived yet.
+	awaiting []id.Id
+	arrived  []id.Id
+	sync     []chan flow_node.Action
+	probing  *chan flow_node.Action
+	// Set once the activation condition has been met and
+	// outgoing sequence flows are being probed
+	activating bool
+	// Set once the gateway has fired and is waiting for reset
+	fired    bool
+	absorbed int
+}
+
+func New(ctx context.Context, wiring *flow_node.Wiring, complexGateway *bpmn.ComplexGateway,
+	itemAwareLocator data.ItemAwareLocator) (node *Node, err error) {
+	var defaultSequenceFlow *sequence_flow.SequenceFlow
+
+	if seqFlow, present := complexGateway.Default(); present {
+		if node, found := wiring.Process.FindBy(bpmn.ExactId(*seqFlow).
+			And(bpmn.ElementType((*bpmn.SequenceFlow)(nil)))); found {
+			defaultSequenceFlow = new(sequence_flow.SequenceFlow)
+			*defaultSequenceFlow = sequence_flow.Make(
+				node.(*bpmn.SequenceFlow),
+				wiring.Definitions,
+			)
+		} else {
+			err = errors.NotFoundError{
+				Expected: fmt.Sprintf("default sequence flow with ID %s", *seqFlow),
+			}
+			return nil, err
+		}
+	}
+
+	nonDefaultSequenceFlows := flow_node.AllSequenceFlows(&wiring.Outgoing,
+		func(sequenceFlow *sequence_flow.SequenceFlow) bool {
+			if defaultSequenceFlow == nil {
+				return false
+			}
+			return *sequenceFlow == *defaultSequenceFlow
+		},
+	)
+
+	node = &Node{
+		Wiring:                  wiring,
+		element:                 complexGateway,
+		runnerChannel:           make(chan message, len(wiring.Incoming)*2+1),
+		nonDefaultSequenceFlows: nonDefaultSequenceFlows,
+		defaultSequenceFlow:     defaultSequenceFlow,
+		itemAwareLocator:        itemAwareLocator,
+		flowTracker:             gateway.NewFlowTracker(ctx, wiring.Tracer, complexGateway),
+	}
+	sender := node.Tracer.RegisterSender()
+	go node.runner(ctx, sender)
+	return
+}
+
+func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
+	defer node.flowTracker.Shutdown()
+	activity := node.flowTracker.Activity()
+
+	defer sender.Done()
+
+	for {
+		select {
+		case msg := <-node.runnerChannel:
+			switch m := msg.(type) {
+			case probingReport:
+				response := node.probing
+				if response == nil {
+					// Reschedule, there's no next action yet
+					go func() {
+						node.runnerChannel <- m
+					}()
+					continue
+				}
+				node.probing = nil
+				flow := make([]*sequence_flow.SequenceFlow, 0)
+				for _, i := range m.result {
+					flow = append(flow, node.nonDefaultSequenceFlows[i])
+				}
+
+				switch len(flow) {
+				case 0:
+					// no successful non-default sequence flows
+					if node.defaultSequenceFlow == nil {
+						// exception (Table 13.2)
+						node.Wiring.Tracer.Trace(tracing.ErrorTrace{
+							Error: NoEffectiveSequenceFlows{
+								ComplexGateway: node.element,
+							},
+						})
+					} else {
+						gateway.DistributeFlows(node.sync, []*sequence_flow.SequenceFlow{node.defaultSequenceFlow})
+					}
+				default:
+					gateway.DistributeFlows(node.sync, flow)
+				}
+				node.activating = false
+				node.fired = true
+				node.tryReset()
+			case nextActionMessage:
+				switch {
+				case node.activating && node.probing == nil && m.flow.Id() == node.activated.flow.Id():
+					// Activating flow returned, and now we wait
+					// until the probe has returned
+					node.sync = append(node.sync, m.response)
+					node.probing = &m.response
+				case node.activating || node.fired:
+					// Late token
+					node.arrived = append(node.arrived, m.flow.Id())
+					node.absorbed++
+					m.response <- flow_node.CompleteAction{}
+					if node.fired {
+						node.tryReset()
+					}
+				case node.activated == nil:
+					// Haven't been activated yet
+					node.activated = &flowSync{response: m.response, flow: m.flow}
+					node.awaiting = node.flowTracker.ActiveFlowsInCohort(m.flow.Id())
+					node.arrived = []id.Id{m.flow.Id()}
+					node.sync = make([]chan flow_node.Action, 0)
+					node.tryActivate(ctx)
+				default:
+					node.arrived = append(node.arrived, m.flow.Id())
+					node.sync = append(node.sync, m.response)
+					node.tryActivate(ctx)
+				}
+			default:
+			}
+		case <-activity:
+			switch {
+			case node.fired:
+				node.tryReset()
+			case !node.activating && node.activated != nil:
+				node.awaiting = node.flowTracker.ActiveFlowsInCohort(node.activated.flow.Id())
+				// Activation condition only depends on arrived tokens
+				if _, present := node.element.ActivationCondition(); !present {
+					node.tryActivate(ctx)
+				}
+			}
+		case <-ctx.Done():
+			node.Tracer.Trace(flow_node.CancellationTrace{Node: node.element})
+			return
+		}
+	}
+}
+
+// tryActivate fires the gateway if its activation condition has been met
+// (or, if there's none, if all awaited tokens have arrived)
+func (node *Node) tryActivate(ctx context.Context) {
+	var activate bool
+	if activationCondition, present := node.element.ActivationCondition(); present {
+		var err error
+		activate, err = expression.EvaluateCondition(ctx, node.Definitions, activationCondition.Expression,
+			node.itemAwareLocator, map[string]interface{}{"activationCount": len(node.arrived)})
+		if err != nil {
+			node.Tracer.Trace(tracing.ErrorTrace{Error: err})
+			return
+		}
+	} else {
+		activate = len(node.pending()) == 0
+	}
+	if !activate {
+		return
+	}
+
+	node.activating = true
+	node.Tracer.Trace(ActivationTrace{Node: node.element, ActivationCount: len(node.arrived)})
+	anId := node.activated.flow.Id()
+	// Probe outgoing sequence flow using the first flow
+	node.activated.response <- flow_node.ProbeAction{
+		SequenceFlows: node.nonDefaultSequenceFlows,
+		ProbeReport: func(indices []int) {
+			node.runnerChannel <- probingReport{
+				result: indices,
+				flowId: anId,
+			}
+		},
+	}
+}
+
+// tryReset gets the gateway ready for the next activation if no more
+// tokens are expected to arrive
+func (node *Node) tryReset() {
+	awaiting := make([]id.Id, 0, len(node.awaiting))
+	for _, flowId := range node.pending() {
+		// Skip flows that have been terminated
+		if len(node.flowTracker.ActiveFlowsInCohort(flowId)) > 0 {
+			awaiting = append(awaiting, flowId)
+		}
+	}
+	node.awaiting = awaiting
+	if len(node.awaiting) > 0 {
+		return
+	}
+	node.Tracer.Trace(ResetTrace{Node: node.element, Absorbed: node.absorbed})
+	node.activated = nil
+	node.arrived = nil
+	node.sync = nil
+	node.fired = false
+	node.absorbed = 0
+}
+
+// pending returns awaited tokens that haven't arrived yet
+func (node *Node) pending() (result []id.Id) {
+	result = make([]id.Id, 0, len(node.awaiting))
+awaiting:
+	for _, flowId := range node.awaiting {
+		for i := range node.arrived {
+			if node.arrived[i] == flowId {
+				continue awaiting
+			}
+		}
+		result = append(result, flowId)
+	}
+	return
+}
+
+func (node *Node) NextAction(flow flow_interface.T) chan flow_node.Action {
+	response := make(chan flow_node.Action)
+	node.runnerChannel <- nextActionMessage{response: response, flow: flow}
+	return response
+}
+
+func (node *Node) Element() bpmn.FlowNodeInterface {
+	return node.element
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/gateway/complex"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// load loads the test process, adjusting it with a given function
+func load(t *testing.T, adjust func(*bpmn.Definitions)) (*bpmn.Definitions, *bpmn.Process) {
+	var doc bpmn.Definitions
+	internal.LoadTestFile("testdata/complex_gateway.bpmn", testdata, &doc)
+	if adjust != nil {
+		adjust(&doc)
+	}
+	return &doc, &(*doc.Processes())[0]
+}
+
+// run runs the process until all of its flows have ceased and
+// the join gateway has been reset, and returns visit counts of flow
+// nodes along with activation and reset traces of the join
+func run(t *testing.T, adjust func(*bpmn.Definitions)) (map[string]int, []complex.ActivationTrace, []complex.ResetTrace) {
+	doc, processElement := load(t, adjust)
+	proc := process.New(processElement, doc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	visited := make(map[string]int)
+	activations := make([]complex.ActivationTrace, 0)
+	resets := make([]complex.ResetTrace, 0)
+	ceased := false
+	for !ceased || len(resets) == 0 {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present {
+				visited[*id]++
+			}
+		case complex.ActivationTrace:
+			if *trace.Node.IdField == "join" {
+				activations = append(activations, trace)
+			}
+		case complex.ResetTrace:
+			if *trace.Node.IdField == "join" {
+				resets = append(resets, trace)
+			}
+		case flow.CeaseFlowTrace:
+			ceased = true
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	tracer.Unsubscribe(traces)
+	return visited, activations, resets
+}
+
+func TestComplexGatewayActivation(t *testing.T) {
+	visited, activations, resets := run(t, nil)
+	// The join fires once two out of three approvals have arrived
+	assert.Equal(t, 1, visited["approved"])
+	assert.Equal(t, 0, visited["skipped"])
+	if assert.Len(t, activations, 1) {
+		assert.Equal(t, 2, activations[0].ActivationCount)
+	}
+	// and absorbs the third one
+	if assert.Len(t, resets, 1) {
+		assert.Equal(t, 1, resets[0].Absorbed)
+	}
+}
+
+func TestComplexGatewayWithoutActivationCondition(t *testing.T) {
+	visited, activations, resets := run(t, func(doc *bpmn.Definitions) {
+		join, found := doc.FindBy(bpmn.ExactId("join"))
+		require.True(t, found)
+		join.(*bpmn.ComplexGateway).SetActivationCondition(nil)
+	})
+	// The join waits for all approvals
+	assert.Equal(t, 1, visited["approved"])
+	if assert.Len(t, activations, 1) {
+		assert.Equal(t, 3, activations[0].ActivationCount)
+	}
+	if assert.Len(t, resets, 1) {
+		assert.Equal(t, 0, resets[0].Absorbed)
+	}
+}
+
+func TestComplexGatewayNoEffectiveSequenceFlows(t *testing.T) {
+	doc, processElement := load(t, func(doc *bpmn.Definitions) {
+		for _, id := range []string{"Flow_fork_approval1", "Flow_fork_approval2", "Flow_fork_approval3"} {
+			sequenceFlow, found := doc.FindBy(bpmn.ExactId(id))
+			require.True(t, found)
+			condition, present := sequenceFlow.(*bpmn.SequenceFlow).ConditionExpression()
+			require.True(t, present)
+			condition.Expression.(*bpmn.FormalExpression).TextPayloadField = "false"
+		}
+	})
+	proc := process.New(processElement, doc)
+	tracer := tracing.NewTracer(context.Background())
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 32))
+	inst, err := proc.Instantiate(instance.WithTracer(tracer))
+	require.Nil(t, err)
+	err = inst.StartAll(context.Background())
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		if trace, ok := trace.(tracing.ErrorTrace); ok {
+			var target complex.NoEffectiveSequenceFlows
+			if assert.True(t, errors.As(trace.Error, &target)) {
+				assert.Equal(t, "fork", *target.ComplexGateway.IdField)
+			}
+			break
+		}
+	}
+	tracer.Unsubscribe(traces)
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package complex
+
+import (
+	"bpxe.org/pkg/bpmn"
+)
+
+// ActivationTrace denotes the gateway firing once its
+// activation condition has been met
+type ActivationTrace struct {
+	Node *bpmn.ComplexGateway
+	// Number of tokens that have arrived by the time of activation
+	ActivationCount int
+}
+
+func (t ActivationTrace) TraceInterface() {}
+
+// ResetTrace denotes the gateway getting ready for the next activation
+// after all the tokens it has been waiting for have arrived (or
+// have been terminated)
+type ResetTrace struct {
+	Node *bpmn.ComplexGateway
+	// Number of tokens that have arrived after the activation
+	// and have been absorbed by the gateway
+	Absorbed int
+}
+
+func (t ResetTrace) TraceInterface() {}
@@ -6,7 +6,7 @@
 // by the Apache License, Version 2.0, included in the file
 // licenses/LICENSE-Apache-2.0
 
-package inclusive
+package gateway
 
 import (
 	"context"
@@ -18,25 +18,33 @@ import (
 	"bpxe.org/pkg/tracing"
 )
 
-type flowTracker struct {
+// FlowTracker keeps track of active flows and flow nodes they have
+// originated from, so that converging gateways (inclusive and complex)
+// can find out which flows they are to wait for
+//
+// Flows passing through inclusive and complex gateways are considered
+// to originate from them.
+type FlowTracker struct {
 	traces     <-chan tracing.Trace
 	shutdownCh chan bool
 	flows      map[id.Id]bpmn.Id
 	activityCh chan struct{}
 	lock       sync.RWMutex
-	element    *bpmn.InclusiveGateway
+	element    bpmn.FlowNodeInterface
 }
 
-func (tracker *flowTracker) activity() <-chan struct{} {
+// Activity returns a channel that signals changes in tracked flows
+func (tracker *FlowTracker) Activity() <-chan struct{} {
 	return tracker.activityCh
 }
 
-func newFlowTracker(ctx context.Context, tracer tracing.Tracer, element *bpmn.InclusiveGateway) *flowTracker {
-	tracker := flowTracker{
+// NewFlowTracker creates a tracker for a given converging gateway
+func NewFlowTracker(ctx context.Context, tracer tracing.Tracer, element bpmn.FlowNodeInterface) *FlowTracker {
+	tracker := FlowTracker{
 		traces:     tracer.Subscribe(),
 		shutdownCh: make(chan bool),
 		flows:      make(map[id.Id]bpmn.Id),
-		activityCh: make(chan struct{}),
+		activityCh: make(chan struct{}, 1),
 		element:    element,
 	}
 	// Lock the tracker until it has caught up enough
@@ -46,7 +54,7 @@ func newFlowTracker(ctx context.Context, tracer tracing.Tracer, element *bpmn.In
 	return &tracker
 }
 
-func (tracker *flowTracker) run(ctx context.Context) {
+func (tracker *FlowTracker) run(ctx context.Context) {
 	// As per note in the constructor, we're starting in a locked mode
 	locked := true
 	// Flag for notifying the node about activity
@@ -80,7 +88,12 @@ func (tracker *flowTracker) run(ctx context.Context) {
 			if locked && reachedNode {
 				tracker.lock.Unlock()
 				if notify {
-					tracker.activityCh <- struct{}{}
+					// A pending notification will do. Blocking here would stop
+					// draining traces and deadlock a node that is tracing.
+					select {
+					case tracker.activityCh <- struct{}{}:
+					default:
+					}
 					notify = false
 				}
 				locked = false
@@ -106,7 +119,7 @@ func (tracker *flowTracker) run(ctx context.Context) {
 	}
 }
 
-func (tracker *flowTracker) handleTrace(locked bool, trace tracing.Trace, notify bool, reachedNode bool) (bool, bool, bool) {
+func (tracker *FlowTracker) handleTrace(locked bool, trace tracing.Trace, notify bool, reachedNode bool) (bool, bool, bool) {
 	trace = tracing.Unwrap(trace)
 	if !locked {
 		// Lock tracker records until messages are drained
@@ -126,8 +139,7 @@ func (tracker *flowTracker) handleTrace(locked bool, trace tracing.Trace, notify
 			}
 			if idPtr, present := t.Source.Id(); present {
 				_, ok := tracker.flows[snapshot.Id()]
-				_, isInclusive := t.Source.(*bpmn.InclusiveGateway)
-				if !ok || isInclusive {
+				if !ok || convergent(t.Source) {
 					tracker.flows[snapshot.Id()] = *idPtr
 				}
 			}
@@ -140,11 +152,15 @@ func (tracker *flowTracker) handleTrace(locked bool, trace tracing.Trace, notify
 	return locked, notify, reachedNode
 }
 
-func (tracker *flowTracker) shutdown() {
+// Shutdown stops tracking
+func (tracker *FlowTracker) Shutdown() {
 	close(tracker.shutdownCh)
 }
 
-func (tracker *flowTracker) activeFlowsInCohort(flowId id.Id) (result []id.Id) {
+// ActiveFlowsInCohort returns active flows that originate from
+// the same flow node as a given flow, including the flow itself.
+// Returns an empty list if the flow is not active.
+func (tracker *FlowTracker) ActiveFlowsInCohort(flowId id.Id) (result []id.Id) {
 	result = make([]id.Id, 0)
 	tracker.lock.RLock()
 	defer tracker.lock.RUnlock()
@@ -157,3 +173,14 @@ func (tracker *flowTracker) activeFlowsInCohort(flowId id.Id) (result []id.Id) {
 	}
 	return
 }
+
+// convergent returns true if flows passing through a given
+// flow node are to be considered originating from it
+func convergent(element bpmn.FlowNodeInterface) bool {
+	switch element.(type) {
+	case *bpmn.InclusiveGateway, *bpmn.ComplexGateway:
+		return true
+	default:
+		return false
+	}
+}
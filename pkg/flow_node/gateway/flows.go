@@ -28,7 +28,7 @@ func DistributeFlows(awaitingActions []chan flow_node.Action, sequenceFlows []*s
 			rangeEnd = len(sequenceFlows)
 		}
 
-		if rangeEnd <= len(sequenceFlows) {
+		if i < len(sequenceFlows) {
 			action <- flow_node.FlowAction{
 				SequenceFlows:      sequenceFlows[i:rangeEnd],
 				UnconditionalFlows: indices[0 : rangeEnd-i],
@@ -70,8 +70,8 @@ type Node struct {
 	awaiting                []id.Id
 	arrived                 []id.Id
 	sync                    []chan flow_node.Action
-	*flowTracker
-	synchronized bool
+	flowTracker             *gateway.FlowTracker
+	synchronized            bool
 }
 
 func New(ctx context.Context, wiring *flow_node.Wiring, inclusiveGateway *bpmn.InclusiveGateway) (node *Node, err error) {
@@ -108,7 +108,7 @@ func New(ctx context.Context, wiring *flow_node.Wiring, inclusiveGateway *bpmn.I
 		runnerChannel:           make(chan message, len(wiring.Incoming)*2+1),
 		nonDefaultSequenceFlows: nonDefaultSequenceFlows,
 		defaultSequenceFlow:     defaultSequenceFlow,
-		flowTracker:             newFlowTracker(ctx, wiring.Tracer, inclusiveGateway),
+		flowTracker:             gateway.NewFlowTracker(ctx, wiring.Tracer, inclusiveGateway),
 	}
 	sender := node.Tracer.RegisterSender()
 	go node.runner(ctx, sender)
@@ -116,8 +116,8 @@ func New(ctx context.Context, wiring *flow_node.Wiring, inclusiveGateway *bpmn.I
 }
 
 func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
-	defer node.flowTracker.shutdown()
-	activity := node.flowTracker.activity()
+	defer node.flowTracker.Shutdown()
+	activity := node.flowTracker.Activity()
 
 	defer sender.Done()
 
@@ -170,7 +170,7 @@ func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
 					if node.activated == nil {
 						// Haven't been activated yet
 						node.activated = &flowSync{response: m.response, flow: m.flow}
-						node.awaiting = node.flowTracker.activeFlowsInCohort(m.flow.Id())
+						node.awaiting = node.flowTracker.ActiveFlowsInCohort(m.flow.Id())
 						node.arrived = []id.Id{m.flow.Id()}
 						node.sync = make([]chan flow_node.Action, 0)
 					} else {
@@ -185,7 +185,7 @@ func (node *Node) runner(ctx context.Context, sender tracing.SenderHandle) {
 			}
 		case <-activity:
 			if !node.synchronized && node.activated != nil {
-				node.awaiting = node.flowTracker.activeFlowsInCohort(node.activated.flow.Id())
+				node.awaiting = node.flowTracker.ActiveFlowsInCohort(node.activated.flow.Id())
 				node.trySync()
 			}
 		case <-ctx.Done():
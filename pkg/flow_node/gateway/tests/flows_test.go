@@ -0,0 +1,62 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"testing"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/gateway"
+	"bpxe.org/pkg/sequence_flow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// More awaiting actions than sequence flows: the first ones get a flow each,
+// the rest are completed
+func TestDistributeFlowsMoreActionsThanFlows(t *testing.T) {
+	definitions := bpmn.DefaultDefinitions()
+	element := bpmn.DefaultSequenceFlow()
+	sequenceFlows := []*sequence_flow.SequenceFlow{sequence_flow.New(&element, &definitions)}
+
+	awaitingActions := make([]chan flow_node.Action, 3)
+	for i := range awaitingActions {
+		awaitingActions[i] = make(chan flow_node.Action, 1)
+	}
+	gateway.DistributeFlows(awaitingActions, sequenceFlows)
+
+	action, ok := (<-awaitingActions[0]).(flow_node.FlowAction)
+	require.True(t, ok)
+	assert.Equal(t, sequenceFlows, action.SequenceFlows)
+	assert.Equal(t, []int{0}, action.UnconditionalFlows)
+	for _, awaitingAction := range awaitingActions[1:] {
+		assert.IsType(t, flow_node.CompleteAction{}, <-awaitingAction)
+	}
+}
+
+// As many awaiting actions as sequence flows: every one gets a flow
+func TestDistributeFlowsAsManyActionsAsFlows(t *testing.T) {
+	definitions := bpmn.DefaultDefinitions()
+	sequenceFlows := make([]*sequence_flow.SequenceFlow, 2)
+	awaitingActions := make([]chan flow_node.Action, 2)
+	for i := range sequenceFlows {
+		element := bpmn.DefaultSequenceFlow()
+		sequenceFlows[i] = sequence_flow.New(&element, &definitions)
+		awaitingActions[i] = make(chan flow_node.Action, 1)
+	}
+	gateway.DistributeFlows(awaitingActions, sequenceFlows)
+
+	for i, awaitingAction := range awaitingActions {
+		action, ok := (<-awaitingAction).(flow_node.FlowAction)
+		require.True(t, ok)
+		assert.Equal(t, sequenceFlows[i:i+1], action.SequenceFlows)
+		assert.Equal(t, []int{0}, action.UnconditionalFlows)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"sync"
 
 	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/compensation"
 	"bpxe.org/pkg/errors"
 	"bpxe.org/pkg/event"
 	"bpxe.org/pkg/id"
@@ -36,6 +37,23 @@ type Wiring struct {
 	FlowNodeMapping                *FlowNodeMapping
 	FlowWaitGroup                  *sync.WaitGroup
 	EventDefinitionInstanceBuilder event.DefinitionInstanceBuilder
+	// TerminateScope, if set, immediately terminates the scope
+	// (process instance or sub-process) flow node belongs to
+	TerminateScope func()
+	// RaiseError, if set, raises a BPMN error to the scope
+	// (process instance or sub-process) flow node belongs to
+	RaiseError func(*event.ErrorEvent)
+	// RaiseEscalation, if set, raises an escalation to the innermost
+	// activity flow node is within (or belongs to) or, if there's none,
+	// to the scope (process instance) it belongs to
+	RaiseEscalation func(*event.EscalationEvent)
+	// Compensation, if set, keeps track of completed activities of the scope
+	// (process instance or sub-process) flow node belongs to that can be
+	// compensated
+	Compensation *compensation.Scope
+	// CancelTransaction, if set, cancels the transaction
+	// flow node belongs to
+	CancelTransaction func()
 }
 
 func sequenceFlows(process *bpmn.Process,
@@ -136,6 +154,11 @@ func (wiring *Wiring) CloneFor(flowNode *bpmn.FlowNode) (result *Wiring, err err
 		FlowNodeMapping:                wiring.FlowNodeMapping,
 		FlowWaitGroup:                  wiring.FlowWaitGroup,
 		EventDefinitionInstanceBuilder: wiring.EventDefinitionInstanceBuilder,
+		TerminateScope:                 wiring.TerminateScope,
+		RaiseError:                     wiring.RaiseError,
+		RaiseEscalation:                wiring.RaiseEscalation,
+		Compensation:                   wiring.Compensation,
+		CancelTransaction:              wiring.CancelTransaction,
 	}
 	return
 }
@@ -0,0 +1,147 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package history
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/process/instance/status"
+)
+
+// Execution is a record of a single process instance's execution
+type Execution struct {
+	InstanceId id.Id
+	ProcessId  string
+	Status     status.Status
+	StartedAt  time.Time
+	// Zero while the execution is running
+	EndedAt time.Time
+	// IDs of visited flow nodes, in order of visiting
+	Visited []string
+	// IDs of sequence flows taken, in order of taking
+	SequenceFlows []string
+	// IDs of link throw events whose links were followed
+	// to their link catch events, in order of following
+	Links []string
+	// Final values of data objects and properties, keyed by
+	// their names. Only recorded upon completion.
+	Data map[string]interface{}
+}
+
+// Store gives access to records of executions
+type Store interface {
+	// Query returns executions matching a given predicate,
+	// ordered by their start time
+	Query(predicate Predicate) ([]Execution, error)
+}
+
+// Predicate matches executions
+type Predicate func(*Execution) bool
+
+// And returns a predicate that matches executions
+// matched by both predicates
+func (predicate Predicate) And(other Predicate) Predicate {
+	return func(execution *Execution) bool {
+		return predicate(execution) && other(execution)
+	}
+}
+
+// Or returns a predicate that matches executions
+// matched by either of predicates
+func (predicate Predicate) Or(other Predicate) Predicate {
+	return func(execution *Execution) bool {
+		return predicate(execution) || other(execution)
+	}
+}
+
+// All matches any execution
+func All(*Execution) bool {
+	return true
+}
+
+// ProcessId matches executions of a given process
+func ProcessId(processId string) Predicate {
+	return func(execution *Execution) bool {
+		return execution.ProcessId == processId
+	}
+}
+
+// WithStatus matches executions that have any of given statuses
+func WithStatus(statuses ...status.Status) Predicate {
+	return func(execution *Execution) bool {
+		for _, status := range statuses {
+			if execution.Status == status {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StartedBetween matches executions that started within a given
+// time range (inclusive). Zero time leaves the range unbounded
+// on that side.
+func StartedBetween(from, to time.Time) Predicate {
+	return func(execution *Execution) bool {
+		return within(execution.StartedAt, from, to)
+	}
+}
+
+// EndedBetween matches executions that ended within a given time
+// range (inclusive). Zero time leaves the range unbounded on that side.
+//
+// Running executions are never matched.
+func EndedBetween(from, to time.Time) Predicate {
+	return func(execution *Execution) bool {
+		return !execution.EndedAt.IsZero() && within(execution.EndedAt, from, to)
+	}
+}
+
+func within(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
+
+// DataMatches matches executions with a data object or property
+// of a given name, value of which satisfies a given function
+func DataMatches(name string, f func(value interface{}) bool) Predicate {
+	return func(execution *Execution) bool {
+		value, present := execution.Data[name]
+		return present && f(value)
+	}
+}
+
+// DataEquals matches executions with a data object or property
+// of a given name equal to a given value
+func DataEquals(name string, value interface{}) Predicate {
+	return DataMatches(name, func(v interface{}) bool {
+		return reflect.DeepEqual(v, value)
+	})
+}
+
+type contextKey string
+
+func (c contextKey) String() string {
+	return "history package context key " + string(c)
+}
+
+// FromContext retrieves a Store from a given context, if there's any
+func FromContext(ctx context.Context) (store Store, found bool) {
+	store, found = ctx.Value(contextKey("store")).(Store)
+	return
+}
+
+// ToContext saves Store into a given context, returning a new one.
+//
+// Expression engines use it to query previous executions.
+func ToContext(ctx context.Context, store Store) context.Context {
+	return context.WithValue(ctx, contextKey("store"), store)
+}
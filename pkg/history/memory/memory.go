@@ -0,0 +1,181 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/history"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+)
+
+// Store keeps records of executions in memory, populating
+// them from process instances' traces
+//
+// Executions are timestamped with the times carried by instances'
+// lifecycle traces.
+type Store struct {
+	lock       sync.RWMutex
+	executions map[string]*history.Execution
+	// Instance ID bytes in the order of executions' start
+	order []string
+}
+
+// New creates a new store
+func New() *Store {
+	return &Store{
+		executions: make(map[string]*history.Execution),
+		order:      make([]string, 0),
+	}
+}
+
+// Subscribe records traces of a given tracer until
+// the context is done
+func (store *Store) Subscribe(ctx context.Context, tracer tracing.Tracer) {
+	traces := tracer.Subscribe()
+	sender := tracer.RegisterSender()
+	go func() {
+		defer sender.Done()
+		defer tracer.Unsubscribe(traces)
+		for {
+			select {
+			case trace := <-traces:
+				store.Record(trace)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Record updates the execution a given trace belongs to,
+// unless the trace is not related to any instance's execution
+func (store *Store) Record(trace tracing.Trace) {
+	trace, instanceTrace := instance.UnwrapTrace(trace)
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	// Instance lifecycle traces are not wrapped by the instance
+	switch t := trace.(type) {
+	case instance.InstantiationTrace:
+		store.start(t.InstanceId, t.Process, t.CreatedAt)
+	case instance.RestorationTrace:
+		store.start(t.InstanceId, t.Process, t.CreatedAt)
+	case instance.SuspensionTrace:
+		execution := store.execution(t.InstanceId)
+		if execution.Status == instance.Running {
+			execution.Status = instance.Suspended
+		}
+	case instance.ResumptionTrace:
+		execution := store.execution(t.InstanceId)
+		if execution.Status == instance.Suspended {
+			execution.Status = instance.Running
+		}
+	case instance.CompletionTrace:
+		execution := store.execution(t.InstanceId)
+		execution.Status = instance.Completed
+		execution.EndedAt = t.CompletedAt
+		execution.Data = t.Data
+	case instance.TerminationTrace:
+		execution := store.execution(t.InstanceId)
+		execution.Status = instance.Terminated
+		execution.EndedAt = t.TerminatedAt
+	case flow.VisitTrace:
+		if instanceTrace == nil {
+			return
+		}
+		if id, present := t.Node.Id(); present {
+			execution := store.execution(instanceTrace.InstanceId)
+			execution.Visited = append(execution.Visited, *id)
+		}
+	case flow.FlowTrace:
+		if instanceTrace == nil {
+			return
+		}
+		execution := store.execution(instanceTrace.InstanceId)
+		for i := range t.Flows {
+			if id, present := t.Flows[i].SequenceFlow().Id(); present {
+				execution.SequenceFlows = append(execution.SequenceFlows, *id)
+			}
+		}
+	case flow.LinkTrace:
+		if instanceTrace == nil {
+			return
+		}
+		if id, present := t.Source.Id(); present {
+			execution := store.execution(instanceTrace.InstanceId)
+			execution.Links = append(execution.Links, *id)
+		}
+	}
+}
+
+// start records the start of an execution at instance's creation
+// time. A restored instance continues its existing execution,
+// if there's any.
+func (store *Store) start(instanceId id.Id, process *bpmn.Process, createdAt time.Time) {
+	execution := store.execution(instanceId)
+	if execution.ProcessId == "" && process != nil {
+		if id, present := process.Id(); present {
+			execution.ProcessId = *id
+		}
+	}
+	execution.StartedAt = createdAt
+	execution.Status = instance.Running
+	execution.EndedAt = time.Time{}
+}
+
+// execution returns the execution of a given instance,
+// creating it if it doesn't exist
+//
+// Must be called with the lock held.
+func (store *Store) execution(instanceId id.Id) *history.Execution {
+	key := string(instanceId.Bytes())
+	execution, present := store.executions[key]
+	if !present {
+		execution = &history.Execution{
+			InstanceId:    instanceId,
+			Visited:       make([]string, 0),
+			SequenceFlows: make([]string, 0),
+			Links:         make([]string, 0),
+		}
+		store.executions[key] = execution
+		store.order = append(store.order, key)
+	}
+	return execution
+}
+
+func (store *Store) Query(predicate history.Predicate) (executions []history.Execution, err error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	executions = make([]history.Execution, 0)
+	for _, key := range store.order {
+		execution := store.executions[key]
+		if predicate(execution) {
+			executions = append(executions, copyExecution(execution))
+		}
+	}
+	return
+}
+
+// copyExecution makes a copy of an execution that
+// further recording will not affect
+func copyExecution(execution *history.Execution) history.Execution {
+	result := *execution
+	result.Visited = append(make([]string, 0, len(execution.Visited)), execution.Visited...)
+	result.SequenceFlows = append(make([]string, 0, len(execution.SequenceFlows)), execution.SequenceFlows...)
+	result.Links = append(make([]string, 0, len(execution.Links)), execution.Links...)
+	return result
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/expression/expr"
+	"bpxe.org/pkg/history"
+	"bpxe.org/pkg/history/memory"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/history.bpmn", testdata, &testDoc)
+}
+
+// run runs an instance of the test process to its completion
+func run(t *testing.T, ctx context.Context, proc *process.Process, approved bool) *instance.Instance {
+	inst, err := proc.Instantiate(instance.WithContext(ctx))
+	require.Nil(t, err)
+	itemAware, found := inst.FindItemAwareByName("approved")
+	require.True(t, found)
+	<-itemAware.Put(ctx, approved)
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+	return inst
+}
+
+func TestHistoryQuery(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+
+	tracer := tracing.NewTracer(ctx)
+	store := memory.New()
+	store.Subscribe(ctx, tracer)
+
+	proc := process.New(&(*testDoc.Processes())[0], &testDoc, process.WithTracer(tracer))
+	started := c.Now()
+	approvedInst := run(t, ctx, proc, true)
+	require.Eventually(t, func() bool {
+		executions, err := store.Query(history.WithStatus(instance.Completed))
+		require.Nil(t, err)
+		return len(executions) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	c.Add(1 * time.Hour)
+	rejectedInst := run(t, ctx, proc, false)
+	require.Eventually(t, func() bool {
+		executions, err := store.Query(history.WithStatus(instance.Completed))
+		require.Nil(t, err)
+		return len(executions) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	executions, err := store.Query(history.ProcessId("proc"))
+	require.Nil(t, err)
+	require.Len(t, executions, 2)
+	assert.Equal(t, approvedInst.Id().Bytes(), executions[0].InstanceId.Bytes())
+	assert.Equal(t, rejectedInst.Id().Bytes(), executions[1].InstanceId.Bytes())
+	assert.Equal(t, []string{"start", "decide", "approve", "end"}, executions[0].Visited)
+	assert.Equal(t, []string{"Flow_start_decide", "approval", "Flow_approve_end"}, executions[0].SequenceFlows)
+	assert.Equal(t, started, executions[0].StartedAt)
+	assert.Equal(t, started, executions[0].EndedAt)
+	assert.Equal(t, true, executions[0].Data["approved"])
+	assert.Equal(t, []string{"start", "decide", "reject", "end"}, executions[1].Visited)
+
+	executions, err = store.Query(history.ProcessId("other"))
+	require.Nil(t, err)
+	assert.Empty(t, executions)
+
+	executions, err = store.Query(history.StartedBetween(started.Add(30*time.Minute), time.Time{}))
+	require.Nil(t, err)
+	require.Len(t, executions, 1)
+	assert.Equal(t, rejectedInst.Id().Bytes(), executions[0].InstanceId.Bytes())
+
+	executions, err = store.Query(history.EndedBetween(time.Time{}, started))
+	require.Nil(t, err)
+	require.Len(t, executions, 1)
+	assert.Equal(t, approvedInst.Id().Bytes(), executions[0].InstanceId.Bytes())
+
+	executions, err = store.Query(history.DataEquals("approved", false).
+		Or(history.WithStatus(instance.Terminated)))
+	require.Nil(t, err)
+	require.Len(t, executions, 1)
+	assert.Equal(t, rejectedInst.Id().Bytes(), executions[0].InstanceId.Bytes())
+}
+
+func TestHistoryRunning(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+
+	tracer := tracing.NewTracer(ctx)
+	store := memory.New()
+	store.Subscribe(ctx, tracer)
+
+	proc := process.New(&(*testDoc.Processes())[0], &testDoc, process.WithTracer(tracer))
+	started := c.Now()
+	inst, err := proc.Instantiate(instance.WithContext(ctx))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		executions, err := store.Query(history.WithStatus(instance.Running))
+		require.Nil(t, err)
+		return len(executions) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	executions, err := store.Query(history.EndedBetween(time.Time{}, time.Time{}))
+	require.Nil(t, err)
+	assert.Empty(t, executions)
+
+	err = inst.Suspend()
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		executions, err := store.Query(history.WithStatus(instance.Suspended))
+		require.Nil(t, err)
+		return len(executions) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	executions, err = store.Query(history.WithStatus(instance.Running))
+	require.Nil(t, err)
+	assert.Empty(t, executions)
+
+	err = inst.Resume()
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		executions, err := store.Query(history.WithStatus(instance.Running))
+		require.Nil(t, err)
+		return len(executions) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Executions are timestamped by instances, not by the time
+	// their traces are recorded
+	c.Add(1 * time.Hour)
+	terminated := c.Now()
+	err = inst.Cancel()
+	require.Nil(t, err)
+	c.Add(1 * time.Hour)
+	require.Eventually(t, func() bool {
+		executions, err = store.Query(history.WithStatus(instance.Terminated))
+		require.Nil(t, err)
+		return len(executions) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, started, executions[0].StartedAt)
+	assert.Equal(t, terminated, executions[0].EndedAt)
+}
+
+func TestHistoryLinks(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+
+	tracer := tracing.NewTracer(ctx)
+	store := memory.New()
+	store.Subscribe(ctx, tracer)
+
+	proc := process.New(&(*testDoc.Processes())[1], &testDoc, process.WithTracer(tracer))
+	inst, err := proc.Instantiate(instance.WithContext(ctx))
+	require.Nil(t, err)
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		executions, err := store.Query(history.WithStatus(instance.Completed))
+		require.Nil(t, err)
+		return len(executions) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	executions, err := store.Query(history.ProcessId("linked"))
+	require.Nil(t, err)
+	require.Len(t, executions, 1)
+	assert.Equal(t, []string{"linked_start", "toEnd", "fromStart", "linked_end"}, executions[0].Visited)
+	assert.Equal(t, []string{"Flow_linked_start_toEnd", "Flow_fromStart_linked_end"}, executions[0].SequenceFlows)
+	assert.Equal(t, []string{"toEnd"}, executions[0].Links)
+}
+
+func TestHistoryExpression(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+
+	tracer := tracing.NewTracer(ctx)
+	store := memory.New()
+	store.Subscribe(ctx, tracer)
+
+	proc := process.New(&(*testDoc.Processes())[0], &testDoc, process.WithTracer(tracer))
+	run(t, ctx, proc, true)
+	run(t, ctx, proc, false)
+	require.Eventually(t, func() bool {
+		executions, err := store.Query(history.WithStatus(instance.Completed))
+		require.Nil(t, err)
+		return len(executions) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	engine := expr.New(history.ToContext(ctx, store))
+	compiled, err := engine.CompileExpression(
+		`len(filter(getExecutions("proc", "completed"), {.data.approved}))`)
+	require.Nil(t, err)
+	result, err := engine.EvaluateExpression(compiled, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 1, result)
+
+	compiled, err = engine.CompileExpression(`getExecutions("proc", "running")`)
+	require.Nil(t, err)
+	result, err = engine.EvaluateExpression(compiled, nil)
+	require.Nil(t, err)
+	assert.Empty(t, result)
+
+	// No store in the context
+	engine = expr.New(ctx)
+	compiled, err = engine.CompileExpression(`getExecutions("proc")`)
+	require.Nil(t, err)
+	result, err = engine.EvaluateExpression(compiled, nil)
+	require.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+func TestHistoryGatewayCondition(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+
+	tracer := tracing.NewTracer(ctx)
+	store := memory.New()
+	store.Subscribe(ctx, tracer)
+
+	m := model.New(&testDoc, model.WithContext(ctx), model.WithTracer(tracer), model.WithHistory(store))
+	err := m.Run(ctx)
+	require.Nil(t, err)
+
+	// The gateway takes a different path once
+	// the process has been completed before
+	for i, task := range []string{"first", "repeated"} {
+		_, err = m.ConsumeEvent(event.NewMessageEvent("repeatRequested", nil, nil))
+		require.Nil(t, err)
+		var executions []history.Execution
+		require.Eventually(t, func() bool {
+			executions, err = store.Query(history.ProcessId("repeat").And(history.WithStatus(instance.Completed)))
+			require.Nil(t, err)
+			return len(executions) == i+1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"repeat_start", "repeat_decide", task, "repeat_end"}, executions[i].Visited)
+	}
+}
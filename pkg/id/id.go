@@ -17,6 +17,9 @@ import (
 type GeneratorBuilder interface {
 	NewIdGenerator(ctx context.Context, tracer tracing.Tracer) (Generator, error)
 	RestoreIdGenerator(ctx context.Context, serialized []byte, tracer tracing.Tracer) (Generator, error)
+	// RestoreId re-creates an identifier from its byte representation
+	// (see Id.Bytes)
+	RestoreId(bytes []byte) (Id, error)
 }
 
 type Generator interface {
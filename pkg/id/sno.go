@@ -64,6 +64,16 @@ func (g *Sno) RestoreIdGenerator(ctx context.Context, bytes []byte, tracer traci
 	return
 }
 
+func (g *Sno) RestoreId(bytes []byte) (result Id, err error) {
+	var id sno.ID
+	err = id.UnmarshalBinary(bytes)
+	if err != nil {
+		return
+	}
+	result = &SnoId{ID: id}
+	return
+}
+
 func (g *SnoGenerator) Snapshot() (result []byte, err error) {
 	result, err = json.Marshal(g.Generator.Snapshot())
 	return
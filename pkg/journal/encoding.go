@@ -0,0 +1,165 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package journal
+
+import (
+	"time"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+)
+
+// Entry types. These names are a part of the journal's format
+// and must never change.
+const (
+	InstantiationEntry    = "instantiation"
+	RestorationEntry      = "restoration"
+	TerminationEntry      = "termination"
+	NewFlowEntry          = "newFlow"
+	VisitEntry            = "visit"
+	FlowEntry             = "flow"
+	LinkEntry             = "link"
+	CompletionEntry       = "completion"
+	FlowTerminationEntry  = "flowTermination"
+	FlowCancellationEntry = "flowCancellation"
+	CeaseFlowEntry        = "ceaseFlow"
+	ActiveBoundaryEntry   = "activeBoundary"
+	DataEntry             = "data"
+)
+
+// Entry payloads. Flow nodes and sequence flows are referred
+// to by their IDs, flows by their ID bytes (see id.Id.Bytes)
+
+// InstanceData is a payload of InstantiationEntry and RestorationEntry
+type InstanceData struct {
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DataData is a payload of DataEntry
+type DataData struct {
+	// ID of the data object or property
+	Id   string      `json:"id"`
+	Item interface{} `json:"item"`
+}
+
+// NodeData is a payload of VisitEntry and CompletionEntry
+type NodeData struct {
+	Node string `json:"node"`
+}
+
+// NewFlowData is a payload of NewFlowEntry
+type NewFlowData struct {
+	FlowId []byte `json:"flowId"`
+	Node   string `json:"node,omitempty"`
+}
+
+// FlowData is a payload of FlowEntry
+type FlowData struct {
+	Source string             `json:"source"`
+	Flows  []SequenceFlowData `json:"flows"`
+}
+
+// SequenceFlowData describes a flow that went through a sequence flow
+type SequenceFlowData struct {
+	FlowId       []byte `json:"flowId"`
+	SequenceFlow string `json:"sequenceFlow"`
+	Target       string `json:"target"`
+}
+
+// LinkData is a payload of LinkEntry
+type LinkData struct {
+	FlowId []byte `json:"flowId"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// FlowTerminationData is a payload of FlowTerminationEntry
+// and FlowCancellationEntry
+type FlowTerminationData struct {
+	FlowId []byte `json:"flowId"`
+	Source string `json:"source,omitempty"`
+}
+
+// ActiveBoundaryData is a payload of ActiveBoundaryEntry
+type ActiveBoundaryData struct {
+	Start bool   `json:"start"`
+	Node  string `json:"node"`
+}
+
+// nodeId returns flow node's ID or an empty string if it has none
+func nodeId(node bpmn.FlowNodeInterface) string {
+	if node == nil {
+		return ""
+	}
+	if id, present := node.Id(); present {
+		return *id
+	}
+	return ""
+}
+
+// encode converts a trace into entry type and payload,
+// returns false if the trace is not journaled
+func encode(trace tracing.Trace) (entryType string, data interface{}, ok bool) {
+	ok = true
+	switch t := trace.(type) {
+	case instance.InstantiationTrace:
+		entryType = InstantiationEntry
+		data = InstanceData{CreatedAt: t.CreatedAt}
+	case instance.RestorationTrace:
+		entryType = RestorationEntry
+		data = InstanceData{CreatedAt: t.CreatedAt}
+	case instance.TerminationTrace:
+		entryType = TerminationEntry
+	case flow.NewFlowTrace:
+		entryType = NewFlowEntry
+		data = NewFlowData{FlowId: t.FlowId.Bytes(), Node: nodeId(t.Node)}
+	case flow.VisitTrace:
+		entryType = VisitEntry
+		data = NodeData{Node: nodeId(t.Node)}
+	case flow.FlowTrace:
+		entryType = FlowEntry
+		flowData := FlowData{Source: nodeId(t.Source), Flows: make([]SequenceFlowData, 0, len(t.Flows))}
+		for i := range t.Flows {
+			sequenceFlow := t.Flows[i].SequenceFlow()
+			sequenceFlowData := SequenceFlowData{FlowId: t.Flows[i].Id().Bytes()}
+			if id, present := sequenceFlow.Id(); present {
+				sequenceFlowData.SequenceFlow = *id
+			}
+			sequenceFlowData.Target = *sequenceFlow.TargetRef()
+			flowData.Flows = append(flowData.Flows, sequenceFlowData)
+		}
+		data = flowData
+	case flow.LinkTrace:
+		entryType = LinkEntry
+		data = LinkData{FlowId: t.FlowId.Bytes(), Source: nodeId(t.Source), Target: nodeId(t.Target)}
+	case flow.CompletionTrace:
+		entryType = CompletionEntry
+		data = NodeData{Node: nodeId(t.Node)}
+	case flow.FlowTerminationTrace:
+		entryType = FlowTerminationEntry
+		data = FlowTerminationData{FlowId: t.FlowId.Bytes(), Source: nodeId(t.Source)}
+	case flow.CancellationTrace:
+		entryType = FlowCancellationEntry
+		data = FlowTerminationData{FlowId: t.FlowId.Bytes()}
+	case flow.CeaseFlowTrace:
+		entryType = CeaseFlowEntry
+	case instance.DataTrace:
+		entryType = DataEntry
+		data = DataData{Id: t.Id, Item: t.Item}
+	case activity.ActiveBoundaryTrace:
+		entryType = ActiveBoundaryEntry
+		data = ActiveBoundaryData{Start: t.Start, Node: nodeId(t.Node)}
+	default:
+		ok = false
+	}
+	return
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/persistence"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+)
+
+// Version of the journal's format
+const Version = 1
+
+// Entry is a journaled trace, stored as a line of JSON
+type Entry struct {
+	// Version of the format the entry is encoded with
+	Version int `json:"v"`
+	// Sequence number of the entry, starting from 1
+	Sequence uint64 `json:"seq"`
+	// Time the entry was journaled at
+	Time time.Time `json:"time"`
+	// ID bytes of the instance the trace belongs to (see id.Id.Bytes)
+	InstanceId []byte `json:"instance"`
+	// ID of the process the instance belongs to, only recorded
+	// for instantiation and restoration entries
+	ProcessId string `json:"process,omitempty"`
+	// Entry type (see InstantiationEntry and others)
+	Type string `json:"type"`
+	// Type-specific payload (see NodeData and others)
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode unmarshals entry's payload into a given value
+func (entry *Entry) Decode(data interface{}) error {
+	return json.Unmarshal(entry.Data, data)
+}
+
+// Journal writes traces of process instances into an
+// append-only log in a local file
+//
+// Only traces that are related to instance's execution are
+// journaled (see InstantiationEntry and other entry types). Replayed
+// state of a running instance can be turned into a snapshot to restore
+// the instance from (see State.Snapshot).
+//
+// The log is read the same way as persistence.FileStore's (see
+// persistence.ReadLog): a partially written entry at the end is
+// discarded and other entries that can't be decoded are skipped.
+type Journal struct {
+	lock     sync.Mutex
+	file     *os.File
+	clock    clock.Clock
+	tracer   tracing.Tracer
+	sequence uint64
+}
+
+type Option func(*Journal)
+
+// WithTracer makes the journal trace entries that couldn't be
+// decoded and were skipped (as tracing.ErrorTrace with
+// persistence.CorruptRecordError) to a given tracer
+func WithTracer(tracer tracing.Tracer) Option {
+	return func(journal *Journal) {
+		journal.tracer = tracer
+	}
+}
+
+// Open opens a journal at a given path, creating it if it doesn't exist.
+// Writing continues after the last complete entry.
+func Open(ctx context.Context, path string, options ...Option) (journal *Journal, err error) {
+	journal = &Journal{}
+	for _, option := range options {
+		option(journal)
+	}
+	journal.clock, err = clock.FromContext(ctx)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+		err = nil
+	case err != nil:
+		return
+	default:
+		var entries []Entry
+		var size int64
+		entries, size, err = read(file, path, journal.tracer)
+		_ = file.Close()
+		if err != nil {
+			return
+		}
+		if len(entries) > 0 {
+			journal.sequence = entries[len(entries)-1].Sequence
+		}
+		// Discard partially written entry, if any
+		err = os.Truncate(path, size)
+		if err != nil {
+			return
+		}
+	}
+	journal.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	return
+}
+
+// Subscribe journals traces of a given tracer until
+// the context is done
+func (journal *Journal) Subscribe(ctx context.Context, tracer tracing.Tracer) {
+	traces := tracer.Subscribe()
+	sender := tracer.RegisterSender()
+	go func() {
+		defer sender.Done()
+		defer tracer.Unsubscribe(traces)
+		for {
+			select {
+			case trace := <-traces:
+				if err := journal.Append(trace); err != nil {
+					tracer.Trace(tracing.ErrorTrace{Error: err})
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Append journals a trace, unless it is not related
+// to any instance's execution
+func (journal *Journal) Append(trace tracing.Trace) (err error) {
+	trace, instanceTrace := instance.UnwrapTrace(trace)
+
+	// Instance lifecycle traces are not wrapped by the instance
+	entry := Entry{Version: Version}
+	switch t := trace.(type) {
+	case instance.InstantiationTrace:
+		entry.InstanceId = t.InstanceId.Bytes()
+		entry.ProcessId = processId(t.Process)
+	case instance.RestorationTrace:
+		entry.InstanceId = t.InstanceId.Bytes()
+		entry.ProcessId = processId(t.Process)
+	case instance.TerminationTrace:
+		entry.InstanceId = t.InstanceId.Bytes()
+	default:
+		if instanceTrace == nil {
+			return
+		}
+		entry.InstanceId = instanceTrace.InstanceId.Bytes()
+	}
+
+	entryType, data, ok := encode(trace)
+	if !ok {
+		return
+	}
+	entry.Type = entryType
+	if data != nil {
+		entry.Data, err = json.Marshal(data)
+		if err != nil {
+			return
+		}
+	}
+
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	if journal.file == nil {
+		err = errors.InvalidStateError{Expected: "open journal", Actual: "closed journal"}
+		return
+	}
+	entry.Sequence = journal.sequence + 1
+	entry.Time = journal.clock.Now()
+	line, err := json.Marshal(&entry)
+	if err != nil {
+		return
+	}
+	_, err = journal.file.Write(append(line, '\n'))
+	if err != nil {
+		return
+	}
+	journal.sequence = entry.Sequence
+	return
+}
+
+// processId returns the ID of a given process, if there's any
+func processId(process *bpmn.Process) (id string) {
+	if process == nil {
+		return
+	}
+	if processId, present := process.Id(); present {
+		id = *processId
+	}
+	return
+}
+
+// Close flushes the journal to the disk and closes it
+func (journal *Journal) Close() (err error) {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	if journal.file == nil {
+		return
+	}
+	err = journal.file.Sync()
+	if err != nil {
+		return
+	}
+	err = journal.file.Close()
+	journal.file = nil
+	return
+}
+
+// ReadFile reads all entries of a journal at a given path
+// (see Read)
+func ReadFile(path string, options ...Option) (entries []Entry, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+	journal := &Journal{}
+	for _, option := range options {
+		option(journal)
+	}
+	entries, _, err = read(file, path, journal.tracer)
+	return
+}
+
+// Read reads all journal entries from a reader
+//
+// A partially written entry at the end is ignored. Other entries that
+// can't be decoded are skipped (see WithTracer). Entries encoded with
+// an unsupported version of the format produce an error.
+func Read(r io.Reader, options ...Option) (entries []Entry, err error) {
+	journal := &Journal{}
+	for _, option := range options {
+		option(journal)
+	}
+	entries, _, err = read(r, "", journal.tracer)
+	return
+}
+
+// read reads all complete journal entries and returns their total size,
+// tracing entries that can't be decoded to a given tracer (unless it is nil)
+func read(r io.Reader, path string, tracer tracing.Tracer) (entries []Entry, size int64, err error) {
+	entries = make([]Entry, 0)
+	_, size, err = persistence.ReadLog(r, path, func(line []byte) (err error) {
+		var entry Entry
+		if err = json.Unmarshal(line, &entry); err == nil {
+			entries = append(entries, entry)
+		}
+		return
+	}, tracer)
+	if err != nil {
+		return
+	}
+	for i := range entries {
+		if entries[i].Version != Version {
+			err = errors.NotSupportedError{
+				What:   fmt.Sprintf("journal entry version %d", entries[i].Version),
+				Reason: fmt.Sprintf("only version %d is known", Version),
+			}
+			return
+		}
+	}
+	return
+}
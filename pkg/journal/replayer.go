@@ -0,0 +1,272 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package journal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/process/instance"
+)
+
+// Flow is a position of a live flow
+type Flow struct {
+	FlowId []byte
+	// ID of the flow node the flow is at
+	Node string
+	// ID of the sequence flow the flow arrived through, empty if
+	// the flow has started at the flow node or followed a link to it
+	SequenceFlow string
+}
+
+// State is the state of an instance rebuilt from the journal
+type State struct {
+	InstanceId []byte
+	// ID of the process the instance belongs to, if known
+	ProcessId string
+	Status    instance.Status
+	// Time of instance's creation, if known
+	CreatedAt time.Time
+	// Time of the first entry of the instance
+	StartedAt time.Time
+	// Time of the last entry of the instance
+	UpdatedAt time.Time
+	// Live flows, keyed by flow ID bytes
+	Flows map[string]Flow
+	// IDs of visited flow nodes, in order of visiting
+	Visited []string
+	// Number of currently active executions of activities,
+	// keyed by activity ID
+	ActiveActivities map[string]int
+	// Values of process' data objects and properties, keyed by their IDs
+	// (as JSON round-trip leaves them, see instance.Snapshot)
+	Data map[string]interface{}
+	// Sequence number of the last entry of the instance
+	Sequence uint64
+}
+
+// SortedFlows returns live flows ordered by their IDs
+func (state *State) SortedFlows() (flows []Flow) {
+	keys := make([]string, 0, len(state.Flows))
+	for key := range state.Flows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	flows = make([]Flow, 0, len(keys))
+	for _, key := range keys {
+		flows = append(flows, state.Flows[key])
+	}
+	return
+}
+
+// Snapshot converts the state of a running instance into its snapshot,
+// which allows to restore the instance (see process.Process.Restore),
+// for example, after a crash
+//
+// Flows are captured the same way instance.Instance.Snapshot captures
+// them (see instance.SnapshotFlows) and timers are considered to have
+// fired up until the last entry of the instance. The journal doesn't
+// keep the state of instance's ID generator, so the restored instance
+// starts a new one.
+func (state *State) Snapshot(process *bpmn.Process) (snapshot *instance.Snapshot, err error) {
+	if state.Status != instance.Running {
+		err = errors.InvalidStateError{Expected: instance.Running.String(), Actual: state.Status.String()}
+		return
+	}
+	if processId, present := process.Id(); !present || *processId != state.ProcessId {
+		err = errors.InvalidArgumentError{
+			Expected: fmt.Sprintf("process %s", state.ProcessId),
+			Actual:   process,
+		}
+		return
+	}
+	flows := make([]instance.FlowSnapshot, 0, len(state.Flows))
+	for _, flow := range state.SortedFlows() {
+		flows = append(flows, instance.FlowSnapshot{
+			FlowId:       flow.FlowId,
+			Node:         flow.Node,
+			SequenceFlow: flow.SequenceFlow,
+		})
+	}
+	snapshot = &instance.Snapshot{
+		ProcessId:  state.ProcessId,
+		InstanceId: state.InstanceId,
+		CreatedAt:  state.CreatedAt,
+		TakenAt:    state.UpdatedAt,
+		Flows:      instance.SnapshotFlows(process, flows),
+		Data:       make(map[string]interface{}, len(state.Data)),
+	}
+	if snapshot.CreatedAt.IsZero() {
+		snapshot.CreatedAt = state.StartedAt
+	}
+	for dataId, item := range state.Data {
+		snapshot.Data[dataId] = item
+	}
+	return
+}
+
+// Replayer rebuilds states of instances by applying
+// journal entries in order
+type Replayer struct {
+	sequence uint64
+	states   map[string]*State
+	order    []string
+}
+
+func NewReplayer() *Replayer {
+	return &Replayer{
+		states: make(map[string]*State),
+		order:  make([]string, 0),
+	}
+}
+
+// Replay applies all given entries to a new replayer
+func Replay(entries []Entry) (replayer *Replayer, err error) {
+	replayer = NewReplayer()
+	for i := range entries {
+		err = replayer.Apply(&entries[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Apply applies an entry to the state of its instance
+//
+// Entries must be applied in the order of their sequence numbers.
+func (replayer *Replayer) Apply(entry *Entry) (err error) {
+	if entry.Sequence <= replayer.sequence {
+		err = errors.InvalidArgumentError{
+			Expected: fmt.Sprintf("entry with sequence number above %d", replayer.sequence),
+			Actual:   entry.Sequence,
+		}
+		return
+	}
+	replayer.sequence = entry.Sequence
+
+	key := string(entry.InstanceId)
+	state, present := replayer.states[key]
+	if !present {
+		state = &State{
+			InstanceId:       entry.InstanceId,
+			StartedAt:        entry.Time,
+			Flows:            make(map[string]Flow),
+			Visited:          make([]string, 0),
+			ActiveActivities: make(map[string]int),
+			Data:             make(map[string]interface{}),
+		}
+		replayer.states[key] = state
+		replayer.order = append(replayer.order, key)
+	}
+	if state.ProcessId == "" {
+		state.ProcessId = entry.ProcessId
+	}
+	state.UpdatedAt = entry.Time
+	state.Sequence = entry.Sequence
+
+	switch entry.Type {
+	case InstantiationEntry, RestorationEntry:
+		state.Status = instance.Running
+		if len(entry.Data) > 0 {
+			var data InstanceData
+			if err = entry.Decode(&data); err != nil {
+				return
+			}
+			state.CreatedAt = data.CreatedAt
+		}
+	case TerminationEntry:
+		state.Status = instance.Terminated
+		state.Flows = make(map[string]Flow)
+	case CeaseFlowEntry:
+		state.Status = instance.Completed
+		state.Flows = make(map[string]Flow)
+	case NewFlowEntry:
+		var data NewFlowData
+		if err = entry.Decode(&data); err != nil {
+			return
+		}
+		if _, present := state.Flows[string(data.FlowId)]; !present {
+			state.Flows[string(data.FlowId)] = Flow{FlowId: data.FlowId, Node: data.Node}
+		}
+	case VisitEntry:
+		var data NodeData
+		if err = entry.Decode(&data); err != nil {
+			return
+		}
+		state.Visited = append(state.Visited, data.Node)
+	case FlowEntry:
+		var data FlowData
+		if err = entry.Decode(&data); err != nil {
+			return
+		}
+		for _, flow := range data.Flows {
+			state.Flows[string(flow.FlowId)] = Flow{
+				FlowId:       flow.FlowId,
+				Node:         flow.Target,
+				SequenceFlow: flow.SequenceFlow,
+			}
+		}
+	case LinkEntry:
+		var data LinkData
+		if err = entry.Decode(&data); err != nil {
+			return
+		}
+		state.Flows[string(data.FlowId)] = Flow{FlowId: data.FlowId, Node: data.Target}
+	case FlowTerminationEntry, FlowCancellationEntry:
+		var data FlowTerminationData
+		if err = entry.Decode(&data); err != nil {
+			return
+		}
+		delete(state.Flows, string(data.FlowId))
+	case ActiveBoundaryEntry:
+		var data ActiveBoundaryData
+		if err = entry.Decode(&data); err != nil {
+			return
+		}
+		if data.Start {
+			state.ActiveActivities[data.Node]++
+		} else if state.ActiveActivities[data.Node]--; state.ActiveActivities[data.Node] <= 0 {
+			delete(state.ActiveActivities, data.Node)
+		}
+	case DataEntry:
+		var data DataData
+		if err = entry.Decode(&data); err != nil {
+			return
+		}
+		state.Data[data.Id] = data.Item
+	case CompletionEntry:
+	default:
+		err = errors.NotSupportedError{
+			What:   fmt.Sprintf("journal entry type %s", entry.Type),
+			Reason: "it is unknown",
+		}
+	}
+	return
+}
+
+// State returns the state of a given instance
+func (replayer *Replayer) State(instanceId id.Id) (state *State, found bool) {
+	state, found = replayer.states[string(instanceId.Bytes())]
+	return
+}
+
+// States returns states of all instances, in the order
+// of their first entries
+func (replayer *Replayer) States() (states []*State) {
+	states = make([]*State, 0, len(replayer.order))
+	for _, key := range replayer.order {
+		states = append(states, replayer.states[key])
+	}
+	return
+}
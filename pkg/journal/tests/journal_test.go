@@ -0,0 +1,315 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/journal"
+	"bpxe.org/pkg/persistence"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/timer"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+var linkDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/journal.bpmn", testdata, &testDoc)
+	internal.LoadTestFile("testdata/link.bpmn", testdata, &linkDoc)
+}
+
+// replay rebuilds the state of the only instance in the journal
+func replay(t *testing.T, path string) *journal.State {
+	entries, err := journal.ReadFile(path)
+	require.Nil(t, err)
+	replayer, err := journal.Replay(entries)
+	require.Nil(t, err)
+	states := replayer.States()
+	if len(states) == 0 {
+		return nil
+	}
+	require.Len(t, states, 1)
+	return states[0]
+}
+
+func TestJournalReplay(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "journal.log")
+
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	j, err := journal.Open(ctx, path)
+	require.Nil(t, err)
+	defer func() { _ = j.Close() }()
+	j.Subscribe(ctx, tracer)
+
+	fanOut := event.NewFanOut()
+	proc := process.New(&(*testDoc.Processes())[0], &testDoc,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithEventDefinitionInstanceBuilder(event.DefinitionInstanceBuildingChain(
+			timer.EventDefinitionInstanceBuilder(ctx, fanOut, tracer),
+		)),
+		process.WithTracer(tracer),
+	)
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	err = inst.StartAll(ctx)
+	require.Nil(t, err)
+
+	for {
+		trace := tracing.Unwrap(<-traces)
+		if _, ok := trace.(catch.ActiveListeningTrace); ok {
+			break
+		}
+	}
+
+	// One flow waits for the timer and another
+	// one waits for it at the joining gateway
+	require.Eventually(t, func() bool {
+		state := replay(t, path)
+		if state == nil || len(state.Flows) != 2 {
+			return false
+		}
+		nodes := make([]string, 0)
+		for _, flow := range state.SortedFlows() {
+			nodes = append(nodes, flow.Node)
+		}
+		sort.Strings(nodes)
+		return assert.ObjectsAreEqual([]string{"join", "wait"}, nodes)
+	}, 5*time.Second, 10*time.Millisecond)
+	state := replay(t, path)
+	assert.Equal(t, inst.Id().Bytes(), state.InstanceId)
+	assert.Equal(t, "proc", state.ProcessId)
+	assert.Equal(t, instance.Running, state.Status)
+	assert.Contains(t, state.Visited, "prepare")
+	assert.Empty(t, state.ActiveActivities)
+
+	c.Add(1 * time.Hour)
+
+	require.Eventually(t, func() bool {
+		state := replay(t, path)
+		return state.Status == instance.Completed
+	}, 5*time.Second, 10*time.Millisecond)
+	state = replay(t, path)
+	assert.Empty(t, state.Flows)
+	assert.Equal(t, "end", state.Visited[len(state.Visited)-1])
+}
+
+func TestJournalReopening(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "journal.log")
+
+	line := `{"v":1,"seq":1,"time":"2021-01-01T00:00:00Z","instance":"AQ==","type":"instantiation"}` + "\n"
+	// The second entry was only partially written
+	err := os.WriteFile(path, []byte(line+`{"v":1,"seq":2,"ti`), 0644)
+	require.Nil(t, err)
+
+	j, err := journal.Open(ctx, path)
+	require.Nil(t, err)
+	generator, err := id.DefaultIdGeneratorBuilder.NewIdGenerator(ctx, tracing.NewTracer(ctx))
+	require.Nil(t, err)
+	instanceId := generator.New()
+	err = j.Append(instance.TerminationTrace{InstanceId: instanceId})
+	require.Nil(t, err)
+	require.Nil(t, j.Close())
+
+	entries, err := journal.ReadFile(path)
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint64(2), entries[1].Sequence)
+	assert.Equal(t, journal.TerminationEntry, entries[1].Type)
+	assert.Equal(t, instanceId.Bytes(), entries[1].InstanceId)
+}
+
+func TestJournalCorruptEntry(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "journal.log")
+
+	first := `{"v":1,"seq":1,"time":"2021-01-01T00:00:00Z","instance":"AQ==","type":"instantiation"}` + "\n"
+	third := `{"v":1,"seq":3,"time":"2021-01-01T00:00:02Z","instance":"AQ==","type":"ceaseFlow"}` + "\n"
+	// The second entry was corrupted after it has been written
+	err := os.WriteFile(path, []byte(first+`{"v":1,"seq":2,"ti}`+"\n"+third), 0644)
+	require.Nil(t, err)
+
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 8))
+	j, err := journal.Open(ctx, path, journal.WithTracer(tracer))
+	require.Nil(t, err)
+	trace := (<-traces).(tracing.ErrorTrace)
+	corrupt, ok := trace.Error.(persistence.CorruptRecordError)
+	require.True(t, ok)
+	assert.Equal(t, path, corrupt.Path)
+	assert.Equal(t, int64(len(first)), corrupt.Offset)
+
+	// Writing continues after the last entry
+	generator, err := id.DefaultIdGeneratorBuilder.NewIdGenerator(ctx, tracing.NewTracer(ctx))
+	require.Nil(t, err)
+	err = j.Append(instance.TerminationTrace{InstanceId: generator.New()})
+	require.Nil(t, err)
+	require.Nil(t, j.Close())
+
+	entries, err := journal.ReadFile(path)
+	require.Nil(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, uint64(1), entries[0].Sequence)
+	assert.Equal(t, uint64(3), entries[1].Sequence)
+	assert.Equal(t, uint64(4), entries[2].Sequence)
+	assert.Equal(t, journal.TerminationEntry, entries[2].Type)
+}
+
+func TestJournalLink(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "journal.log")
+
+	j, err := journal.Open(ctx, path)
+	require.Nil(t, err)
+	generator, err := id.DefaultIdGeneratorBuilder.NewIdGenerator(ctx, tracing.NewTracer(ctx))
+	require.Nil(t, err)
+	instanceId, flowId := generator.New(), generator.New()
+	source, found := linkDoc.FindBy(bpmn.ExactId("toWait"))
+	require.True(t, found)
+	target, found := linkDoc.FindBy(bpmn.ExactId("fromStart"))
+	require.True(t, found)
+	for _, trace := range []tracing.Trace{
+		instance.InstantiationTrace{InstanceId: instanceId},
+		instance.Trace{InstanceId: instanceId, Trace: flow.NewFlowTrace{FlowId: flowId,
+			Node: source.(bpmn.FlowNodeInterface)}},
+		instance.Trace{InstanceId: instanceId, Trace: flow.LinkTrace{FlowId: flowId,
+			Source: source.(bpmn.FlowNodeInterface), Target: target.(bpmn.FlowNodeInterface)}},
+	} {
+		require.Nil(t, j.Append(trace))
+	}
+	require.Nil(t, j.Close())
+
+	// The flow is at the link catch event rather than at the link
+	// throw event, and has not arrived through a sequence flow
+	state := replay(t, path)
+	assert.Equal(t, []journal.Flow{{FlowId: flowId.Bytes(), Node: "fromStart"}}, state.SortedFlows())
+}
+
+func TestJournalUnsupportedVersion(t *testing.T) {
+	_, err := journal.Read(strings.NewReader(
+		`{"v":2,"seq":1,"time":"2021-01-01T00:00:00Z","instance":"AQ==","type":"instantiation"}` + "\n"))
+	require.NotNil(t, err)
+	assert.IsType(t, errors.NotSupportedError{}, err)
+}
+
+func TestJournalRestoration(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "journal.log")
+
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	j, err := journal.Open(ctx, path)
+	require.Nil(t, err)
+	defer func() { _ = j.Close() }()
+	j.Subscribe(ctx, tracer)
+
+	fanOut := event.NewFanOut()
+	proc := process.New(&(*testDoc.Processes())[0], &testDoc,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithEventDefinitionInstanceBuilder(event.DefinitionInstanceBuildingChain(
+			timer.EventDefinitionInstanceBuilder(ctx, fanOut, tracer),
+		)),
+		process.WithTracer(tracer),
+	)
+	originalCtx, originalCancel := context.WithCancel(ctx)
+	original, err := proc.Instantiate(instance.WithContext(originalCtx))
+	require.Nil(t, err)
+	itemAware, found := original.FindItemAwareByName("order")
+	require.True(t, found)
+	<-itemAware.Put(ctx, "o-1")
+	err = original.StartAll(ctx)
+	require.Nil(t, err)
+
+	require.Eventually(t, func() bool {
+		state := replay(t, path)
+		if state == nil || len(state.Flows) != 2 || state.Data["order"] != "o-1" {
+			return false
+		}
+		nodes := make([]string, 0)
+		for _, flow := range state.SortedFlows() {
+			nodes = append(nodes, flow.Node)
+		}
+		sort.Strings(nodes)
+		return assert.ObjectsAreEqual([]string{"join", "wait"}, nodes)
+	}, 5*time.Second, 10*time.Millisecond)
+	// The instance is stopped as if its host went down
+	originalCancel()
+
+	state := replay(t, path)
+	snapshot, err := state.Snapshot(&(*testDoc.Processes())[0])
+	require.Nil(t, err)
+	restored, err := proc.Restore(snapshot, instance.WithContext(ctx))
+	require.Nil(t, err)
+	assert.Equal(t, original.Id().String(), restored.Id().String())
+
+	itemAware, found = restored.FindItemAwareByName("order")
+	require.True(t, found)
+	assert.Equal(t, "o-1", <-itemAware.Get(ctx))
+
+	restoredTraces := false
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace.(type) {
+		case instance.RestorationTrace:
+			restoredTraces = true
+		case catch.ActiveListeningTrace:
+			if restoredTraces {
+				c.Add(1 * time.Hour)
+			}
+		case instance.CompletionTrace:
+			if restoredTraces {
+				require.Eventually(t, func() bool {
+					return replay(t, path).Status == instance.Completed
+				}, 5*time.Second, 10*time.Millisecond)
+				return
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		}
+	}
+}
+
+func TestJournalSnapshotOfCompletedInstance(t *testing.T) {
+	entries, err := journal.Read(strings.NewReader(
+		`{"v":1,"seq":1,"time":"2021-01-01T00:00:00Z","instance":"AQ==","type":"instantiation"}` + "\n" +
+			`{"v":1,"seq":2,"time":"2021-01-01T00:00:01Z","instance":"AQ==","type":"ceaseFlow"}` + "\n"))
+	require.Nil(t, err)
+	replayer, err := journal.Replay(entries)
+	require.Nil(t, err)
+	_, err = replayer.States()[0].Snapshot(&(*testDoc.Processes())[0])
+	assert.IsType(t, errors.InvalidStateError{}, err)
+}
@@ -10,12 +10,19 @@ package model
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
 	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow_node/activity/service_task"
+	"bpxe.org/pkg/flow_node/activity/user_task"
+	"bpxe.org/pkg/history"
 	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/persistence"
 	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
 	"bpxe.org/pkg/timer"
 	"bpxe.org/pkg/tracing"
 )
@@ -28,6 +35,12 @@ type Model struct {
 	idGeneratorBuilder             id.GeneratorBuilder
 	eventDefinitionInstanceBuilder event.DefinitionInstanceBuilder
 	tracer                         tracing.Tracer
+	serviceTaskRegistry            *service_task.Registry
+	inbox                          user_task.Inbox
+	store                          persistence.Store
+	history                        history.Store
+	archiveCapacity                int
+	compensateOnCancel             bool
 }
 
 type Option func(context.Context, *Model) context.Context
@@ -46,6 +59,73 @@ func WithEventDefinitionInstanceBuilder(builder event.DefinitionInstanceBuilder)
 	}
 }
 
+// WithServiceTaskRegistry sets a registry of handlers used by
+// service tasks of all processes in the model
+func WithServiceTaskRegistry(registry *service_task.Registry) Option {
+	return func(ctx context.Context, model *Model) context.Context {
+		model.serviceTaskRegistry = registry
+		return ctx
+	}
+}
+
+// WithInbox sets an inbox user tasks of all processes
+// in the model put their work items into
+func WithInbox(inbox user_task.Inbox) Option {
+	return func(ctx context.Context, model *Model) context.Context {
+		model.inbox = inbox
+		return ctx
+	}
+}
+
+// WithCompensationOnCancel makes cancellation of every instance of
+// model's processes compensate its completed activities first (see
+// instance.WithCompensationOnCancel)
+func WithCompensationOnCancel() Option {
+	return func(ctx context.Context, model *Model) context.Context {
+		model.compensateOnCancel = true
+		return ctx
+	}
+}
+
+// WithStore sets a store that records the state of all instances
+// of model's processes. Instances that haven't completed are restored
+// from it when the model is run.
+func WithStore(store persistence.Store) Option {
+	return func(ctx context.Context, model *Model) context.Context {
+		model.store = store
+		return ctx
+	}
+}
+
+// WithHistory sets a store of execution records for expressions
+// to query (see history.ToContext). It is available to instances
+// the model instantiates or restores (see Run).
+//
+// The store is not populated by the model, it is up to the caller
+// to record model's traces in it.
+func WithHistory(store history.Store) Option {
+	return func(ctx context.Context, model *Model) context.Context {
+		model.history = store
+		return ctx
+	}
+}
+
+// WithArchiveCapacity sets the number of complete instances each
+// of model's processes keeps track of (process.DefaultArchiveCapacity
+// by default)
+//
+// Zero (or negative) capacity makes the processes forget
+// instances as soon as they complete.
+func WithArchiveCapacity(capacity int) Option {
+	return func(ctx context.Context, model *Model) context.Context {
+		if capacity < 0 {
+			capacity = 0
+		}
+		model.archiveCapacity = capacity
+		return ctx
+	}
+}
+
 // WithContext will pass a given context to a new model
 // instead of implicitly generated one
 func WithContext(newCtx context.Context) Option {
@@ -65,7 +145,8 @@ func WithTracer(tracer tracing.Tracer) Option {
 func New(element *bpmn.Definitions, options ...Option) *Model {
 	procs := element.Processes()
 	model := &Model{
-		Element: element,
+		Element:         element,
+		archiveCapacity: process.DefaultArchiveCapacity,
 	}
 
 	ctx := context.Background()
@@ -78,6 +159,14 @@ func New(element *bpmn.Definitions, options ...Option) *Model {
 		model.idGeneratorBuilder = id.DefaultIdGeneratorBuilder
 	}
 
+	if model.serviceTaskRegistry == nil {
+		model.serviceTaskRegistry = service_task.NewRegistry()
+	}
+
+	if model.inbox == nil {
+		model.inbox = user_task.NewInbox()
+	}
+
 	if model.tracer == nil {
 		model.tracer = tracing.NewTracer(ctx)
 	}
@@ -91,19 +180,51 @@ func New(element *bpmn.Definitions, options ...Option) *Model {
 
 	model.processes = make([]process.Process, len(*procs))
 
+	processOptions := []process.Option{
+		process.WithIdGenerator(model.idGeneratorBuilder),
+		process.WithEventIngress(model), process.WithEventEgress(model),
+		process.WithEventDefinitionInstanceBuilder(model),
+		process.WithContext(ctx),
+		process.WithTracer(model.tracer),
+		process.WithServiceTaskRegistry(model.serviceTaskRegistry),
+		process.WithInbox(model.inbox),
+		process.WithArchiveCapacity(model.archiveCapacity),
+	}
+	if model.compensateOnCancel {
+		processOptions = append(processOptions, process.WithCompensationOnCancel())
+	}
+	if model.store != nil {
+		processOptions = append(processOptions, process.WithInstanceObserver(&storeObserver{
+			store:  model.store,
+			tracer: model.tracer,
+		}))
+	}
+
 	for i := range *procs {
-		model.processes[i] = process.Make(&(*procs)[i], element,
-			process.WithIdGenerator(model.idGeneratorBuilder),
-			process.WithEventIngress(model), process.WithEventEgress(model),
-			process.WithEventDefinitionInstanceBuilder(model),
-			process.WithContext(ctx),
-			process.WithTracer(model.tracer),
-		)
+		model.processes[i] = process.Make(&(*procs)[i], element, processOptions...)
 	}
 	return model
 }
 
+// Run restores unfinished instances (if there's a store) and starts
+// instantiating processes when events their start events or receive
+// tasks wait for occur
+//
+// Conditional start events don't instantiate processes
+// (see package conditional).
 func (model *Model) Run(ctx context.Context) (err error) {
+	if model.history != nil {
+		ctx = history.ToContext(ctx, model.history)
+	}
+	// Restore unfinished instances first, so that they
+	// can consume events right away
+	if model.store != nil {
+		err = model.restore(ctx)
+		if err != nil {
+			return
+		}
+	}
+
 	// Setup process instantiation
 	for i := range *model.Element.Processes() {
 		instantiatingFlowNodes := (*model.Element.Processes())[i].InstantiatingFlowNodes()
@@ -121,12 +242,60 @@ func (model *Model) Run(ctx context.Context) (err error) {
 				}
 			case *bpmn.EventBasedGateway:
 			case *bpmn.ReceiveTask:
+				err = model.RegisterEventConsumer(newReceiveTaskConsumer(ctx,
+					model.tracer,
+					&model.processes[i],
+					node, model.eventDefinitionInstanceBuilder))
+				if err != nil {
+					return
+				}
 			}
 		}
 	}
 	return
 }
 
+// restore restores all unfinished instances recorded in model's store
+func (model *Model) restore(ctx context.Context) (err error) {
+	var snapshots []*instance.Snapshot
+	snapshots, err = model.store.Unfinished()
+	if err != nil {
+		return
+	}
+	for _, snapshot := range snapshots {
+		processId := snapshot.ProcessId
+		proc, found := model.FindProcessBy(func(p *process.Process) bool {
+			id, present := p.Element.Id()
+			return present && *id == processId
+		})
+		if !found {
+			err = errors.NotFoundError{Expected: fmt.Sprintf("process %s", processId)}
+			return
+		}
+		_, err = proc.Restore(snapshot,
+			instance.WithContext(ctx),
+			instance.WithTracer(model.tracer),
+			instance.WithEventDefinitionInstanceBuilder(model),
+		)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ServiceTaskRegistry returns a registry of handlers used by
+// service tasks of all processes in the model
+func (model *Model) ServiceTaskRegistry() *service_task.Registry {
+	return model.serviceTaskRegistry
+}
+
+// Inbox returns an inbox with work items of user tasks
+// of all processes in the model
+func (model *Model) Inbox() user_task.Inbox {
+	return model.inbox
+}
+
 func (model *Model) FindProcessBy(f func(*process.Process) bool) (result *process.Process, found bool) {
 	for i := range model.processes {
 		if f(&model.processes[i]) {
@@ -138,12 +307,47 @@ func (model *Model) FindProcessBy(f func(*process.Process) bool) (result *proces
 	return
 }
 
+// FindInstance returns an instance of any of model's processes
+// with a given ID (see process.Process.FindInstance)
+func (model *Model) FindInstance(instanceId id.Id) (inst *instance.Instance, found bool) {
+	for i := range model.processes {
+		if inst, found = model.processes[i].FindInstance(instanceId); found {
+			return
+		}
+	}
+	return
+}
+
+// Instances returns instances of all model's processes, process
+// by process (see process.Process.Instances)
+func (model *Model) Instances(statuses ...instance.Status) (instances []*instance.Instance) {
+	instances = make([]*instance.Instance, 0)
+	for i := range model.processes {
+		instances = append(instances, model.processes[i].Instances(statuses...)...)
+	}
+	return
+}
+
 func (model *Model) ConsumeEvent(ev event.Event) (result event.ConsumptionResult, err error) {
 	model.eventConsumersLock.RLock()
 	// We're copying the list of consumers here to ensure that
 	// new consumers can subscribe during event forwarding
 	eventConsumers := model.eventConsumers
 	model.eventConsumersLock.RUnlock()
+	if messageEvent, ok := ev.(*event.MessageEvent); ok {
+		// If there are instances that the message correlates with,
+		// it is delivered only to them (and, therefore, doesn't
+		// instantiate any processes)
+		correlated := make([]event.Consumer, 0)
+		for _, consumer := range eventConsumers {
+			if inst, ok := consumer.(*instance.Instance); ok && inst.CorrelatesWith(messageEvent) {
+				correlated = append(correlated, consumer)
+			}
+		}
+		if len(correlated) > 0 {
+			eventConsumers = correlated
+		}
+	}
 	result, err = event.ForwardEvent(ev, &eventConsumers)
 	return
 }
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package model
+
+import (
+	"context"
+	"sync"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow_node/activity/receive_task"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+)
+
+// receiveTaskConsumer instantiates the process every time
+// a message matching an instantiating receive task arrives
+type receiveTaskConsumer struct {
+	process              *process.Process
+	ctx                  context.Context
+	consumptionLock      sync.Mutex
+	tracer               tracing.Tracer
+	element              *bpmn.ReceiveTask
+	definitionInstance   event.DefinitionInstance
+	eventInstanceBuilder event.DefinitionInstanceBuilder
+}
+
+func newReceiveTaskConsumer(
+	ctx context.Context,
+	tracer tracing.Tracer,
+	process *process.Process,
+	receiveTask *bpmn.ReceiveTask,
+	eventDefinitionInstanceBuilder event.DefinitionInstanceBuilder) *receiveTaskConsumer {
+	consumer := &receiveTaskConsumer{
+		ctx:                  ctx,
+		process:              process,
+		tracer:               tracer,
+		element:              receiveTask,
+		definitionInstance:   event.WrapEventDefinition(receive_task.EventDefinition(receiveTask)),
+		eventInstanceBuilder: eventDefinitionInstanceBuilder,
+	}
+	return consumer
+}
+
+func (s *receiveTaskConsumer) ConsumeEvent(ev event.Event) (result event.ConsumptionResult, err error) {
+	messageEvent, ok := ev.(*event.MessageEvent)
+	if !ok || !messageEvent.MatchesEventInstance(s.definitionInstance) {
+		return
+	}
+
+	s.consumptionLock.Lock()
+	defer s.consumptionLock.Unlock()
+	defer s.tracer.Trace(EventInstantiationAttemptedTrace{Event: ev, Element: s.element})
+
+	var inst *instance.Instance
+	inst, err = s.process.Instantiate(
+		instance.WithContext(s.ctx),
+		instance.WithTracer(s.tracer),
+		instance.WithEventDefinitionInstanceBuilder(s.eventInstanceBuilder),
+	)
+	if err != nil {
+		result = event.ConsumptionError
+		return
+	}
+	err = inst.StartWithReceiveTask(s.ctx, s.element)
+	if err != nil {
+		result = event.ConsumptionError
+		return
+	}
+	result, err = inst.ConsumeEvent(ev)
+	if err != nil {
+		result = event.ConsumptionError
+	}
+	return
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package model
+
+import (
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/persistence"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+)
+
+// storeObserver records changes of state of model's
+// instances into a store
+type storeObserver struct {
+	store  persistence.Store
+	tracer tracing.Tracer
+}
+
+func (observer *storeObserver) InstanceCreated(snapshot *instance.Snapshot) {
+	if err := observer.store.RecordCreation(snapshot); err != nil {
+		observer.tracer.Trace(tracing.ErrorTrace{Error: err})
+	}
+}
+
+func (observer *storeObserver) InstanceChanged(snapshot *instance.Snapshot) {
+	if err := observer.store.RecordSnapshot(snapshot); err != nil {
+		observer.tracer.Trace(tracing.ErrorTrace{Error: err})
+	}
+}
+
+func (observer *storeObserver) InstanceCompleted(instanceId id.Id) {
+	if err := observer.store.RecordCompletion(instanceId); err != nil {
+		observer.tracer.Trace(tracing.ErrorTrace{Error: err})
+	}
+}
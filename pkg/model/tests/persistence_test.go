@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/persistence"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPersistence bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/persistence.bpmn", testdata, &testPersistence)
+}
+
+func TestPersistence(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "instances.log")
+
+	// First run, stopped while the instance waits for the timer
+	store, err := persistence.NewFileStore(path)
+	require.Nil(t, err)
+	firstCtx, firstCancel := context.WithCancel(ctx)
+	m := model.New(&testPersistence, model.WithContext(firstCtx), model.WithStore(store))
+	err = m.Run(firstCtx)
+	require.Nil(t, err)
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+	inst, err := proc.Instantiate(instance.WithContext(firstCtx))
+	require.Nil(t, err)
+	itemAware, found := inst.FindItemAwareByName("order")
+	require.True(t, found)
+	<-itemAware.Put(firstCtx, "o-1")
+	err = inst.StartAll(firstCtx)
+	require.Nil(t, err)
+
+	require.Eventually(t, func() bool {
+		unfinished, err := store.Unfinished()
+		require.Nil(t, err)
+		return len(unfinished) == 1 && len(unfinished[0].Flows) == 1 &&
+			unfinished[0].Flows[0].Node == "wait"
+	}, 5*time.Second, 10*time.Millisecond)
+	unfinished, err := store.Unfinished()
+	require.Nil(t, err)
+	assert.Equal(t, inst.Id().Bytes(), unfinished[0].InstanceId)
+	assert.Equal(t, "o-1", unfinished[0].Data["order"])
+
+	firstCancel()
+	require.Nil(t, store.Close())
+
+	// Second run restores the instance and completes it
+	store, err = persistence.NewFileStore(path)
+	require.Nil(t, err)
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	m = model.New(&testPersistence, model.WithContext(ctx), model.WithStore(store), model.WithTracer(tracer))
+	err = m.Run(ctx)
+	require.Nil(t, err)
+
+	restored := false
+loop:
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case instance.RestorationTrace:
+			restored = true
+		case catch.ActiveListeningTrace:
+			c.Add(1 * time.Hour)
+		case flow.CompletionTrace:
+			if id, present := trace.Node.Id(); present && *id == "end" {
+				break loop
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+	assert.True(t, restored)
+
+	require.Eventually(t, func() bool {
+		unfinished, err := store.Unfinished()
+		require.Nil(t, err)
+		return len(unfinished) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, store.Close())
+
+	// Completion has been persisted
+	store, err = persistence.NewFileStore(path)
+	require.Nil(t, err)
+	defer func() { _ = store.Close() }()
+	unfinished, err = store.Unfinished()
+	require.Nil(t, err)
+	assert.Empty(t, unfinished)
+}
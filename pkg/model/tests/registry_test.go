@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRegistry bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/registry.bpmn", testdata, &testRegistry)
+}
+
+func TestInstanceRegistry(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	m := model.New(&testRegistry, model.WithContext(ctx), model.WithTracer(tracer),
+		model.WithArchiveCapacity(1))
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+
+	first, err := proc.Instantiate(instance.WithContext(ctx))
+	require.Nil(t, err)
+	require.Nil(t, first.StartAll(ctx))
+	second, err := proc.Instantiate(instance.WithContext(ctx))
+	require.Nil(t, err)
+	require.Nil(t, second.StartAll(ctx))
+	for listening := 0; listening < 2; {
+		if _, ok := tracing.Unwrap(<-traces).(catch.ActiveListeningTrace); ok {
+			listening++
+		}
+	}
+	require.Nil(t, second.Suspend())
+
+	assert.Equal(t, []*instance.Instance{first, second}, proc.Instances())
+	assert.Equal(t, []*instance.Instance{first}, m.Instances(instance.Running))
+	assert.Equal(t, []*instance.Instance{second}, m.Instances(instance.Suspended))
+	assert.Equal(t, []*instance.Instance{first, second},
+		m.Instances(instance.Running, instance.Suspended))
+
+	c.Add(1 * time.Hour)
+	// Completed instance is archived shortly after its completion
+	require.Eventually(t, func() bool {
+		instances := proc.Instances()
+		return len(instances) == 2 && instances[0] == second && instances[1] == first
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []*instance.Instance{first}, proc.Instances(instance.Completed))
+
+	// Archive only keeps the most recently completed instance
+	require.Nil(t, second.Resume())
+	require.Eventually(t, func() bool {
+		completed := proc.Instances(instance.Completed)
+		return len(completed) == 1 && completed[0] == second
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []*instance.Instance{second}, m.Instances())
+	_, found = m.FindInstance(first.Id())
+	assert.False(t, found)
+	inst, found := m.FindInstance(second.Id())
+	require.True(t, found)
+	assert.Equal(t, second, inst)
+}
+
+func TestInstanceRegistryInstantiation(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	m := model.New(&testTimerStartEventInstantiation, model.WithContext(ctx))
+	err := m.Run(ctx)
+	require.Nil(t, err)
+	assert.Empty(t, m.Instances())
+
+	// Instances started by the model are registered as well
+	c.Add(1 * time.Minute)
+	require.Eventually(t, func() bool {
+		return len(m.Instances()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	inst := m.Instances()[0]
+	found, present := m.FindInstance(inst.Id())
+	require.True(t, present)
+	assert.Equal(t, inst, found)
+}
+
+func TestInstanceRegistryNegativeArchiveCapacity(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	m := model.New(&testRegistry, model.WithContext(ctx), model.WithTracer(tracer),
+		model.WithArchiveCapacity(-1))
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+
+	inst, err := proc.Instantiate(instance.WithContext(ctx))
+	require.Nil(t, err)
+	require.Nil(t, inst.StartAll(ctx))
+	for {
+		if _, ok := tracing.Unwrap(<-traces).(catch.ActiveListeningTrace); ok {
+			break
+		}
+	}
+	c.Add(1 * time.Hour)
+
+	// Complete instance is forgotten right away
+	require.Eventually(t, func() bool {
+		return len(proc.Instances()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	_, found = m.FindInstance(inst.Id())
+	assert.False(t, found)
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package persistence
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+)
+
+const (
+	creationRecord   = "created"
+	snapshotRecord   = "snapshot"
+	completionRecord = "completed"
+)
+
+// record is a single entry of FileStore's log, stored as a line of JSON
+type record struct {
+	Kind       string          `json:"kind"`
+	InstanceId []byte          `json:"instanceId"`
+	Snapshot   json.RawMessage `json:"snapshot,omitempty"`
+}
+
+// DefaultCompactionThreshold is the number of records FileStore's log
+// has to reach before it is considered for compaction
+const DefaultCompactionThreshold = 1024
+
+// FileStore is a Store that keeps an append-only log in a local file
+//
+// Every recorded creation, snapshot and completion is appended to the
+// log. Once the log grows past compaction threshold and at least half
+// of its records are superseded, it is compacted: re-written to contain
+// only the latest states of unfinished instances.
+//
+// Writes are not synced to the disk individually, so the log survives
+// a crash of the process, but not necessarily that of the machine.
+// A partially written record at the end of the log is discarded
+// upon opening. Other records that can't be decoded are skipped
+// (see CorruptRecordError).
+type FileStore struct {
+	lock                sync.Mutex
+	path                string
+	file                *os.File
+	records             int
+	compactionThreshold int
+	tracer              tracing.Tracer
+	// latest snapshots of unfinished instances, keyed by
+	// instance ID bytes
+	instances map[string]json.RawMessage
+}
+
+type FileStoreOption func(*FileStore)
+
+// WithCompactionThreshold overrides DefaultCompactionThreshold
+func WithCompactionThreshold(records int) FileStoreOption {
+	return func(store *FileStore) {
+		store.compactionThreshold = records
+	}
+}
+
+// WithTracer makes FileStore trace errors it recovers from
+// (as tracing.ErrorTrace) to a given tracer
+func WithTracer(tracer tracing.Tracer) FileStoreOption {
+	return func(store *FileStore) {
+		store.tracer = tracer
+	}
+}
+
+// CorruptRecordError describes a record of FileStore's log that
+// couldn't be decoded and was skipped
+type CorruptRecordError struct {
+	Path   string
+	Offset int64
+	Err    error
+}
+
+func (e CorruptRecordError) Error() string {
+	return fmt.Sprintf("corrupt record at offset %d of %s: %v", e.Offset, e.Path, e.Err)
+}
+
+func (e CorruptRecordError) Unwrap() error {
+	return e.Err
+}
+
+// NewFileStore opens a log at a given path, creating it if
+// it doesn't exist
+func NewFileStore(path string, options ...FileStoreOption) (store *FileStore, err error) {
+	store = &FileStore{
+		path:                path,
+		compactionThreshold: DefaultCompactionThreshold,
+		instances:           make(map[string]json.RawMessage),
+	}
+	for _, option := range options {
+		option(store)
+	}
+	err = store.load()
+	if err != nil {
+		return
+	}
+	store.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	return
+}
+
+// load replays the log
+func (store *FileStore) load() (err error) {
+	file, err := os.Open(store.path)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	var size int64
+	store.records, size, err = ReadLog(file, store.path, func(line []byte) (err error) {
+		var rec record
+		if err = json.Unmarshal(line, &rec); err == nil {
+			store.apply(&rec)
+		}
+		return
+	}, store.tracer)
+	if err != nil {
+		return
+	}
+	// Discard partially written record, if any
+	err = os.Truncate(store.path, size)
+	return
+}
+
+// apply updates the latest states of unfinished instances
+// with a record
+func (store *FileStore) apply(rec *record) {
+	key := string(rec.InstanceId)
+	switch rec.Kind {
+	case creationRecord:
+		store.instances[key] = rec.Snapshot
+	case snapshotRecord:
+		if _, present := store.instances[key]; present {
+			store.instances[key] = rec.Snapshot
+		}
+	case completionRecord:
+		delete(store.instances, key)
+	}
+}
+
+// append writes a record to the log and applies it
+func (store *FileStore) append(rec *record) (err error) {
+	if store.file == nil {
+		err = errors.InvalidStateError{Expected: "open store", Actual: "closed store"}
+		return
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	_, err = store.file.Write(append(line, '\n'))
+	if err != nil {
+		return
+	}
+	store.apply(rec)
+	store.records++
+	if store.records >= store.compactionThreshold && store.records >= 2*len(store.instances) {
+		err = store.compact()
+	}
+	return
+}
+
+func (store *FileStore) RecordCreation(snapshot *instance.Snapshot) (err error) {
+	return store.recordSnapshot(creationRecord, snapshot)
+}
+
+// RecordSnapshot records instance's state
+//
+// Snapshots of instances that haven't been recorded as created
+// or have completed already are ignored.
+func (store *FileStore) RecordSnapshot(snapshot *instance.Snapshot) (err error) {
+	return store.recordSnapshot(snapshotRecord, snapshot)
+}
+
+func (store *FileStore) recordSnapshot(kind string, snapshot *instance.Snapshot) (err error) {
+	serialized, err := json.Marshal(snapshot)
+	if err != nil {
+		return
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if _, present := store.instances[string(snapshot.InstanceId)]; kind == snapshotRecord && !present {
+		return
+	}
+	err = store.append(&record{Kind: kind, InstanceId: snapshot.InstanceId, Snapshot: serialized})
+	return
+}
+
+func (store *FileStore) RecordCompletion(instanceId id.Id) (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	err = store.append(&record{Kind: completionRecord, InstanceId: instanceId.Bytes()})
+	return
+}
+
+// Unfinished returns the latest recorded states of unfinished
+// instances, ordered by their creation time
+func (store *FileStore) Unfinished() (snapshots []*instance.Snapshot, err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	snapshots = make([]*instance.Snapshot, 0, len(store.instances))
+	for _, serialized := range store.instances {
+		snapshot := &instance.Snapshot{}
+		err = json.Unmarshal(serialized, snapshot)
+		if err != nil {
+			return
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.Before(snapshots[j].CreatedAt)
+	})
+	return
+}
+
+// Compact re-writes the log to contain only the latest states
+// of unfinished instances
+func (store *FileStore) Compact() (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.file == nil {
+		err = errors.InvalidStateError{Expected: "open store", Actual: "closed store"}
+		return
+	}
+	err = store.compact()
+	return
+}
+
+func (store *FileStore) compact() (err error) {
+	compactedPath := store.path + ".compaction"
+	compacted, err := os.Create(compactedPath)
+	if err != nil {
+		return
+	}
+	keys := make([]string, 0, len(store.instances))
+	for key := range store.instances {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	writer := bufio.NewWriter(compacted)
+	for _, key := range keys {
+		var line []byte
+		line, err = json.Marshal(&record{
+			Kind:       creationRecord,
+			InstanceId: []byte(key),
+			Snapshot:   store.instances[key],
+		})
+		if err != nil {
+			_ = compacted.Close()
+			return
+		}
+		_, err = writer.Write(append(line, '\n'))
+		if err != nil {
+			_ = compacted.Close()
+			return
+		}
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = compacted.Sync()
+	}
+	if err != nil {
+		_ = compacted.Close()
+		return
+	}
+	err = compacted.Close()
+	if err != nil {
+		return
+	}
+	// The current log remains open (and in use) until the compacted
+	// one has replaced it
+	err = os.Rename(compactedPath, store.path)
+	if err != nil {
+		_ = os.Remove(compactedPath)
+		return
+	}
+	file, err := os.OpenFile(store.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		// The file that is still open is no longer the log,
+		// so the store can't continue to write into it
+		_ = store.file.Close()
+		store.file = nil
+		return
+	}
+	_ = store.file.Close()
+	store.file = file
+	store.records = len(keys)
+	return
+}
+
+func (store *FileStore) Close() (err error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if store.file == nil {
+		return
+	}
+	err = store.file.Sync()
+	if err != nil {
+		return
+	}
+	err = store.file.Close()
+	store.file = nil
+	return
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package persistence
+
+import (
+	"bufio"
+	"io"
+
+	"bpxe.org/pkg/tracing"
+)
+
+// ReadLog reads an append-only log of records stored as lines,
+// passing every complete record to a given decoding function
+//
+// Records the function fails to decode can only be corrupted outside
+// of the log's writer, so they are skipped and, if there is a tracer,
+// traced as CorruptRecordError (with a given path). A partially written
+// record at the end of the log is ignored.
+//
+// Returns the number of complete records (including skipped ones)
+// and their total size.
+func ReadLog(r io.Reader, path string, decode func(record []byte) error,
+	tracer tracing.Tracer) (records int, size int64, err error) {
+	reader := bufio.NewReader(r)
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		if decodeErr := decode(line); decodeErr != nil && tracer != nil {
+			tracer.Trace(tracing.ErrorTrace{
+				Error: CorruptRecordError{Path: path, Offset: size, Err: decodeErr},
+			})
+		}
+		records++
+		size += int64(len(line))
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package persistence
+
+import (
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/process/instance"
+)
+
+// Store is a persistence backend for process instances
+//
+// Store keeps the latest recorded state of every instance that has been
+// created but hasn't completed yet, so that such instances can be
+// restored (see process.Process.Restore) after a restart.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// RecordCreation records creation of an instance along
+	// with its initial state
+	RecordCreation(snapshot *instance.Snapshot) error
+	// RecordSnapshot records instance's state, superseding
+	// the previously recorded one
+	RecordSnapshot(snapshot *instance.Snapshot) error
+	// RecordCompletion records completion of an instance, after
+	// which it is no longer considered unfinished
+	RecordCompletion(instanceId id.Id) error
+	// Unfinished returns the latest recorded states of all
+	// instances that haven't completed
+	Unfinished() ([]*instance.Snapshot, error)
+	// Close releases store's resources
+	Close() error
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/persistence"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIds(t *testing.T, n int) (ids []id.Id) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	generator, err := id.DefaultIdGeneratorBuilder.NewIdGenerator(ctx, tracing.NewTracer(ctx))
+	require.Nil(t, err)
+	for i := 0; i < n; i++ {
+		ids = append(ids, generator.New())
+	}
+	return
+}
+
+func newSnapshot(instanceId id.Id, createdAt time.Time, order string) *instance.Snapshot {
+	return &instance.Snapshot{
+		ProcessId:  "proc",
+		InstanceId: instanceId.Bytes(),
+		CreatedAt:  createdAt,
+		Flows:      []instance.FlowSnapshot{},
+		Data:       map[string]interface{}{"order": order},
+	}
+}
+
+func TestFileStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.log")
+	ids := newIds(t, 3)
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := persistence.NewFileStore(path)
+	require.Nil(t, err)
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[0], createdAt, "o-1")))
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[1], createdAt.Add(time.Minute), "o-2")))
+	require.Nil(t, store.RecordSnapshot(newSnapshot(ids[0], createdAt, "o-1 (updated)")))
+	require.Nil(t, store.RecordCompletion(ids[1]))
+	// Snapshots of instances that weren't created are ignored
+	require.Nil(t, store.RecordSnapshot(newSnapshot(ids[2], createdAt, "o-3")))
+	require.Nil(t, store.Close())
+
+	store, err = persistence.NewFileStore(path)
+	require.Nil(t, err)
+	defer func() { _ = store.Close() }()
+	unfinished, err := store.Unfinished()
+	require.Nil(t, err)
+	require.Len(t, unfinished, 1)
+	assert.Equal(t, ids[0].Bytes(), unfinished[0].InstanceId)
+	assert.Equal(t, "o-1 (updated)", unfinished[0].Data["order"])
+	assert.True(t, createdAt.Equal(unfinished[0].CreatedAt))
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.log")
+	ids := newIds(t, 2)
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := persistence.NewFileStore(path, persistence.WithCompactionThreshold(8))
+	require.Nil(t, err)
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[0], createdAt, "o-1")))
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[1], createdAt.Add(time.Minute), "o-2")))
+	for i := 0; i < 10; i++ {
+		require.Nil(t, store.RecordSnapshot(newSnapshot(ids[0], createdAt, "o-1 (updated)")))
+	}
+	require.Nil(t, store.Close())
+
+	contents, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.Less(t, bytes.Count(contents, []byte("\n")), 8)
+
+	store, err = persistence.NewFileStore(path)
+	require.Nil(t, err)
+	defer func() { _ = store.Close() }()
+	unfinished, err := store.Unfinished()
+	require.Nil(t, err)
+	require.Len(t, unfinished, 2)
+	assert.Equal(t, "o-1 (updated)", unfinished[0].Data["order"])
+	assert.Equal(t, "o-2", unfinished[1].Data["order"])
+}
+
+func TestFileStorePartialRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.log")
+	ids := newIds(t, 2)
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := persistence.NewFileStore(path)
+	require.Nil(t, err)
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[0], createdAt, "o-1")))
+	require.Nil(t, store.Close())
+
+	// Simulate a crash in the middle of writing a record
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.Nil(t, err)
+	_, err = file.WriteString(`{"kind":"created","instan`)
+	require.Nil(t, err)
+	require.Nil(t, file.Close())
+
+	store, err = persistence.NewFileStore(path)
+	require.Nil(t, err)
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[1], createdAt.Add(time.Minute), "o-2")))
+	require.Nil(t, store.Close())
+
+	store, err = persistence.NewFileStore(path)
+	require.Nil(t, err)
+	defer func() { _ = store.Close() }()
+	unfinished, err := store.Unfinished()
+	require.Nil(t, err)
+	require.Len(t, unfinished, 2)
+	assert.Equal(t, "o-1", unfinished[0].Data["order"])
+	assert.Equal(t, "o-2", unfinished[1].Data["order"])
+}
+
+func TestFileStoreCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.log")
+	ids := newIds(t, 2)
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := persistence.NewFileStore(path)
+	require.Nil(t, err)
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[0], createdAt, "o-1")))
+	require.Nil(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.Nil(t, err)
+	_, err = file.WriteString("{\"kind\":\"created\",\"instan}\n")
+	require.Nil(t, err)
+	require.Nil(t, file.Close())
+
+	store, err = persistence.NewFileStore(path)
+	require.Nil(t, err)
+	require.Nil(t, store.RecordCreation(newSnapshot(ids[1], createdAt.Add(time.Minute), "o-2")))
+	require.Nil(t, store.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 8))
+	store, err = persistence.NewFileStore(path, persistence.WithTracer(tracer))
+	require.Nil(t, err)
+	defer func() { _ = store.Close() }()
+	unfinished, err := store.Unfinished()
+	require.Nil(t, err)
+	require.Len(t, unfinished, 2)
+	assert.Equal(t, "o-1", unfinished[0].Data["order"])
+	assert.Equal(t, "o-2", unfinished[1].Data["order"])
+
+	trace := (<-traces).(tracing.ErrorTrace)
+	corrupt, ok := trace.Error.(persistence.CorruptRecordError)
+	require.True(t, ok)
+	assert.Equal(t, path, corrupt.Path)
+	assert.Greater(t, corrupt.Offset, int64(0))
+}
@@ -12,29 +12,45 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/compensation"
+	"bpxe.org/pkg/conditional"
+	"bpxe.org/pkg/correlation"
 	"bpxe.org/pkg/data"
 	"bpxe.org/pkg/errors"
 	"bpxe.org/pkg/event"
 	"bpxe.org/pkg/flow"
 	"bpxe.org/pkg/flow_node"
 	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/activity/call_activity"
+	"bpxe.org/pkg/flow_node/activity/receive_task"
+	"bpxe.org/pkg/flow_node/activity/script_task"
+	"bpxe.org/pkg/flow_node/activity/send_task"
+	"bpxe.org/pkg/flow_node/activity/service_task"
+	"bpxe.org/pkg/flow_node/activity/sub_process"
 	"bpxe.org/pkg/flow_node/activity/task"
+	"bpxe.org/pkg/flow_node/activity/user_task"
 	"bpxe.org/pkg/flow_node/event/catch"
 	"bpxe.org/pkg/flow_node/event/end"
 	"bpxe.org/pkg/flow_node/event/start"
+	"bpxe.org/pkg/flow_node/event/throw"
+	"bpxe.org/pkg/flow_node/gateway/complex"
 	"bpxe.org/pkg/flow_node/gateway/event_based"
 	"bpxe.org/pkg/flow_node/gateway/exclusive"
 	"bpxe.org/pkg/flow_node/gateway/inclusive"
 	"bpxe.org/pkg/flow_node/gateway/parallel"
 	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/timer"
 	"bpxe.org/pkg/tracing"
 )
 
 type Instance struct {
 	id                             id.Id
 	process                        *bpmn.Process
+	definitions                    *bpmn.Definitions
 	Tracer                         tracing.Tracer
 	flowNodeMapping                *flow_node.FlowNodeMapping
 	flowWaitGroup                  sync.WaitGroup
@@ -52,13 +68,63 @@ type Instance struct {
 	eventDefinitionInstanceBuilder event.DefinitionInstanceBuilder
 	eventConsumersLock             sync.RWMutex
 	eventConsumers                 []event.Consumer
+	processInstantiator            call_activity.ProcessInstantiator
+	raiseErrorFunc                 func(*event.ErrorEvent)
+	serviceTaskRegistry            *service_task.Registry
+	inbox                          user_task.Inbox
+	clock                          clock.Clock
+	createdAt                      time.Time
+	// times timers of intermediate catch events were armed at,
+	// keyed by event IDs
+	timersArmedAt map[string]time.Time
+	flowTracker   flowTracker
+	snapshot      *Snapshot
+	observer      Observer
+	hold          *flow.Hold
+	lifecycle     lifecycle
+	compensation  *compensation.Scope
+	// true if cancellation compensates completed activities
+	compensateOnCancel bool
+	ctx                context.Context
+	cancel             context.CancelFunc
 }
 
 func (instance *Instance) Id() id.Id {
 	return instance.id
 }
 
+// ConsumeEvent forwards the event to instance's flow nodes.
+//
+// Message events that are subject to correlation (see correlation.Correlates)
+// are only forwarded if they correlate with the instance. Suspended instance
+// holds events back until it is resumed (see Suspend).
 func (instance *Instance) ConsumeEvent(ev event.Event) (result event.ConsumptionResult, err error) {
+	// Flow nodes of a cancelled instance are no longer
+	// processing events
+	if instance.ctx.Err() != nil {
+		return
+	}
+	if messageEvent, ok := ev.(*event.MessageEvent); ok {
+		var correlates, applicable bool
+		correlates, applicable, err = correlation.Correlates(instance.ctx, instance.definitions,
+			instance.process, messageEvent, instance)
+		if err != nil {
+			result = event.ConsumptionError
+			return
+		}
+		if applicable && !correlates {
+			return
+		}
+	}
+	if instance.holdEvent(ev) {
+		return
+	}
+	result, err = instance.forwardEvent(ev)
+	return
+}
+
+// forwardEvent forwards the event to instance's flow nodes
+func (instance *Instance) forwardEvent(ev event.Event) (result event.ConsumptionResult, err error) {
 	instance.eventConsumersLock.RLock()
 	// We're copying the list of consumers here to ensure that
 	// new consumers can subscribe during event forwarding
@@ -68,6 +134,24 @@ func (instance *Instance) ConsumeEvent(ev event.Event) (result event.Consumption
 	return
 }
 
+// CorrelatesWith returns true if the message event is subject to
+// correlation and correlates with the instance
+//
+// Instances that have completed or have been terminated
+// don't correlate with any messages.
+func (instance *Instance) CorrelatesWith(ev *event.MessageEvent) bool {
+	if instance.ensureActive() != nil {
+		return false
+	}
+	correlates, applicable, err := correlation.Correlates(instance.ctx, instance.definitions,
+		instance.process, ev, instance)
+	if err != nil {
+		instance.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		return false
+	}
+	return applicable && correlates
+}
+
 func (instance *Instance) RegisterEventConsumer(ev event.Consumer) (err error) {
 	instance.eventConsumersLock.Lock()
 	defer instance.eventConsumersLock.Unlock()
@@ -127,6 +211,21 @@ ready:
 	return
 }
 
+// FindItemAwareLocatorByScope returns the instance itself if the scope
+// matches process' name or id. Instance is the outermost scope.
+func (instance *Instance) FindItemAwareLocatorByScope(scope string) (itemAwareLocator data.ItemAwareLocator, found bool) {
+	if namePtr, present := instance.process.Name(); present && *namePtr == scope {
+		itemAwareLocator = instance
+		found = true
+		return
+	}
+	if idPtr, present := instance.process.Id(); present && *idPtr == scope {
+		itemAwareLocator = instance
+		found = true
+	}
+	return
+}
+
 // Option allows to modify configuration of
 // an instance in a flexible fashion (as its just a modification
 // function)
@@ -178,6 +277,69 @@ func WithEventDefinitionInstanceBuilder(builder event.DefinitionInstanceBuilder)
 	}
 }
 
+// WithProcessInstantiator sets an instantiator used by call activities
+// to instantiate called processes
+func WithProcessInstantiator(instantiator call_activity.ProcessInstantiator) Option {
+	return func(ctx context.Context, instance *Instance) context.Context {
+		instance.processInstantiator = instantiator
+		return ctx
+	}
+}
+
+// WithRaiseError sets a function errors not caught within the instance
+// are raised through (for example, to the call activity that has
+// called it) instead of being merely reported
+func WithRaiseError(raiseError func(*event.ErrorEvent)) Option {
+	return func(ctx context.Context, instance *Instance) context.Context {
+		instance.raiseErrorFunc = raiseError
+		return ctx
+	}
+}
+
+// WithServiceTaskRegistry sets a registry of handlers used by service tasks
+func WithServiceTaskRegistry(registry *service_task.Registry) Option {
+	return func(ctx context.Context, instance *Instance) context.Context {
+		instance.serviceTaskRegistry = registry
+		return ctx
+	}
+}
+
+// WithInbox sets an inbox user tasks put their work items into
+func WithInbox(inbox user_task.Inbox) Option {
+	return func(ctx context.Context, instance *Instance) context.Context {
+		instance.inbox = inbox
+		return ctx
+	}
+}
+
+// WithSnapshot restores instance's state from a snapshot
+// (see Instance.Snapshot), resuming its flows instead of
+// waiting for the instance to be started
+func WithSnapshot(snapshot *Snapshot) Option {
+	return func(ctx context.Context, instance *Instance) context.Context {
+		instance.snapshot = snapshot
+		return ctx
+	}
+}
+
+// WithObserver sets an observer notified of changes
+// of instance's state
+func WithObserver(observer Observer) Option {
+	return func(ctx context.Context, instance *Instance) context.Context {
+		instance.observer = observer
+		return ctx
+	}
+}
+
+// WithCompensationOnCancel makes cancellation of the instance
+// compensate its completed activities first (see Instance.Cancel)
+func WithCompensationOnCancel() Option {
+	return func(ctx context.Context, instance *Instance) context.Context {
+		instance.compensateOnCancel = true
+		return ctx
+	}
+}
+
 func (instance *Instance) FlowNodeMapping() *flow_node.FlowNodeMapping {
 	return instance.flowNodeMapping
 }
@@ -185,6 +347,7 @@ func (instance *Instance) FlowNodeMapping() *flow_node.FlowNodeMapping {
 func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options ...Option) (instance *Instance, err error) {
 	instance = &Instance{
 		process:                    element,
+		definitions:                definitions,
 		flowNodeMapping:            flow_node.NewLockedFlowNodeMapping(),
 		dataObjectsByName:          make(map[string]data.ItemAware),
 		dataObjectReferencesByName: make(map[string]data.ItemAware),
@@ -192,6 +355,8 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		dataObjects:                make(map[string]data.ItemAware),
 		dataObjectReferences:       make(map[string]data.ItemAware),
 		properties:                 make(map[string]data.ItemAware),
+		flowTracker:                flowTracker{positions: make(map[string]flowPosition)},
+		timersArmedAt:              make(map[string]time.Time),
 	}
 
 	ctx := context.Background()
@@ -201,6 +366,14 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		ctx = option(ctx, instance)
 	}
 
+	// Instance-scoped context, allows to cancel
+	// everything within this instance at once
+	ctx, instance.cancel = context.WithCancel(ctx)
+	// Allows to hold all instance's flows at once (see Suspend)
+	instance.hold = flow.NewHold()
+	ctx = flow.HoldToContext(ctx, instance.hold)
+	instance.ctx = ctx
+
 	if instance.Tracer == nil {
 		instance.Tracer = tracing.NewTracer(ctx)
 	}
@@ -209,21 +382,56 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		instance.idGeneratorBuilder = id.DefaultIdGeneratorBuilder
 	}
 
+	if instance.serviceTaskRegistry == nil {
+		instance.serviceTaskRegistry = service_task.NewRegistry()
+	}
+
+	if instance.inbox == nil {
+		instance.inbox = user_task.NewInbox()
+	}
+
 	var idGenerator id.Generator
-	idGenerator, err = instance.idGeneratorBuilder.NewIdGenerator(ctx, instance.Tracer)
+	if instance.snapshot != nil {
+		idGenerator, err = instance.idGeneratorBuilder.RestoreIdGenerator(ctx,
+			instance.snapshot.IdGenerator, instance.Tracer)
+	} else {
+		idGenerator, err = instance.idGeneratorBuilder.NewIdGenerator(ctx, instance.Tracer)
+	}
 	if err != nil {
 		return
 	}
 
 	instance.idGenerator = idGenerator
 
-	instance.id = idGenerator.New()
+	instance.clock, err = clock.FromContext(ctx)
+	if err != nil {
+		return
+	}
+
+	if instance.snapshot != nil {
+		instance.id, err = instance.idGeneratorBuilder.RestoreId(instance.snapshot.InstanceId)
+		if err != nil {
+			return
+		}
+		instance.createdAt = instance.snapshot.CreatedAt
+	} else {
+		instance.id = idGenerator.New()
+		instance.createdAt = instance.clock.Now()
+	}
 
 	err = instance.EventEgress.RegisterEventConsumer(instance)
 	if err != nil {
 		return
 	}
 
+	subTracer := tracing.NewTracer(ctx)
+	tracing.NewRelay(ctx, subTracer, instance.Tracer, func(trace tracing.Trace) []tracing.Trace {
+		return []tracing.Trace{Trace{
+			InstanceId: instance.id,
+			Trace:      trace,
+		}}
+	})
+
 	// Item aware elements
 
 	for i := range *instance.process.DataObjects() {
@@ -291,19 +499,43 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		}
 	}
 
+	// Restored data is traced as well
+	instance.watchData(ctx, subTracer)
+
+	if instance.snapshot != nil {
+		err = instance.restoreData(ctx, instance.snapshot)
+		if err != nil {
+			return
+		}
+	}
+
+	// Conditional events are evaluated against instance's data
+	// and only concern the instance itself
+	instance.eventDefinitionInstanceBuilder = event.DefinitionInstanceBuildingChain(
+		conditional.EventDefinitionInstanceBuilder(ctx, instance, instance.Tracer,
+			definitions, instance),
+		instance.eventDefinitionInstanceBuilder,
+	)
+
 	// Flow nodes
 
-	subTracer := tracing.NewTracer(ctx)
+	// Links within sub-processes are only instantiated when they start,
+	// so all links are validated upfront
+	err = throw.ValidateLinks(instance.process)
+	if err != nil {
+		return
+	}
 
-	tracing.NewRelay(ctx, subTracer, instance.Tracer, func(trace tracing.Trace) []tracing.Trace {
-		return []tracing.Trace{Trace{
-			InstanceId: instance.id,
-			Trace:      trace,
-		}}
-	})
+	instance.compensation = compensation.NewScope(subTracer)
+
+	// Timers are recorded before the instance can be captured
+	instance.recordTimers()
+
+	trackerSender := instance.Tracer.RegisterSender()
+	go instance.track(subTracer)(ctx, trackerSender)
 
-	wiringMaker := func(element *bpmn.FlowNode) (*flow_node.Wiring, error) {
-		return flow_node.NewWiring(
+	wiringMaker := func(element *bpmn.FlowNode) (wiring *flow_node.Wiring, err error) {
+		wiring, err = flow_node.NewWiring(
 			instance.id,
 			instance.process,
 			definitions,
@@ -322,29 +554,151 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 			subTracer,
 			instance.flowNodeMapping,
 			&instance.flowWaitGroup, instance.eventDefinitionInstanceBuilder)
+		if err != nil {
+			return
+		}
+		wiring.TerminateScope = instance.terminate
+		wiring.RaiseError = instance.raiseError
+		wiring.RaiseEscalation = instance.raiseEscalation
+		wiring.Compensation = instance.compensation
+		return
+	}
+
+	err = instance.instantiateFlowNodes(ctx, instance.process, wiringMaker, instance.flowNodeMapping, instance)
+	if err != nil {
+		return
+	}
+
+	instance.flowNodeMapping.Finalize()
+
+	// StartAll cease flow monitor
+	monitor := instance.ceaseFlowMonitor(subTracer)
+
+	if instance.snapshot != nil {
+		instance.Tracer.Trace(RestorationTrace{InstanceId: instance.id, Process: instance.process,
+			CreatedAt: instance.createdAt})
+		// Flows are to be resumed before the monitor starts waiting
+		// for them to finish
+		err = instance.restoreFlows(ctx, instance.snapshot, subTracer)
+		if err != nil {
+			return
+		}
+	}
+
+	sender := instance.Tracer.RegisterSender()
+	go monitor(ctx, sender)
+
+	if instance.snapshot == nil {
+		instance.Tracer.Trace(InstantiationTrace{InstanceId: instance.id, Process: instance.process,
+			CreatedAt: instance.createdAt})
+	}
+
+	return
+}
+
+// watchData traces changes of instance's data objects
+// and properties (see DataTrace)
+func (instance *Instance) watchData(ctx context.Context, tracer tracing.Tracer) {
+	for _, itemAwares := range []map[bpmn.Id]data.ItemAware{instance.dataObjects, instance.properties} {
+		for dataId, itemAware := range itemAwares {
+			updates := itemAware.Subscribe(ctx)
+			if updates == nil {
+				continue
+			}
+			sender := tracer.RegisterSender()
+			go func(dataId string) {
+				defer sender.Done()
+				for {
+					select {
+					case item, ok := <-updates:
+						if !ok {
+							return
+						}
+						tracer.Trace(DataTrace{Id: dataId, Item: item})
+					case <-ctx.Done():
+						return
+					}
+				}
+			}(dataId)
+		}
 	}
+}
 
+// recordTimers records the time timers of process' intermediate catch
+// events are armed at, which is the time of instance's creation (or,
+// upon restoration, the time they were originally armed at)
+func (instance *Instance) recordTimers() {
+	for i := range *instance.process.IntermediateCatchEvents() {
+		element := &(*instance.process.IntermediateCatchEvents())[i]
+		nodeId, present := element.Id()
+		if !present || len(*element.TimerEventDefinitions()) == 0 {
+			continue
+		}
+		armedAt := instance.createdAt
+		if instance.snapshot != nil {
+			for _, timerSnapshot := range instance.snapshot.Timers {
+				if timerSnapshot.Node == *nodeId {
+					armedAt = timerSnapshot.ArmedAt
+				}
+			}
+		}
+		instance.timersArmedAt[*nodeId] = armedAt
+	}
+}
+
+// armTimers makes restored timers of an intermediate catch event to be
+// re-created as they were armed originally, without firing again the
+// occurrences that had fired before the snapshot was taken
+//
+// Only the event's own wiring is affected, timers armed later on (such
+// as those within sub-processes or on activity boundaries) are armed anew.
+func (instance *Instance) armTimers(ctx context.Context, element *bpmn.IntermediateCatchEvent,
+	wiring *flow_node.Wiring) {
+	nodeId, present := element.Id()
+	if !present || instance.snapshot == nil {
+		return
+	}
+	armedAt, recorded := instance.timersArmedAt[*nodeId]
+	if !recorded {
+		return
+	}
+	wiring.EventDefinitionInstanceBuilder = event.DefinitionInstanceBuildingChain(
+		timer.ResumingEventDefinitionInstanceBuilder(ctx, instance.EventIngress,
+			instance.Tracer, armedAt, instance.snapshot.TakenAt),
+		wiring.EventDefinitionInstanceBuilder,
+	)
+}
+
+// instantiateFlowNodes instantiates all supported flow nodes within a given container
+// (process or sub-process) and registers them in a given flow node mapping
+func (instance *Instance) instantiateFlowNodes(
+	ctx context.Context,
+	container bpmn.FlowElementsContainerInterface,
+	wiringMaker func(*bpmn.FlowNode) (*flow_node.Wiring, error),
+	flowNodeMapping *flow_node.FlowNodeMapping,
+	itemAwareLocator data.ItemAwareLocator,
+) (err error) {
 	var wiring *flow_node.Wiring
 
-	for i := range *instance.process.StartEvents() {
-		element := &(*instance.process.StartEvents())[i]
+	for i := range *container.StartEvents() {
+		element := &(*container.StartEvents())[i]
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
 			return
 		}
 		var startEvent *start.Node
-		startEvent, err = start.New(ctx, wiring, element, idGenerator, instance)
+		startEvent, err = start.New(ctx, wiring, element, instance.idGenerator, itemAwareLocator)
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, startEvent)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, startEvent)
 		if err != nil {
 			return
 		}
 	}
 
-	for i := range *instance.process.EndEvents() {
-		element := &(*instance.process.EndEvents())[i]
+	for i := range *container.EndEvents() {
+		element := &(*container.EndEvents())[i]
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
 			return
@@ -354,50 +708,174 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, endEvent)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, endEvent)
 		if err != nil {
 			return
 		}
 	}
 
-	for i := range *instance.process.IntermediateCatchEvents() {
-		element := &(*instance.process.IntermediateCatchEvents())[i]
+	for i := range *container.IntermediateCatchEvents() {
+		element := &(*container.IntermediateCatchEvents())[i]
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
 			return
 		}
+		// Sub-processes instantiate their flow nodes here as well,
+		// but only process' own timers are recorded
+		if container == instance.process {
+			instance.armTimers(ctx, element, wiring)
+		}
 		var intermediateCatchEvent *catch.Node
 		intermediateCatchEvent, err = catch.New(ctx, wiring, &element.CatchEvent)
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, intermediateCatchEvent)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, intermediateCatchEvent)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range *container.IntermediateThrowEvents() {
+		element := &(*container.IntermediateThrowEvents())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var intermediateThrowEvent *throw.Node
+		intermediateThrowEvent, err = throw.New(ctx, wiring, element, container)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, intermediateThrowEvent)
 		if err != nil {
 			return
 		}
 	}
 
-	for i := range *instance.process.Tasks() {
-		element := &(*instance.process.Tasks())[i]
+	for i := range *container.Tasks() {
+		element := &(*container.Tasks())[i]
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
 			return
 		}
 		var aTask *activity.Harness
 		aTask, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
-			idGenerator, task.NewTask(ctx, element), instance,
+			instance.idGenerator, task.NewTask(ctx, element, itemAwareLocator), itemAwareLocator,
 		)
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, aTask)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, aTask)
 		if err != nil {
 			return
 		}
 	}
 
-	for i := range *instance.process.ExclusiveGateways() {
-		element := &(*instance.process.ExclusiveGateways())[i]
+	for i := range *container.ServiceTasks() {
+		element := &(*container.ServiceTasks())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var serviceTask *activity.Harness
+		serviceTask, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, service_task.NewServiceTask(ctx, element,
+				instance.serviceTaskRegistry, itemAwareLocator),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, serviceTask)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range *container.ScriptTasks() {
+		element := &(*container.ScriptTasks())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var scriptTask *activity.Harness
+		scriptTask, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, script_task.NewScriptTask(ctx, element, itemAwareLocator),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, scriptTask)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range *container.UserTasks() {
+		element := &(*container.UserTasks())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var userTask *activity.Harness
+		userTask, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, user_task.NewUserTask(ctx, element, instance.inbox,
+				instance.idGenerator, itemAwareLocator),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, userTask)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range *container.ReceiveTasks() {
+		element := &(*container.ReceiveTasks())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var receiveTask *activity.Harness
+		receiveTask, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, receive_task.NewReceiveTask(ctx, element, itemAwareLocator),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, receiveTask)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range *container.SendTasks() {
+		element := &(*container.SendTasks())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var sendTask *activity.Harness
+		sendTask, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, send_task.NewSendTask(ctx, element, itemAwareLocator),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, sendTask)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range *container.ExclusiveGateways() {
+		element := &(*container.ExclusiveGateways())[i]
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
 			return
@@ -407,14 +885,14 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, exclusiveGateway)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, exclusiveGateway)
 		if err != nil {
 			return
 		}
 	}
 
-	for i := range *instance.process.InclusiveGateways() {
-		element := &(*instance.process.InclusiveGateways())[i]
+	for i := range *container.InclusiveGateways() {
+		element := &(*container.InclusiveGateways())[i]
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
 			return
@@ -424,14 +902,31 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, inclusiveGateway)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, inclusiveGateway)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := range *container.ComplexGateways() {
+		element := &(*container.ComplexGateways())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var complexGateway *complex.Node
+		complexGateway, err = complex.New(ctx, wiring, element, itemAwareLocator)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, complexGateway)
 		if err != nil {
 			return
 		}
 	}
 
-	for i := range *instance.process.ParallelGateways() {
-		element := &(*instance.process.ParallelGateways())[i]
+	for i := range *container.ParallelGateways() {
+		element := &(*container.ParallelGateways())[i]
 		var parallelGateway *parallel.Node
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
@@ -441,14 +936,14 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, parallelGateway)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, parallelGateway)
 		if err != nil {
 			return
 		}
 	}
 
-	for i := range *instance.process.EventBasedGateways() {
-		element := &(*instance.process.EventBasedGateways())[i]
+	for i := range *container.EventBasedGateways() {
+		element := &(*container.EventBasedGateways())[i]
 		wiring, err = wiringMaker(&element.FlowNode)
 		if err != nil {
 			return
@@ -458,23 +953,105 @@ func NewInstance(element *bpmn.Process, definitions *bpmn.Definitions, options .
 		if err != nil {
 			return
 		}
-		err = instance.flowNodeMapping.RegisterElementToFlowNode(element, eventBasedGateway)
+		err = flowNodeMapping.RegisterElementToFlowNode(element, eventBasedGateway)
 		if err != nil {
 			return
 		}
 	}
 
-	instance.flowNodeMapping.Finalize()
+	for i := range *container.SubProcesses() {
+		element := &(*container.SubProcesses())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var subProcess *activity.Harness
+		subProcess, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, sub_process.NewSubProcess(ctx, element,
+				instance.idGenerator, itemAwareLocator, instance.instantiateFlowNodes),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, subProcess)
+		if err != nil {
+			return
+		}
+	}
 
-	// StartAll cease flow monitor
-	sender := instance.Tracer.RegisterSender()
-	go instance.ceaseFlowMonitor(subTracer)(ctx, sender)
+	for i := range *container.Transactions() {
+		element := &(*container.Transactions())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var transaction *activity.Harness
+		transaction, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, sub_process.NewTransaction(ctx, element,
+				instance.idGenerator, itemAwareLocator, instance.instantiateFlowNodes),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, transaction)
+		if err != nil {
+			return
+		}
+	}
 
-	instance.Tracer.Trace(InstantiationTrace{InstanceId: instance.id})
+	for i := range *container.CallActivities() {
+		element := &(*container.CallActivities())[i]
+		wiring, err = wiringMaker(&element.FlowNode)
+		if err != nil {
+			return
+		}
+		var callActivity *activity.Harness
+		callActivity, err = activity.NewHarness(ctx, wiring, &element.FlowNode,
+			instance.idGenerator, call_activity.NewCallActivity(ctx, element, instance.processInstantiator),
+			itemAwareLocator,
+		)
+		if err != nil {
+			return
+		}
+		err = flowNodeMapping.RegisterElementToFlowNode(element, callActivity)
+		if err != nil {
+			return
+		}
+	}
 
 	return
 }
 
+// terminate immediately terminates the instance, cancelling
+// all of its flows and flow nodes
+func (instance *Instance) terminate() {
+	instance.Tracer.Trace(TerminationTrace{InstanceId: instance.id, TerminatedAt: instance.clock.Now()})
+	instance.flowTracker.lock.Lock()
+	instance.flowTracker.terminated = true
+	instance.flowTracker.lock.Unlock()
+	instance.cancel()
+}
+
+// raiseError handles an error that wasn't caught by any of the
+// enclosing scopes. Unless the instance was given a function to
+// propagate it further (see WithRaiseError), it is merely reported.
+func (instance *Instance) raiseError(ev *event.ErrorEvent) {
+	if instance.raiseErrorFunc != nil {
+		instance.raiseErrorFunc(ev)
+		return
+	}
+	instance.Tracer.Trace(UncaughtErrorTrace{InstanceId: instance.id, Error: ev})
+}
+
+// raiseEscalation handles an escalation that wasn't caught by any
+// of the enclosing activities. Uncaught escalations are not errors,
+// so it is merely reported.
+func (instance *Instance) raiseEscalation(ev *event.EscalationEvent) {
+	instance.Tracer.Trace(UncaughtEscalationTrace{InstanceId: instance.id, Escalation: ev})
+}
+
 // StartWith explicitly starts the instance by triggering a given start event
 func (instance *Instance) StartWith(ctx context.Context, startEvent bpmn.StartEventInterface) (err error) {
 	flowNode, found := instance.flowNodeMapping.ResolveElementToFlowNode(startEvent)
@@ -513,6 +1090,47 @@ func (instance *Instance) StartAll(ctx context.Context) (err error) {
 	return
 }
 
+// StartWithReceiveTask explicitly starts the instance at a given
+// instantiating receive task (one with `instantiate` set to true
+// and no incoming sequence flows).
+//
+// The task will be waiting for the message, which is supposed to be
+// delivered to the instance separately.
+func (instance *Instance) StartWithReceiveTask(ctx context.Context, receiveTask *bpmn.ReceiveTask) (err error) {
+	elementId := "<unnamed>"
+	if idPtr, present := receiveTask.Id(); present {
+		elementId = *idPtr
+	}
+	processId := "<unnamed>"
+	if idPtr, present := instance.process.Id(); present {
+		processId = *idPtr
+	}
+	if !receiveTask.Instantiate() || len(*receiveTask.Incomings()) > 0 {
+		err = errors.InvalidArgumentError{
+			Expected: fmt.Sprintf("receive task %s in process %s to be instantiating", elementId, processId),
+			Actual:   receiveTask,
+		}
+		return
+	}
+	flowNode, found := instance.flowNodeMapping.ResolveElementToFlowNode(receiveTask)
+	if !found {
+		err = errors.NotFoundError{Expected: fmt.Sprintf("receive task %s in process %s", elementId, processId)}
+		return
+	}
+	harness, ok := flowNode.(*activity.Harness)
+	if !ok {
+		err = errors.RequirementExpectationError{
+			Expected: fmt.Sprintf("receive task %s flow node in process %s to be of type activity.Harness", elementId, processId),
+			Actual:   fmt.Sprintf("%T", flowNode),
+		}
+		return
+	}
+	newFlow := flow.New(harness.Definitions, harness, harness.Tracer,
+		harness.FlowNodeMapping, harness.FlowWaitGroup, instance.idGenerator, nil, instance)
+	newFlow.Start(ctx)
+	return
+}
+
 func (instance *Instance) ceaseFlowMonitor(tracer tracing.Tracer) func(ctx context.Context, sender tracing.SenderHandle) {
 	// Subscribing to traces early as otherwise events produced
 	// after the goroutine below is started are not going to be
@@ -542,28 +1160,56 @@ func (instance *Instance) ceaseFlowMonitor(tracer tracing.Tracer) func(ctx conte
 
 		// So, at first, we wait for (1.1) to occur
 		// [(1.2) will be addded when we actually support them]
+		//
+		// Processes without start events can be started at an
+		// instantiating receive task instead (see StartWithReceiveTask),
+		// in which case we wait for one of them to be visited as well.
+		receiveTaskVisited := len(*instance.process.StartEvents()) > 0 ||
+			len(instance.instantiatingReceiveTasks()) == 0
 
 		for {
-			if len(startEventsActivated) == len(*instance.process.StartEvents()) {
+			// (restored instance has been started already)
+			if instance.snapshot != nil ||
+				receiveTaskVisited && len(startEventsActivated) == len(*instance.process.StartEvents()) {
 				break
 			}
 
 			select {
 			case trace := <-traces:
+				// Traces of other instances (such as those of called
+				// processes) are relayed to this tracer as well, but
+				// their start events are of no concern here
+				if instance.isRelayedTrace(trace) {
+					continue
+				}
 				trace = tracing.Unwrap(trace)
 				switch t := trace.(type) {
 				case flow.FlowTerminationTrace:
 					switch flowNode := t.Source.(type) {
 					case *bpmn.StartEvent:
-						startEventsActivated = append(startEventsActivated, flowNode)
+						if instance.isOwnStartEvent(flowNode) {
+							startEventsActivated = append(startEventsActivated, flowNode)
+						}
 					default:
 					}
 				case flow.FlowTrace:
 					switch flowNode := t.Source.(type) {
 					case *bpmn.StartEvent:
-						startEventsActivated = append(startEventsActivated, flowNode)
+						if instance.isOwnStartEvent(flowNode) {
+							startEventsActivated = append(startEventsActivated, flowNode)
+						}
 					default:
 					}
+				case flow.VisitTrace:
+					// Activities are visited through their harnesses,
+					// so the node is matched by its id
+					if visitedId, present := t.Node.Id(); present {
+						for _, receiveTask := range instance.instantiatingReceiveTasks() {
+							if id, present := receiveTask.Id(); present && *id == *visitedId {
+								receiveTaskVisited = true
+							}
+						}
+					}
 				default:
 				}
 			case <-ctx.Done():
@@ -582,6 +1228,9 @@ func (instance *Instance) ceaseFlowMonitor(tracer tracing.Tracer) func(ctx conte
 		}()
 		select {
 		case <-waitIsOver:
+			instance.flowTracker.lock.Lock()
+			instance.flowTracker.completed = true
+			instance.flowTracker.lock.Unlock()
 			// Send out a cease flow trace
 			tracer.Trace(flow.CeaseFlowTrace{})
 		case <-ctx.Done():
@@ -590,6 +1239,43 @@ func (instance *Instance) ceaseFlowMonitor(tracer tracing.Tracer) func(ctx conte
 	}
 }
 
+// isRelayedTrace returns true if the trace was produced by another instance
+func (instance *Instance) isRelayedTrace(trace tracing.Trace) bool {
+	for {
+		if t, ok := trace.(Trace); ok && t.InstanceId != instance.id {
+			return true
+		}
+		wrapped, ok := trace.(tracing.WrappedTrace)
+		if !ok {
+			return false
+		}
+		trace = wrapped.Unwrap()
+	}
+}
+
+// isOwnStartEvent returns true if the start event is one of process' own
+// start events (as opposed to start events of sub-processes)
+func (instance *Instance) isOwnStartEvent(startEvent *bpmn.StartEvent) bool {
+	for i := range *instance.process.StartEvents() {
+		if &(*instance.process.StartEvents())[i] == startEvent {
+			return true
+		}
+	}
+	return false
+}
+
+// instantiatingReceiveTasks returns process' own receive tasks
+// that can start the instance
+func (instance *Instance) instantiatingReceiveTasks() (receiveTasks []*bpmn.ReceiveTask) {
+	receiveTasks = make([]*bpmn.ReceiveTask, 0)
+	for _, flowNode := range instance.process.InstantiatingFlowNodes() {
+		if receiveTask, ok := flowNode.(*bpmn.ReceiveTask); ok {
+			receiveTasks = append(receiveTasks, receiveTask)
+		}
+	}
+	return
+}
+
 // WaitUntilComplete waits until the instance is complete.
 // Returns true if the instance was complete, false if the context signalled `Done`
 func (instance *Instance) WaitUntilComplete(ctx context.Context) (complete bool) {
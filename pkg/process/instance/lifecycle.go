@@ -0,0 +1,171 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package instance
+
+import (
+	"fmt"
+	"sync"
+
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/process/instance/status"
+	"bpxe.org/pkg/tracing"
+)
+
+// Status of an instance
+type Status = status.Status
+
+const (
+	Running    = status.Running
+	Suspended  = status.Suspended
+	Completed  = status.Completed
+	Terminated = status.Terminated
+)
+
+// lifecycle keeps track of instance's suspension
+type lifecycle struct {
+	lock      sync.Mutex
+	suspended bool
+	// Events received while suspended, in order of their arrival
+	events []event.Event
+}
+
+// Suspend suspends the instance: its flows are held at the flow nodes they
+// are at and events (including those of timers) are held back until the
+// instance is resumed (see Resume)
+//
+// Flows that are already being acted upon by flow nodes are held
+// at the next flow nodes they arrive at. Instances of processes
+// called by call activities are to be suspended separately.
+func (instance *Instance) Suspend() (err error) {
+	if err = instance.ensureActive(); err != nil {
+		return
+	}
+	instance.lifecycle.lock.Lock()
+	defer instance.lifecycle.lock.Unlock()
+	if instance.lifecycle.suspended {
+		err = errors.InvalidStateError{Expected: "running instance", Actual: "suspended instance"}
+		return
+	}
+	instance.lifecycle.suspended = true
+	instance.hold.Hold()
+	instance.Tracer.Trace(SuspensionTrace{InstanceId: instance.id})
+	return
+}
+
+// Resume resumes a suspended instance, letting its flows continue
+// and delivering events it received while suspended
+func (instance *Instance) Resume() (err error) {
+	if err = instance.ensureActive(); err != nil {
+		return
+	}
+	instance.lifecycle.lock.Lock()
+	if !instance.lifecycle.suspended {
+		instance.lifecycle.lock.Unlock()
+		err = errors.InvalidStateError{Expected: "suspended instance", Actual: "running instance"}
+		return
+	}
+	instance.Tracer.Trace(ResumptionTrace{InstanceId: instance.id})
+	instance.hold.Release()
+	instance.lifecycle.lock.Unlock()
+
+	// Events that arrive during the delivery are still held back
+	// so that the order of events is preserved
+	for {
+		instance.lifecycle.lock.Lock()
+		events := instance.lifecycle.events
+		instance.lifecycle.events = nil
+		if len(events) == 0 {
+			instance.lifecycle.suspended = false
+			instance.lifecycle.lock.Unlock()
+			return
+		}
+		instance.lifecycle.lock.Unlock()
+		for _, ev := range events {
+			_, err = instance.forwardEvent(ev)
+			if err != nil {
+				instance.Tracer.Trace(tracing.ErrorTrace{Error: err})
+				err = nil
+			}
+		}
+	}
+}
+
+// Status returns current status of the instance
+//
+// Instance that was stopped by cancelling its context
+// is considered terminated.
+func (instance *Instance) Status() Status {
+	instance.flowTracker.lock.RLock()
+	completed, terminated := instance.flowTracker.completed, instance.flowTracker.terminated
+	instance.flowTracker.lock.RUnlock()
+	switch {
+	case completed:
+		return Completed
+	case terminated || instance.ctx.Err() != nil:
+		return Terminated
+	case instance.Suspended():
+		return Suspended
+	default:
+		return Running
+	}
+}
+
+// Suspended returns true if the instance is suspended
+func (instance *Instance) Suspended() bool {
+	instance.lifecycle.lock.Lock()
+	defer instance.lifecycle.lock.Unlock()
+	return instance.lifecycle.suspended
+}
+
+// Cancel terminates the instance (suspended or not),
+// cancelling all of its flows and flow nodes
+//
+// If configured (see WithCompensationOnCancel), instance's completed
+// activities that have compensation handlers are compensated before
+// that, in reverse order of their completion.
+func (instance *Instance) Cancel() (err error) {
+	if err = instance.ensureActive(); err != nil {
+		return
+	}
+	if instance.compensateOnCancel {
+		// Instance's flows are held so that no more activities complete
+		// in the meantime. Compensation handlers are run even if the
+		// instance is suspended, so they are not subject to its hold.
+		instance.hold.Hold()
+		instance.compensation.Compensate(flow.HoldToContext(instance.ctx, flow.NewHold()), nil)
+	}
+	instance.terminate()
+	return
+}
+
+// ensureActive returns an error if the instance has
+// completed or has been terminated
+func (instance *Instance) ensureActive() (err error) {
+	switch status := instance.Status(); status {
+	case Completed, Terminated:
+		err = errors.InvalidStateError{
+			Expected: "active instance",
+			Actual:   fmt.Sprintf("%s instance", status),
+		}
+	}
+	return
+}
+
+// holdEvent holds the event back if the instance is suspended,
+// returns true if it did
+func (instance *Instance) holdEvent(ev event.Event) bool {
+	instance.lifecycle.lock.Lock()
+	defer instance.lifecycle.lock.Unlock()
+	if instance.lifecycle.suspended {
+		instance.lifecycle.events = append(instance.lifecycle.events, ev)
+	}
+	return instance.lifecycle.suspended
+}
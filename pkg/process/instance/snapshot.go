@@ -0,0 +1,432 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package instance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/tracing"
+)
+
+// Snapshot is a serializable (using encoding/json) representation
+// of a running instance, sufficient to restore it later
+// (see process.Process.Restore)
+//
+// Data item values are stored as is, so they are expected to be
+// serializable as well. Note that JSON round-trip doesn't preserve
+// all Go types (for example, all numbers become float64).
+type Snapshot struct {
+	// ID of the process the instance belongs to
+	ProcessId string `json:"processId"`
+	// Instance ID, see id.Id.Bytes
+	InstanceId []byte `json:"instanceId"`
+	// Serialized state of instance's ID generator
+	IdGenerator []byte `json:"idGenerator"`
+	// Time of instance's creation
+	CreatedAt time.Time `json:"createdAt"`
+	// Time the snapshot was taken at, timers' occurrences due
+	// before it are considered to have fired already
+	TakenAt time.Time `json:"takenAt"`
+	// Timers of intermediate catch events
+	Timers []TimerSnapshot `json:"timers,omitempty"`
+	// Live flows
+	Flows []FlowSnapshot `json:"flows"`
+	// Values of process' data objects and properties, keyed by their IDs
+	Data map[string]interface{} `json:"data"`
+}
+
+// FlowSnapshot describes where a flow sits
+type FlowSnapshot struct {
+	// Flow ID, see id.Id.Bytes
+	FlowId []byte `json:"flowId"`
+	// ID of the flow node the flow is at
+	Node string `json:"node"`
+	// ID of the sequence flow the flow arrived through, if any
+	SequenceFlow string `json:"sequenceFlow,omitempty"`
+}
+
+// TimerSnapshot describes when timers of an intermediate catch event
+// were armed
+type TimerSnapshot struct {
+	// ID of the intermediate catch event
+	Node string `json:"node"`
+	// Time the timers were armed at
+	ArmedAt time.Time `json:"armedAt"`
+}
+
+// Observer is notified of changes of instance's state, which
+// allows to persist it (see WithObserver)
+//
+// Notifications of a given instance are delivered sequentially
+// and in order.
+type Observer interface {
+	// InstanceCreated is called once a new (not restored)
+	// instance has been created
+	InstanceCreated(snapshot *Snapshot)
+	// InstanceChanged is called every time instance's flows
+	// have moved
+	InstanceChanged(snapshot *Snapshot)
+	// InstanceCompleted is called once the instance has completed
+	// or has been terminated
+	InstanceCompleted(instanceId id.Id)
+}
+
+// flowPosition is a position of a live flow as observed by flowTracker
+type flowPosition struct {
+	flowId       id.Id
+	node         string
+	sequenceFlow string
+}
+
+// flowTracker keeps track of positions of instance's live flows
+type flowTracker struct {
+	lock      sync.RWMutex
+	positions map[string]flowPosition
+	// Set once all flows have ceased (before WaitUntilComplete returns)
+	completed  bool
+	terminated bool
+}
+
+// track follows flow traces to maintain flow positions
+// and notifies instance's observer, if any
+func (instance *Instance) track(tracer tracing.Tracer) func(ctx context.Context, sender tracing.SenderHandle) {
+	// Subscribing to traces early as otherwise events produced
+	// after the goroutine below is started are not going to be
+	// sent to it.
+	traces := tracer.Subscribe()
+	return func(ctx context.Context, sender tracing.SenderHandle) {
+		defer sender.Done()
+		defer tracer.Unsubscribe(traces)
+		tracker := &instance.flowTracker
+		if instance.observer != nil && instance.snapshot == nil {
+			instance.notifyObserver(instance.observer.InstanceCreated)
+		}
+		for {
+			select {
+			case trace := <-traces:
+				if instance.isRelayedTrace(trace) {
+					continue
+				}
+				changed := true
+				tracker.lock.Lock()
+				switch t := tracing.Unwrap(trace).(type) {
+				case flow.NewFlowTrace:
+					if _, present := tracker.positions[t.FlowId.String()]; !present {
+						if nodeId, present := t.Node.Id(); present {
+							tracker.positions[t.FlowId.String()] = flowPosition{flowId: t.FlowId, node: *nodeId}
+						}
+					}
+				case flow.FlowTrace:
+					for i := range t.Flows {
+						sequenceFlow := t.Flows[i].SequenceFlow()
+						sequenceFlowId, present := sequenceFlow.Id()
+						if !present {
+							continue
+						}
+						target := *sequenceFlow.TargetRef()
+						tracker.positions[t.Flows[i].Id().String()] = flowPosition{
+							flowId:       t.Flows[i].Id(),
+							node:         target,
+							sequenceFlow: *sequenceFlowId,
+						}
+					}
+				case flow.LinkTrace:
+					if nodeId, present := t.Target.Id(); present {
+						tracker.positions[t.FlowId.String()] = flowPosition{flowId: t.FlowId, node: *nodeId}
+					}
+				case flow.FlowTerminationTrace:
+					delete(tracker.positions, t.FlowId.String())
+				case flow.CancellationTrace:
+					delete(tracker.positions, t.FlowId.String())
+				case flow.CeaseFlowTrace:
+					tracker.lock.Unlock()
+					instance.traceCompletion()
+					if instance.observer != nil {
+						instance.observer.InstanceCompleted(instance.id)
+					}
+					return
+				default:
+					changed = false
+				}
+				tracker.lock.Unlock()
+				if changed && instance.observer != nil {
+					instance.notifyObserver(instance.observer.InstanceChanged)
+				}
+			case <-ctx.Done():
+				tracker.lock.RLock()
+				terminated := tracker.terminated
+				tracker.lock.RUnlock()
+				if terminated && instance.observer != nil {
+					instance.observer.InstanceCompleted(instance.id)
+				}
+				return
+			}
+		}
+	}
+}
+
+// traceCompletion traces instance's completion along
+// with its final data
+//
+// Items that can't be captured are left out of the data
+// and their errors are traced.
+func (instance *Instance) traceCompletion() {
+	trace := CompletionTrace{
+		InstanceId:  instance.id,
+		Data:        make(map[string]interface{}),
+		CompletedAt: instance.clock.Now(),
+	}
+	for _, itemAwares := range []map[string]data.ItemAware{
+		instance.dataObjectsByName, instance.propertiesByName,
+	} {
+		for name, itemAware := range itemAwares {
+			item, err := instance.snapshotItem(itemAware)
+			if err != nil {
+				instance.Tracer.Trace(tracing.ErrorTrace{Error: err})
+				continue
+			}
+			trace.Data[name] = item
+		}
+	}
+	instance.Tracer.Trace(trace)
+}
+
+// notifyObserver passes instance's snapshot to a given
+// notification method of instance's observer
+func (instance *Instance) notifyObserver(notify func(*Snapshot)) {
+	snapshot, err := instance.Snapshot()
+	if err != nil {
+		// Snapshot can't be taken once the instance
+		// has been stopped
+		if instance.ctx.Err() == nil {
+			instance.Tracer.Trace(tracing.ErrorTrace{Error: err})
+		}
+		return
+	}
+	notify(snapshot)
+}
+
+// Snapshot captures instance's state
+//
+// Flow positions are derived from instance's traces, so flows that
+// have just moved might be captured at the flow node they were at
+// before. Upon restoration, such a flow node will be executed again.
+//
+// Flows within sub-processes (including transactions) are not captured,
+// the sub-process will be entered anew upon restoration. Flows waiting
+// on events after an event-based gateway are captured as a single flow
+// at the gateway.
+func (instance *Instance) Snapshot() (snapshot *Snapshot, err error) {
+	processId, present := instance.process.Id()
+	if !present {
+		err = errors.RequirementExpectationError{
+			Expected: "process to have an ID",
+			Actual:   instance.process,
+		}
+		return
+	}
+	snapshot = &Snapshot{
+		ProcessId:  *processId,
+		InstanceId: instance.id.Bytes(),
+		CreatedAt:  instance.createdAt,
+		Flows:      make([]FlowSnapshot, 0),
+		Data:       make(map[string]interface{}),
+	}
+	snapshot.IdGenerator, err = instance.idGenerator.Snapshot()
+	if err != nil {
+		return
+	}
+	snapshot.TakenAt = instance.clock.Now()
+	for nodeId, armedAt := range instance.timersArmedAt {
+		snapshot.Timers = append(snapshot.Timers, TimerSnapshot{Node: nodeId, ArmedAt: armedAt})
+	}
+
+	flows := make([]FlowSnapshot, 0)
+	instance.flowTracker.lock.RLock()
+	for _, position := range instance.flowTracker.positions {
+		flows = append(flows, FlowSnapshot{
+			FlowId:       position.flowId.Bytes(),
+			Node:         position.node,
+			SequenceFlow: position.sequenceFlow,
+		})
+	}
+	instance.flowTracker.lock.RUnlock()
+	snapshot.Flows = SnapshotFlows(instance.process, flows)
+
+	for dataId, itemAware := range instance.dataObjects {
+		snapshot.Data[dataId], err = instance.snapshotItem(itemAware)
+		if err != nil {
+			return
+		}
+	}
+	for dataId, itemAware := range instance.properties {
+		snapshot.Data[dataId], err = instance.snapshotItem(itemAware)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// snapshotItem retrieves item's value, unless the instance is done
+func (instance *Instance) snapshotItem(itemAware data.ItemAware) (item data.Item, err error) {
+	select {
+	case item = <-itemAware.Get(instance.ctx):
+	case <-instance.ctx.Done():
+		err = instance.ctx.Err()
+	}
+	return
+}
+
+// SnapshotFlows converts positions of process instance's live flows
+// into flow snapshots the same way Instance.Snapshot does, which allows
+// to restore the instance from positions tracked elsewhere (for example,
+// by a journal)
+func SnapshotFlows(process *bpmn.Process, flows []FlowSnapshot) (snapshots []FlowSnapshot) {
+	snapshots = make([]FlowSnapshot, 0, len(flows))
+	gateways := make(map[string]bool)
+	for _, flow := range flows {
+		flowSnapshot, ok := snapshotFlow(process, flow)
+		if !ok {
+			continue
+		}
+		if flowSnapshot.SequenceFlow == "" {
+			if gateways[flowSnapshot.Node] {
+				continue
+			}
+			if _, found := process.FindBy(bpmn.ExactId(flowSnapshot.Node).
+				And(bpmn.ElementType((*bpmn.EventBasedGateway)(nil)))); found {
+				gateways[flowSnapshot.Node] = true
+			}
+		}
+		snapshots = append(snapshots, flowSnapshot)
+	}
+	return
+}
+
+// snapshotFlow converts flow's position to its snapshot, returns false
+// if the flow shouldn't be captured
+func snapshotFlow(process *bpmn.Process, flow FlowSnapshot) (snapshot FlowSnapshot, ok bool) {
+	snapshot = flow
+	element, found := process.FindBy(bpmn.ExactId(snapshot.Node))
+	if !found {
+		return
+	}
+	// Boundary event flows are re-created along with the activities
+	if _, isBoundaryEvent := element.(*bpmn.BoundaryEvent); isBoundaryEvent {
+		return
+	}
+	if withinSubProcess(process, snapshot.Node) {
+		return
+	}
+	if snapshot.SequenceFlow != "" {
+		if sequenceFlow, found := process.FindBy(bpmn.ExactId(snapshot.SequenceFlow).
+			And(bpmn.ElementType((*bpmn.SequenceFlow)(nil)))); found {
+			sourceRef := *sequenceFlow.(*bpmn.SequenceFlow).SourceRef()
+			if _, found := process.FindBy(bpmn.ExactId(sourceRef).
+				And(bpmn.ElementType((*bpmn.EventBasedGateway)(nil)))); found {
+				// The flow will wait at the gateway again
+				snapshot.Node = sourceRef
+				snapshot.SequenceFlow = ""
+			}
+		}
+	}
+	ok = true
+	return
+}
+
+// withinSubProcess returns true if a given flow node is contained
+// in one of process's sub-processes (including transactions)
+func withinSubProcess(process *bpmn.Process, nodeId string) bool {
+	subProcesses := make([]*bpmn.SubProcess, 0)
+	for i := range *process.SubProcesses() {
+		subProcesses = append(subProcesses, &(*process.SubProcesses())[i])
+	}
+	for i := range *process.Transactions() {
+		subProcesses = append(subProcesses, &(*process.Transactions())[i].SubProcess)
+	}
+	for _, subProcess := range subProcesses {
+		// Flows at the sub-process itself are not within it
+		if id, present := subProcess.Id(); present && *id == nodeId {
+			continue
+		}
+		if _, found := subProcess.FindBy(bpmn.ExactId(nodeId)); found {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreData puts snapshot's data into instance's data objects
+// and properties
+func (instance *Instance) restoreData(ctx context.Context, snapshot *Snapshot) (err error) {
+	for dataId, value := range snapshot.Data {
+		itemAware, found := instance.dataObjects[dataId]
+		if !found {
+			itemAware, found = instance.properties[dataId]
+		}
+		if !found {
+			err = errors.NotFoundError{Expected: fmt.Sprintf("data object or property %s", dataId)}
+			return
+		}
+		select {
+		case <-itemAware.Put(ctx, value):
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+	return
+}
+
+// restoreFlows resumes snapshot's flows
+func (instance *Instance) restoreFlows(ctx context.Context, snapshot *Snapshot, tracer tracing.Tracer) (err error) {
+	for _, flowSnapshot := range snapshot.Flows {
+		var flowId id.Id
+		flowId, err = instance.idGeneratorBuilder.RestoreId(flowSnapshot.FlowId)
+		if err != nil {
+			return
+		}
+		element, found := instance.process.FindBy(bpmn.ExactId(flowSnapshot.Node))
+		if !found {
+			err = errors.NotFoundError{Expected: fmt.Sprintf("flow node %s", flowSnapshot.Node)}
+			return
+		}
+		flowNodeElement, ok := element.(bpmn.FlowNodeInterface)
+		if !ok {
+			err = errors.RequirementExpectationError{
+				Expected: fmt.Sprintf("%s to be a flow node", flowSnapshot.Node),
+				Actual:   element,
+			}
+			return
+		}
+		flowNode, found := instance.flowNodeMapping.ResolveElementToFlowNode(flowNodeElement)
+		if !found {
+			err = errors.NotFoundError{Expected: fmt.Sprintf("flow node for element %s", flowSnapshot.Node)}
+			return
+		}
+		var sequenceFlowId *string
+		if flowSnapshot.SequenceFlow != "" {
+			sequenceFlow := flowSnapshot.SequenceFlow
+			sequenceFlowId = &sequenceFlow
+		}
+		flow.Restore(flowId, sequenceFlowId, instance.definitions, flowNode, tracer,
+			instance.flowNodeMapping, &instance.flowWaitGroup, instance.idGenerator, nil,
+			instance).Start(ctx)
+	}
+	return
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package status
+
+import "fmt"
+
+// Status of a process instance
+type Status int
+
+const (
+	Running Status = iota
+	Suspended
+	Completed
+	Terminated
+)
+
+func (status Status) String() string {
+	switch status {
+	case Running:
+		return "running"
+	case Suspended:
+		return "suspended"
+	case Completed:
+		return "completed"
+	case Terminated:
+		return "terminated"
+	default:
+		return fmt.Sprintf("Status(%d)", int(status))
+	}
+}
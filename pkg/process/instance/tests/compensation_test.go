@@ -0,0 +1,196 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/compensation"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node"
+	"bpxe.org/pkg/flow_node/activity"
+	"bpxe.org/pkg/flow_node/activity/task"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCompensation bpmn.Definitions
+var testCompensationHold bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/compensation.bpmn", testdata, &testCompensation)
+	internal.LoadTestFile("testdata/compensation_hold.bpmn", testdata, &testCompensationHold)
+}
+
+func TestCancelCompensation(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	fanOut := event.NewFanOut()
+	proc := process.New(&(*testCompensation.Processes())[0], &testCompensation,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithTracer(tracer), process.WithCompensationOnCancel(),
+	)
+
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	require.Nil(t, inst.StartAll(ctx))
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		_, ok := trace.(catch.ActiveListeningTrace)
+		return ok
+	})
+
+	// Completed activities are compensated even if
+	// the instance is suspended
+	require.Nil(t, inst.Suspend())
+	require.Nil(t, inst.Cancel())
+	compensated, handled := false, false
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		switch trace := trace.(type) {
+		case compensation.CompensationTrace:
+			id, _ := trace.Activity.Id()
+			assert.Equal(t, "bookHotel", *id)
+			compensated = true
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "cancelHotel" {
+				handled = true
+			}
+		case instance.TerminationTrace:
+			return true
+		}
+		return false
+	})
+	assert.True(t, compensated)
+	assert.True(t, handled)
+
+	completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer completionCancel()
+	assert.True(t, inst.WaitUntilComplete(completionCtx))
+}
+
+func TestCancelWithoutCompensation(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	fanOut := event.NewFanOut()
+	proc := process.New(&(*testCompensation.Processes())[0], &testCompensation,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithTracer(tracer),
+	)
+
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	require.Nil(t, inst.StartAll(ctx))
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		_, ok := trace.(catch.ActiveListeningTrace)
+		return ok
+	})
+
+	// Completed activities are only compensated if configured
+	require.Nil(t, inst.Cancel())
+	compensated := false
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		switch trace.(type) {
+		case compensation.CompensationTrace:
+			compensated = true
+		case instance.TerminationTrace:
+			return true
+		}
+		return false
+	})
+	assert.False(t, compensated)
+}
+
+// setBody sets the body of a given task of the instance
+func setBody(t *testing.T, inst *instance.Instance, taskId string,
+	body func(*task.Task, context.Context) flow_node.Action) {
+	element, found := testCompensationHold.FindBy(bpmn.ExactId(taskId))
+	require.True(t, found)
+	node, found := inst.FlowNodeMapping().ResolveElementToFlowNode(element.(bpmn.FlowNodeInterface))
+	require.True(t, found)
+	node.(*activity.Harness).Activity().(*task.Task).SetBody(body)
+}
+
+func TestCancelHoldsFlows(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	proc := process.New(&(*testCompensationHold.Processes())[0], &testCompensationHold,
+		process.WithTracer(tracer), process.WithCompensationOnCancel())
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+
+	// gate lets its flow through to bookFlight only once
+	// cancelHotel has started compensating bookHotel
+	gate, compensating, compensated := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	setBody(t, inst, "gate", func(aTask *task.Task, ctx context.Context) flow_node.Action {
+		<-gate
+		return flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&aTask.Outgoing)}
+	})
+	setBody(t, inst, "cancelHotel", func(aTask *task.Task, ctx context.Context) flow_node.Action {
+		close(compensating)
+		<-compensated
+		return flow_node.FlowAction{SequenceFlows: flow_node.AllSequenceFlows(&aTask.Outgoing)}
+	})
+	require.Nil(t, inst.StartAll(ctx))
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		if trace, ok := trace.(flow.VisitTrace); ok {
+			id, present := trace.Node.Id()
+			return present && *id == "join"
+		}
+		return false
+	})
+
+	activities := make([]string, 0)
+	compensationOr := func(f func(tracing.Trace) bool) func(tracing.Trace) bool {
+		return func(trace tracing.Trace) bool {
+			if trace, ok := trace.(compensation.CompensationTrace); ok {
+				id, _ := trace.Activity.Id()
+				activities = append(activities, *id)
+			}
+			return f(trace)
+		}
+	}
+
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- inst.Cancel()
+	}()
+	<-compensating
+	close(gate)
+	// The flow is held at bookFlight rather than completing
+	// it while bookHotel is being compensated
+	awaitTrace(t, traces, compensationOr(func(trace tracing.Trace) bool {
+		if trace, ok := trace.(flow.HoldTrace); ok {
+			id, present := trace.Node.Id()
+			return present && *id == "bookFlight"
+		}
+		return false
+	}))
+	close(compensated)
+	require.Nil(t, <-cancelled)
+	awaitTrace(t, traces, compensationOr(func(trace tracing.Trace) bool {
+		_, ok := trace.(instance.TerminationTrace)
+		return ok
+	}))
+	assert.Equal(t, []string{"bookHotel"}, activities)
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/errors"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/model"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/timer"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLifecycle bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/lifecycle.bpmn", testdata, &testLifecycle)
+}
+
+func newLifecycleProcess(ctx context.Context, tracer tracing.Tracer) *process.Process {
+	fanOut := event.NewFanOut()
+	return process.New(&(*testLifecycle.Processes())[0], &testLifecycle,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithEventDefinitionInstanceBuilder(event.DefinitionInstanceBuildingChain(
+			timer.EventDefinitionInstanceBuilder(ctx, fanOut, tracer),
+		)),
+		process.WithTracer(tracer),
+	)
+}
+
+// awaitTrace waits for a trace matching a given function
+func awaitTrace(t *testing.T, traces chan tracing.Trace, f func(tracing.Trace) bool) {
+	for {
+		trace := tracing.Unwrap(<-traces)
+		if errorTrace, ok := trace.(tracing.ErrorTrace); ok {
+			t.Fatalf("%#v", errorTrace)
+		}
+		if f(trace) {
+			return
+		}
+	}
+}
+
+// positions returns IDs of flow nodes instance's flows are at
+func positions(t *testing.T, inst *instance.Instance) []string {
+	snapshot, err := inst.Snapshot()
+	require.Nil(t, err)
+	nodes := make([]string, 0)
+	for _, flowSnapshot := range snapshot.Flows {
+		nodes = append(nodes, flowSnapshot.Node)
+	}
+	return nodes
+}
+
+func TestSuspendAndResume(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	proc := newLifecycleProcess(ctx, tracer)
+
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	require.Nil(t, inst.Suspend())
+	assert.True(t, inst.Suspended())
+	assert.IsType(t, errors.InvalidStateError{}, inst.Suspend())
+
+	// Flows of a suspended instance are held where they are
+	require.Nil(t, inst.StartAll(ctx))
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		if hold, ok := trace.(flow.HoldTrace); ok {
+			_, ok = hold.Node.(*bpmn.StartEvent)
+			return ok
+		}
+		return false
+	})
+	require.Nil(t, inst.Resume())
+	assert.False(t, inst.Suspended())
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		_, ok := trace.(catch.ActiveListeningTrace)
+		return ok
+	})
+
+	// Timer of a suspended instance is not lost, but its
+	// firing doesn't move the flow until resumption
+	require.Nil(t, inst.Suspend())
+	c.Add(1 * time.Hour)
+	assert.Never(t, func() bool {
+		return !assert.ObjectsAreEqual([]string{"wait"}, positions(t, inst))
+	}, 100*time.Millisecond, 10*time.Millisecond)
+
+	require.Nil(t, inst.Resume())
+	completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer completionCancel()
+	assert.True(t, inst.WaitUntilComplete(completionCtx))
+	assert.IsType(t, errors.InvalidStateError{}, inst.Suspend())
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	proc := newLifecycleProcess(ctx, tracer)
+
+	inst, err := proc.Instantiate()
+	require.Nil(t, err)
+	require.Nil(t, inst.StartAll(ctx))
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		_, ok := trace.(catch.ActiveListeningTrace)
+		return ok
+	})
+
+	// Suspended instance can be cancelled
+	require.Nil(t, inst.Suspend())
+	require.Nil(t, inst.Cancel())
+	awaitTrace(t, traces, func(trace tracing.Trace) bool {
+		termination, ok := trace.(instance.TerminationTrace)
+		return ok && termination.InstanceId.String() == inst.Id().String()
+	})
+	completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer completionCancel()
+	assert.True(t, inst.WaitUntilComplete(completionCtx))
+
+	assert.IsType(t, errors.InvalidStateError{}, inst.Resume())
+	assert.IsType(t, errors.InvalidStateError{}, inst.Cancel())
+}
+
+func TestFindInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), clock.NewMock()))
+	defer cancel()
+	m := model.New(&testLifecycle, model.WithContext(ctx))
+	proc, found := m.FindProcessBy(func(p *process.Process) bool {
+		id, present := p.Element.Id()
+		return present && *id == "proc"
+	})
+	require.True(t, found)
+	inst, err := proc.Instantiate(instance.WithContext(ctx))
+	require.Nil(t, err)
+
+	found_, found := proc.FindInstance(inst.Id())
+	require.True(t, found)
+	assert.Equal(t, inst, found_)
+	found_, found = m.FindInstance(inst.Id())
+	require.True(t, found)
+	assert.Equal(t, inst, found_)
+
+	other := newLifecycleProcess(ctx, tracing.NewTracer(ctx))
+	_, found = other.FindInstance(inst.Id())
+	assert.False(t, found)
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"bpxe.org/internal"
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/clock"
+	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow"
+	"bpxe.org/pkg/flow_node/event/catch"
+	"bpxe.org/pkg/process"
+	"bpxe.org/pkg/process/instance"
+	"bpxe.org/pkg/timer"
+	"bpxe.org/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/snapshot.bpmn", testdata, &testDoc)
+}
+
+var subProcessDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/snapshot_sub_process.bpmn", testdata, &subProcessDoc)
+}
+
+var transactionDoc bpmn.Definitions
+
+func init() {
+	internal.LoadTestFile("testdata/snapshot_transaction.bpmn", testdata, &transactionDoc)
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	fanOut := event.NewFanOut()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	proc := process.New(&(*testDoc.Processes())[0], &testDoc,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithEventDefinitionInstanceBuilder(event.DefinitionInstanceBuildingChain(
+			timer.EventDefinitionInstanceBuilder(ctx, fanOut, tracer),
+		)),
+		process.WithTracer(tracer),
+	)
+
+	originalCtx, originalCancel := context.WithCancel(ctx)
+	original, err := proc.Instantiate(instance.WithContext(originalCtx))
+	require.Nil(t, err)
+	itemAware, found := original.FindItemAwareByName("order")
+	require.True(t, found)
+	<-itemAware.Put(ctx, "o-1")
+	err = original.StartAll(ctx)
+	require.Nil(t, err)
+
+	// Wait until one flow waits for the timer and another
+	// one waits for it at the joining gateway
+	var snapshot *instance.Snapshot
+	positions := make(map[string]string)
+	require.Eventually(t, func() bool {
+		snapshot, err = original.Snapshot()
+		require.Nil(t, err)
+		positions = make(map[string]string)
+		for _, flowSnapshot := range snapshot.Flows {
+			positions[flowSnapshot.Node] = flowSnapshot.SequenceFlow
+		}
+		_, joined := positions["join"]
+		return len(snapshot.Flows) == 2 && joined
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, map[string]string{"wait": "Flow_fork_wait", "join": "Flow_prepare_join"}, positions)
+	assert.Equal(t, "o-1", snapshot.Data["order"])
+
+	// Half of the timer's duration passes before the instance is stopped
+	c.Add(30 * time.Minute)
+	originalCancel()
+
+	serialized, err := json.Marshal(snapshot)
+	require.Nil(t, err)
+	var deserialized instance.Snapshot
+	err = json.Unmarshal(serialized, &deserialized)
+	require.Nil(t, err)
+
+	restored, err := proc.Restore(&deserialized, instance.WithContext(ctx))
+	require.Nil(t, err)
+	assert.Equal(t, original.Id().String(), restored.Id().String())
+
+	itemAware, found = restored.FindItemAwareByName("order")
+	require.True(t, found)
+	assert.Equal(t, "o-1", <-itemAware.Get(ctx))
+
+	// Restored instance retains the original id, so its traces
+	// can only be told apart by coming after the restoration
+	restoredTraces := false
+	for {
+		trace := tracing.Unwrap(<-traces)
+		switch trace := trace.(type) {
+		case instance.RestorationTrace:
+			restoredTraces = true
+		case catch.ActiveListeningTrace:
+			// The rest of the timer's duration passes once
+			// the restored instance listens to it
+			if restoredTraces {
+				c.Add(30 * time.Minute)
+			}
+		case flow.CompletionTrace:
+			if id, present := trace.Node.Id(); present && *id == "end" {
+				completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+				defer completionCancel()
+				assert.True(t, restored.WaitUntilComplete(completionCtx))
+				return
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
+
+func TestRestoredSubProcessTimer(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	fanOut := event.NewFanOut()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	proc := process.New(&(*subProcessDoc.Processes())[0], &subProcessDoc,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithEventDefinitionInstanceBuilder(event.DefinitionInstanceBuildingChain(
+			timer.EventDefinitionInstanceBuilder(ctx, fanOut, tracer),
+		)),
+		process.WithTracer(tracer),
+	)
+
+	originalCtx, originalCancel := context.WithCancel(ctx)
+	original, err := proc.Instantiate(instance.WithContext(originalCtx))
+	require.Nil(t, err)
+	err = original.StartAll(ctx)
+	require.Nil(t, err)
+
+	var snapshot *instance.Snapshot
+	require.Eventually(t, func() bool {
+		snapshot, err = original.Snapshot()
+		require.Nil(t, err)
+		return len(snapshot.Flows) == 1 && snapshot.Flows[0].Node == "wait"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []instance.TimerSnapshot{{Node: "wait", ArmedAt: snapshot.CreatedAt}}, snapshot.Timers)
+
+	c.Add(30 * time.Minute)
+	originalCancel()
+
+	restored, err := proc.Restore(snapshot, instance.WithContext(ctx))
+	require.Nil(t, err)
+
+	// The sub-process starts once the restored timer fires and its
+	// own timer is to be armed at that moment, not when the instance
+	// was originally created
+	restoredTraces := false
+	innerElapsed := make(chan struct{})
+	for {
+		var trace tracing.Trace
+		select {
+		case trace = <-traces:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the restored instance to complete")
+		}
+		switch trace := tracing.Unwrap(trace).(type) {
+		case instance.RestorationTrace:
+			restoredTraces = true
+		case catch.ActiveListeningTrace:
+			id, present := trace.Node.Id()
+			if !restoredTraces || !present {
+				continue
+			}
+			switch *id {
+			case "wait":
+				c.Add(30 * time.Minute)
+			case "inner":
+				c.Add(time.Hour)
+				go func() {
+					// Give the timer a chance to fire prematurely
+					time.Sleep(100 * time.Millisecond)
+					close(innerElapsed)
+					c.Add(time.Hour)
+				}()
+			}
+		case flow.VisitTrace:
+			if id, present := trace.Node.Id(); present && *id == "subEnd" {
+				select {
+				case <-innerElapsed:
+				default:
+					t.Fatal("sub-process timer fired prematurely")
+				}
+			}
+		case flow.CompletionTrace:
+			if id, present := trace.Node.Id(); present && *id == "end" {
+				completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+				defer completionCancel()
+				assert.True(t, restored.WaitUntilComplete(completionCtx))
+				return
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
+
+func TestSnapshotInTransaction(t *testing.T) {
+	c := clock.NewMock()
+	ctx, cancel := context.WithCancel(clock.ToContext(context.Background(), c))
+	defer cancel()
+	fanOut := event.NewFanOut()
+	tracer := tracing.NewTracer(ctx)
+	traces := tracer.SubscribeChannel(make(chan tracing.Trace, 128))
+	proc := process.New(&(*transactionDoc.Processes())[0], &transactionDoc,
+		process.WithEventIngress(fanOut), process.WithEventEgress(fanOut),
+		process.WithEventDefinitionInstanceBuilder(event.DefinitionInstanceBuildingChain(
+			timer.EventDefinitionInstanceBuilder(ctx, fanOut, tracer),
+		)),
+		process.WithTracer(tracer),
+	)
+
+	originalCtx, originalCancel := context.WithCancel(ctx)
+	original, err := proc.Instantiate(instance.WithContext(originalCtx))
+	require.Nil(t, err)
+	err = original.StartAll(ctx)
+	require.Nil(t, err)
+
+	// Wait until the original instance is within the transaction
+	for inner := false; !inner; {
+		var trace tracing.Trace
+		select {
+		case trace = <-traces:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the transaction to start")
+		}
+		switch trace := tracing.Unwrap(trace).(type) {
+		case catch.ActiveListeningTrace:
+			if id, present := trace.Node.Id(); present {
+				switch *id {
+				case "wait":
+					c.Add(time.Hour)
+				case "inner":
+					inner = true
+				}
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+
+	// Only the flow at the transaction itself is captured
+	var snapshot *instance.Snapshot
+	require.Eventually(t, func() bool {
+		snapshot, err = original.Snapshot()
+		require.Nil(t, err)
+		return len(snapshot.Flows) == 1 && snapshot.Flows[0].Node == "sub"
+	}, 5*time.Second, 10*time.Millisecond)
+	originalCancel()
+
+	restored, err := proc.Restore(snapshot, instance.WithContext(ctx))
+	require.Nil(t, err)
+
+	// The transaction is entered anew
+	restoredTraces := false
+	for {
+		var trace tracing.Trace
+		select {
+		case trace = <-traces:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the restored instance to complete")
+		}
+		switch trace := tracing.Unwrap(trace).(type) {
+		case instance.RestorationTrace:
+			restoredTraces = true
+		case catch.ActiveListeningTrace:
+			if id, present := trace.Node.Id(); restoredTraces && present && *id == "inner" {
+				c.Add(2 * time.Hour)
+			}
+		case flow.CompletionTrace:
+			if id, present := trace.Node.Id(); restoredTraces && present && *id == "end" {
+				completionCtx, completionCancel := context.WithTimeout(ctx, 5*time.Second)
+				defer completionCancel()
+				assert.True(t, restored.WaitUntilComplete(completionCtx))
+				return
+			}
+		case tracing.ErrorTrace:
+			t.Fatalf("%#v", trace)
+		default:
+			t.Logf("%#v", trace)
+		}
+	}
+}
@@ -9,6 +9,11 @@
 package instance
 
 import (
+	"time"
+
+	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/data"
+	"bpxe.org/pkg/event"
 	"bpxe.org/pkg/id"
 	"bpxe.org/pkg/tracing"
 )
@@ -16,10 +21,86 @@ import (
 // InstantiationTrace denotes instantiation of a given process
 type InstantiationTrace struct {
 	InstanceId id.Id
+	Process    *bpmn.Process
+	CreatedAt  time.Time
 }
 
 func (i InstantiationTrace) TraceInterface() {}
 
+// RestorationTrace denotes restoration of a given process
+// instance from its snapshot
+type RestorationTrace struct {
+	InstanceId id.Id
+	Process    *bpmn.Process
+	// Time of instance's original creation
+	CreatedAt time.Time
+}
+
+func (t RestorationTrace) TraceInterface() {}
+
+// DataTrace denotes a change of a data object or property
+// of the instance
+type DataTrace struct {
+	// ID of the data object or property
+	Id   string
+	Item data.Item
+}
+
+func (t DataTrace) TraceInterface() {}
+
+// CompletionTrace denotes completion of a given process instance
+// (all of its flows have ceased)
+type CompletionTrace struct {
+	InstanceId id.Id
+	// Final values of instance's data objects and
+	// properties, keyed by their names
+	Data        map[string]interface{}
+	CompletedAt time.Time
+}
+
+func (t CompletionTrace) TraceInterface() {}
+
+// SuspensionTrace denotes suspension of a given process instance
+type SuspensionTrace struct {
+	InstanceId id.Id
+}
+
+func (t SuspensionTrace) TraceInterface() {}
+
+// ResumptionTrace denotes resumption of a given suspended
+// process instance
+type ResumptionTrace struct {
+	InstanceId id.Id
+}
+
+func (t ResumptionTrace) TraceInterface() {}
+
+// TerminationTrace denotes termination of a given process instance
+type TerminationTrace struct {
+	InstanceId   id.Id
+	TerminatedAt time.Time
+}
+
+func (t TerminationTrace) TraceInterface() {}
+
+// UncaughtErrorTrace denotes an error that was raised within
+// a given process instance and hasn't been caught
+type UncaughtErrorTrace struct {
+	InstanceId id.Id
+	Error      *event.ErrorEvent
+}
+
+func (t UncaughtErrorTrace) TraceInterface() {}
+
+// UncaughtEscalationTrace denotes an escalation that was raised
+// within a given process instance and hasn't been caught
+type UncaughtEscalationTrace struct {
+	InstanceId id.Id
+	Escalation *event.EscalationEvent
+}
+
+func (t UncaughtEscalationTrace) TraceInterface() {}
+
 // Trace wraps any trace with process instance id
 type Trace struct {
 	InstanceId id.Id
@@ -31,3 +112,23 @@ func (t Trace) Unwrap() tracing.Trace {
 }
 
 func (t Trace) TraceInterface() {}
+
+// UnwrapTrace unwraps a given trace, returning the innermost trace
+// along with the innermost instance's Trace it was wrapped into
+// (nil if there was none)
+//
+// Instance lifecycle traces (such as InstantiationTrace) are not
+// wrapped into their own instance's Trace.
+func UnwrapTrace(trace tracing.Trace) (innermost tracing.Trace, instanceTrace *Trace) {
+	innermost = trace
+	for {
+		if t, ok := innermost.(Trace); ok {
+			instanceTrace = &t
+		}
+		wrapped, ok := innermost.(tracing.WrappedTrace)
+		if !ok {
+			return
+		}
+		innermost = wrapped.Unwrap()
+	}
+}
@@ -12,7 +12,11 @@ import (
 	"context"
 
 	"bpxe.org/pkg/bpmn"
+	"bpxe.org/pkg/errors"
 	"bpxe.org/pkg/event"
+	"bpxe.org/pkg/flow_node/activity/call_activity"
+	"bpxe.org/pkg/flow_node/activity/service_task"
+	"bpxe.org/pkg/flow_node/activity/user_task"
 	"bpxe.org/pkg/id"
 	"bpxe.org/pkg/process/instance"
 	"bpxe.org/pkg/tracing"
@@ -21,13 +25,18 @@ import (
 type Process struct {
 	Element                        *bpmn.Process
 	Definitions                    *bpmn.Definitions
-	instances                      []*instance.Instance
+	instances                      *registry
 	EventIngress                   event.Consumer
 	EventEgress                    event.Source
 	idGeneratorBuilder             id.GeneratorBuilder
 	eventDefinitionInstanceBuilder event.DefinitionInstanceBuilder
 	Tracer                         tracing.Tracer
 	subTracerMaker                 func() tracing.Tracer
+	serviceTaskRegistry            *service_task.Registry
+	inbox                          user_task.Inbox
+	instanceObserver               instance.Observer
+	archiveCapacity                int
+	compensateOnCancel             bool
 }
 
 type Option func(context.Context, *Process) context.Context
@@ -68,6 +77,61 @@ func WithTracer(tracer tracing.Tracer) Option {
 	}
 }
 
+// WithServiceTaskRegistry sets a registry of handlers used by
+// service tasks of every instance of the process
+func WithServiceTaskRegistry(registry *service_task.Registry) Option {
+	return func(ctx context.Context, process *Process) context.Context {
+		process.serviceTaskRegistry = registry
+		return ctx
+	}
+}
+
+// WithInbox sets an inbox user tasks of every instance
+// of the process put their work items into
+func WithInbox(inbox user_task.Inbox) Option {
+	return func(ctx context.Context, process *Process) context.Context {
+		process.inbox = inbox
+		return ctx
+	}
+}
+
+// WithCompensationOnCancel makes cancellation of every instance of
+// the process compensate its completed activities first (see
+// instance.WithCompensationOnCancel)
+func WithCompensationOnCancel() Option {
+	return func(ctx context.Context, process *Process) context.Context {
+		process.compensateOnCancel = true
+		return ctx
+	}
+}
+
+// WithInstanceObserver sets an observer notified of changes
+// of state of every instance of the process
+//
+// Instances of processes called by call activities are not observed,
+// as they are re-created along with their calling instances.
+func WithInstanceObserver(observer instance.Observer) Option {
+	return func(ctx context.Context, process *Process) context.Context {
+		process.instanceObserver = observer
+		return ctx
+	}
+}
+
+// WithArchiveCapacity sets the number of complete instances
+// the process keeps track of (DefaultArchiveCapacity by default)
+//
+// Zero (or negative) capacity makes the process forget instances
+// as soon as they are archived (see Instances).
+func WithArchiveCapacity(capacity int) Option {
+	return func(ctx context.Context, process *Process) context.Context {
+		if capacity < 0 {
+			capacity = 0
+		}
+		process.archiveCapacity = capacity
+		return ctx
+	}
+}
+
 // WithContext will pass a given context to a new process
 // instead of implicitly generated one
 func WithContext(newCtx context.Context) Option {
@@ -78,9 +142,9 @@ func WithContext(newCtx context.Context) Option {
 
 func Make(element *bpmn.Process, definitions *bpmn.Definitions, options ...Option) Process {
 	process := Process{
-		Element:     element,
-		Definitions: definitions,
-		instances:   make([]*instance.Instance, 0),
+		Element:         element,
+		Definitions:     definitions,
+		archiveCapacity: DefaultArchiveCapacity,
 	}
 
 	ctx := context.Background()
@@ -89,6 +153,8 @@ func Make(element *bpmn.Process, definitions *bpmn.Definitions, options ...Optio
 		ctx = option(ctx, &process)
 	}
 
+	process.instances = newRegistry(process.archiveCapacity)
+
 	if process.idGeneratorBuilder == nil {
 		process.idGeneratorBuilder = id.DefaultIdGeneratorBuilder
 	}
@@ -97,6 +163,14 @@ func Make(element *bpmn.Process, definitions *bpmn.Definitions, options ...Optio
 		process.eventDefinitionInstanceBuilder = event.WrappingDefinitionInstanceBuilder
 	}
 
+	if process.serviceTaskRegistry == nil {
+		process.serviceTaskRegistry = service_task.NewRegistry()
+	}
+
+	if process.inbox == nil {
+		process.inbox = user_task.NewInbox()
+	}
+
 	if process.EventIngress == nil && process.EventEgress == nil {
 		fanOut := event.NewFanOut()
 		process.EventIngress = fanOut
@@ -135,11 +209,83 @@ func (process *Process) Instantiate(options ...instance.Option) (inst *instance.
 		instance.WithEventEgress(process.EventEgress),
 		instance.WithEventIngress(process.EventIngress),
 		instance.WithTracer(subTracer),
+		instance.WithProcessInstantiator(process.instantiateCalledProcess),
+		instance.WithServiceTaskRegistry(process.serviceTaskRegistry),
+		instance.WithInbox(process.inbox),
 	}, options...)
+	if process.instanceObserver != nil {
+		options = append([]instance.Option{instance.WithObserver(process.instanceObserver)}, options...)
+	}
+	if process.compensateOnCancel {
+		options = append([]instance.Option{instance.WithCompensationOnCancel()}, options...)
+	}
 	inst, err = instance.NewInstance(process.Element, process.Definitions, options...)
 	if err != nil {
 		return
 	}
+	process.instances.add(inst)
+	return
+}
 
+// FindInstance returns process' instance with a given ID,
+// whether it is live or archived (see WithArchiveCapacity)
+func (process *Process) FindInstance(instanceId id.Id) (inst *instance.Instance, found bool) {
+	return process.instances.find(instanceId)
+}
+
+// Instances returns process' live instances (in order of their
+// instantiation), followed by archived ones (in order of their
+// completion). If any statuses are given, only instances with
+// these statuses are returned.
+//
+// Instances are archived asynchronously, shortly after they complete,
+// so a complete instance may still be among live ones for a while.
+// Filtering by status is not affected by that.
+func (process *Process) Instances(statuses ...instance.Status) []*instance.Instance {
+	return process.instances.list(statuses...)
+}
+
+// Restore re-creates an instance of the process from its snapshot
+// (see instance.Instance.Snapshot) and resumes its execution
+func (process *Process) Restore(snapshot *instance.Snapshot,
+	options ...instance.Option) (inst *instance.Instance, err error) {
+	if processId, present := process.Element.Id(); !present || *processId != snapshot.ProcessId {
+		err = errors.InvalidArgumentError{
+			Expected: "snapshot of an instance of this process",
+			Actual:   snapshot.ProcessId,
+		}
+		return
+	}
+	inst, err = process.Instantiate(append(options, instance.WithSnapshot(snapshot))...)
+	return
+}
+
+// instantiateCalledProcess instantiates a process called by a call activity.
+//
+// Called process shares this process' definitions, event ingress/egress,
+// id generator, event definition instance builder, service task
+// registry and inbox, and its traces
+// are relayed to a given tracer. Errors not caught within it
+// are raised through a given function.
+func (process *Process) instantiateCalledProcess(ctx context.Context,
+	element *bpmn.Process, tracer tracing.Tracer, raiseError func(*event.ErrorEvent),
+) (inst call_activity.Instance, err error) {
+	calledProcess := New(element, process.Definitions,
+		WithContext(ctx),
+		WithIdGenerator(process.idGeneratorBuilder),
+		WithEventDefinitionInstanceBuilder(process.eventDefinitionInstanceBuilder),
+		WithEventIngress(process.EventIngress),
+		WithEventEgress(process.EventEgress),
+		WithServiceTaskRegistry(process.serviceTaskRegistry),
+		WithInbox(process.inbox),
+		WithTracer(tracer),
+	)
+	var calledInstance *instance.Instance
+	calledInstance, err = calledProcess.Instantiate(instance.WithContext(ctx),
+		instance.WithRaiseError(raiseError))
+	if err != nil {
+		return
+	}
+	inst = calledInstance
 	return
 }
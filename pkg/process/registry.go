@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Aree Enterprises, Inc. and Contributors
+// Use of this software is governed by the Business Source License
+// included in the file LICENSE
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/LICENSE-Apache-2.0
+
+package process
+
+import (
+	"context"
+	"sync"
+
+	"bpxe.org/pkg/id"
+	"bpxe.org/pkg/process/instance"
+)
+
+// DefaultArchiveCapacity is the default number of complete instances
+// (completed or terminated) the process keeps track of
+const DefaultArchiveCapacity = 128
+
+// registry keeps track of process' instances. Once an instance is
+// complete (see instance.Instance.WaitUntilComplete), it is moved to the
+// archive, where only the most recently completed instances are kept.
+// Archival happens in the background, shortly after completion.
+type registry struct {
+	lock sync.RWMutex
+	// Live instances, in order of their registration
+	live []*instance.Instance
+	// Complete instances, in order of their completion
+	archive         []*instance.Instance
+	archiveCapacity int
+	// Both live and archived instances, keyed by their ID bytes
+	byId map[string]*instance.Instance
+}
+
+func newRegistry(archiveCapacity int) *registry {
+	return &registry{
+		live:            make([]*instance.Instance, 0),
+		archive:         make([]*instance.Instance, 0),
+		archiveCapacity: archiveCapacity,
+		byId:            make(map[string]*instance.Instance),
+	}
+}
+
+// add registers an instance and archives it once it is complete
+// (in the background, see registry)
+func (registry *registry) add(inst *instance.Instance) {
+	registry.lock.Lock()
+	registry.live = append(registry.live, inst)
+	registry.byId[string(inst.Id().Bytes())] = inst
+	registry.lock.Unlock()
+	go func() {
+		// Instance becomes complete when its context is done as well,
+		// so this doesn't outlive it
+		inst.WaitUntilComplete(context.Background())
+		registry.archiveInstance(inst)
+	}()
+}
+
+func (registry *registry) archiveInstance(inst *instance.Instance) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	for i := range registry.live {
+		if registry.live[i] == inst {
+			registry.live = append(registry.live[:i], registry.live[i+1:]...)
+			break
+		}
+	}
+	registry.archive = append(registry.archive, inst)
+	if overflow := len(registry.archive) - registry.archiveCapacity; overflow > 0 {
+		for _, evicted := range registry.archive[:overflow] {
+			delete(registry.byId, string(evicted.Id().Bytes()))
+		}
+		registry.archive = append(registry.archive[:0], registry.archive[overflow:]...)
+	}
+}
+
+func (registry *registry) find(instanceId id.Id) (inst *instance.Instance, found bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	inst, found = registry.byId[string(instanceId.Bytes())]
+	return
+}
+
+// list returns live instances followed by archived ones,
+// only those with given statuses if any are given
+func (registry *registry) list(statuses ...instance.Status) (result []*instance.Instance) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	result = make([]*instance.Instance, 0)
+	for _, instances := range [][]*instance.Instance{registry.live, registry.archive} {
+		for i := range instances {
+			if hasStatus(instances[i], statuses) {
+				result = append(result, instances[i])
+			}
+		}
+	}
+	return
+}
+
+func hasStatus(inst *instance.Instance, statuses []instance.Status) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	status := inst.Status()
+	for i := range statuses {
+		if statuses[i] == status {
+			return true
+		}
+	}
+	return false
+}
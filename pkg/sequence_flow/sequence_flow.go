@@ -44,13 +44,12 @@ func (sequenceFlow *SequenceFlow) resolveId(id *string) (result bpmn.FlowNodeInt
 	var process *bpmn.Process
 	for i := range *sequenceFlow.definitions.Processes() {
 		proc := &(*sequenceFlow.definitions.Processes())[i]
-		sequenceFlows := proc.SequenceFlows()
-		for j := range *sequenceFlows {
-			if idPtr, present := (*sequenceFlows)[j].Id(); present {
-				if *idPtr == *ownId {
-					process = proc
-				}
-			}
+		// Sequence flows can be nested within sub-processes,
+		// so we're searching for them recursively
+		if _, found := proc.FindBy(bpmn.ExactId(*ownId).
+			And(bpmn.ElementType((*bpmn.SequenceFlow)(nil)))); found {
+			process = proc
+			break
 		}
 	}
 	if process == nil {
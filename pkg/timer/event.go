@@ -10,6 +10,7 @@ package timer
 
 import (
 	"context"
+	"time"
 
 	"bpxe.org/pkg/bpmn"
 	"bpxe.org/pkg/clock"
@@ -25,6 +26,9 @@ type eventDefinitionInstanceBuilder struct {
 	context      context.Context
 	eventIngress event.Consumer
 	tracer       tracing.Tracer
+	// if set, timers are resumed as armed at this time (see Resume)
+	armedAt    *time.Time
+	firedUntil time.Time
 }
 
 type eventDefinitionInstance struct {
@@ -43,7 +47,11 @@ func (e *eventDefinitionInstanceBuilder) NewEventDefinitionInstance(def bpmn.Eve
 			return
 		}
 		var timer chan bpmn.TimerEventDefinition
-		timer, err = New(e.context, c, *timerEventDefinition)
+		if e.armedAt != nil {
+			timer, err = Resume(e.context, c, *timerEventDefinition, *e.armedAt, e.firedUntil)
+		} else {
+			timer, err = New(e.context, c, *timerEventDefinition)
+		}
 		if err != nil {
 			return
 		}
@@ -79,3 +87,23 @@ func EventDefinitionInstanceBuilder(
 		tracer:       tracer,
 	}
 }
+
+// ResumingEventDefinitionInstanceBuilder is the same as
+// EventDefinitionInstanceBuilder, except that it re-creates timers
+// armed at a given time, skipping occurrences due at or before
+// another given time (see Resume)
+func ResumingEventDefinitionInstanceBuilder(
+	ctx context.Context,
+	eventIngress event.Consumer,
+	tracer tracing.Tracer,
+	armedAt time.Time,
+	firedUntil time.Time,
+) event.DefinitionInstanceBuilder {
+	return &eventDefinitionInstanceBuilder{
+		context:      ctx,
+		eventIngress: eventIngress,
+		tracer:       tracer,
+		armedAt:      &armedAt,
+		firedUntil:   firedUntil,
+	}
+}
@@ -20,6 +20,18 @@ import (
 )
 
 func New(ctx context.Context, clock clock.Clock, definition bpmn.TimerEventDefinition) (ch chan bpmn.TimerEventDefinition, err error) {
+	return Resume(ctx, clock, definition, clock.Now(), time.Time{})
+}
+
+// Resume is the same as New, except that it re-creates a timer that was
+// armed in the past: durations and cycles without an explicit start are
+// measured from the time the timer was armed at, and occurrences due
+// at or before a given time are considered to have fired already and
+// are skipped. An occurrence due since then, but before the current time,
+// fires immediately and, as usual, the next occurrence of a cycle is due
+// an interval after the time it was delivered at.
+func Resume(ctx context.Context, clock clock.Clock, definition bpmn.TimerEventDefinition,
+	armedAt time.Time, firedUntil time.Time) (ch chan bpmn.TimerEventDefinition, err error) {
 	timeDate, timeDatePresent := definition.TimeDate()
 	timeCycle, timeCyclePresent := definition.TimeCycle()
 	timeDuration, timeDurationPresent := definition.TimeDuration()
@@ -31,6 +43,10 @@ func New(ctx context.Context, clock clock.Clock, definition bpmn.TimerEventDefin
 		if err != nil {
 			return
 		}
+		if !t.After(firedUntil) {
+			close(ch)
+			return
+		}
 		go dateTimeTimer(ctx, clock, t, func() {
 			ch <- definition
 			close(ch)
@@ -43,10 +59,9 @@ func New(ctx context.Context, clock clock.Clock, definition bpmn.TimerEventDefin
 			return
 		}
 		if repeatingInterval.Interval.Start == nil {
-			now := clock.Now()
-			repeatingInterval.Interval.Start = &now
+			repeatingInterval.Interval.Start = &armedAt
 		}
-		go recurringTimer(ctx, clock, repeatingInterval, func() {
+		go recurringTimer(ctx, clock, repeatingInterval, firedUntil, func() {
 			ch <- definition
 		}, func() {
 			close(ch)
@@ -58,7 +73,12 @@ func New(ctx context.Context, clock clock.Clock, definition bpmn.TimerEventDefin
 		if err != nil {
 			return
 		}
-		go dateTimeTimer(ctx, clock, clock.Now().Add(duration.Duration), func() {
+		t := armedAt.Add(duration.Duration)
+		if !t.After(firedUntil) {
+			close(ch)
+			return
+		}
+		go dateTimeTimer(ctx, clock, t, func() {
 			ch <- definition
 			close(ch)
 		})
@@ -72,7 +92,8 @@ func New(ctx context.Context, clock clock.Clock, definition bpmn.TimerEventDefin
 	return
 }
 
-func recurringTimer(ctx context.Context, clock clock.Clock, interval iso8601.RepeatingInterval, f func(), final func()) {
+func recurringTimer(ctx context.Context, clock clock.Clock, interval iso8601.RepeatingInterval,
+	firedUntil time.Time, f func(), final func()) {
 	if interval.Interval.Start == nil {
 		panic("shouldn't happen, has to be always set, explicitly or by timer.New")
 	}
@@ -101,7 +122,17 @@ func recurringTimer(ctx context.Context, clock clock.Clock, interval iso8601.Rep
 			return
 		}
 
-		timer = clock.Until(t.Add(interval.Interval.Duration.Duration))
+		next := t.Add(interval.Interval.Duration.Duration)
+		if !next.After(firedUntil) {
+			// This occurrence has fired already
+			t = next
+			if repetitions > 0 {
+				repetitions--
+			}
+			continue
+		}
+
+		timer = clock.Until(next)
 
 		if interval.Interval.End != nil {
 			endTimer = clock.Until(*interval.Interval.End)
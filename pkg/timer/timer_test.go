@@ -14,6 +14,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"testing"
+	"time"
 
 	"bpxe.org/pkg/bpmn"
 	"bpxe.org/pkg/clock"
@@ -233,6 +234,56 @@ func TestTimeCycleEndDate(t *testing.T) {
 	requireCompletion(t, timer)
 }
 
+func TestResumeTimeDuration(t *testing.T) {
+	c := clock.NewMock()
+
+	definition := bpmn.DefaultTimerEventDefinition()
+	duration := bpmn.AnExpression{}
+	err := xml.NewDecoder(bytes.NewBufferString(`<bpmn:expression>PT30M</bpmn:expression>`)).Decode(&duration)
+	require.Nil(t, err)
+	definition.SetTimeDuration(&duration)
+	armedAt := c.Now()
+	c.Add(time.Hour)
+
+	// Fired already
+	timer, err := Resume(context.Background(), c, definition, armedAt, armedAt.Add(30*time.Minute))
+	require.Nil(t, err)
+	requireCompletion(t, timer)
+
+	// Missed while the timer wasn't running
+	timer, err = Resume(context.Background(), c, definition, armedAt, armedAt.Add(10*time.Minute))
+	require.Nil(t, err)
+	<-timer
+	requireCompletion(t, timer)
+}
+
+func TestResumeTimeCycle(t *testing.T) {
+	c := clock.NewMock()
+
+	definition := bpmn.DefaultTimerEventDefinition()
+	cycle := bpmn.AnExpression{}
+	err := xml.NewDecoder(bytes.NewBufferString(`<bpmn:expression>R3/PT30M</bpmn:expression>`)).Decode(&cycle)
+	require.Nil(t, err)
+	definition.SetTimeCycle(&cycle)
+	armedAt := c.Now()
+	c.Add(70 * time.Minute)
+
+	// The first occurrence has fired, the second one was missed
+	timer, err := Resume(context.Background(), c, definition, armedAt, armedAt.Add(40*time.Minute))
+	require.Nil(t, err)
+	<-timer
+	requireNoMoreMessages(t, timer, false)
+
+	// The third occurrence is due an interval after
+	// the missed one was delivered
+	c.Add(20 * time.Minute)
+	requireNoMoreMessages(t, timer, false)
+	c.Add(10 * time.Minute)
+	<-timer
+	_, ok := <-timer
+	require.False(t, ok)
+}
+
 // requireCompletion tests whether timer receives anything but channel
 // closure event; if it does, it'll fail the test
 func requireCompletion(t *testing.T, timer chan bpmn.TimerEventDefinition) {